@@ -48,6 +48,8 @@ func main() {
 	rootCmd.AddCommand(cmd.NewDeleteCmd(f, streams))
 	rootCmd.AddCommand(cmd.NewRenderCmd(f, streams))
 	rootCmd.AddCommand(cmd.NewDiffCmd(f, streams))
+	rootCmd.AddCommand(cmd.NewHooksCmd(f, streams))
+	rootCmd.AddCommand(cmd.NewTestCmd(f, streams))
 	rootCmd.AddCommand(cmd.NewDumpValuesCmd(streams))
 	rootCmd.AddCommand(cmd.NewVersionCmd(streams))
 
@@ -10,6 +10,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/fatih/color v1.7.0
 	github.com/gobwas/glob v0.2.3 // indirect
+	github.com/googleapis/gnostic v0.0.0-20170729233727-0c5108395e2d
 	github.com/huandu/xstrings v1.2.0 // indirect
 	github.com/imdario/mergo v0.3.7
 	github.com/martinohmann/go-difflib v1.1.0
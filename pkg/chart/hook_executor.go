@@ -1,59 +1,111 @@
 package chart
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/martinohmann/kubectl-chart/pkg/deletions"
 	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
 	"github.com/martinohmann/kubectl-chart/pkg/printers"
 	"github.com/martinohmann/kubectl-chart/pkg/resources"
 	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/klog"
 )
 
-var jobGVR = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+var (
+	// JobGVR is the GroupVersionResource of Job hooks.
+	JobGVR = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+
+	// InterruptCleanupTimeout is the maximum time to wait for the deletion
+	// of hooks that were interrupted.
+	InterruptCleanupTimeout = 30 * time.Second
+)
+
+// FailureReasonEnvVar is the name of the environment variable that is
+// injected into apply-failed and delete-failed hooks. It contains the error
+// that caused the hooks to be executed.
+const FailureReasonEnvVar = "KUBECTL_CHART_FAILURE_REASON"
 
 // HookExecutor executes chart lifecycle hooks.
 type HookExecutor struct {
 	genericclioptions.IOStreams
 	DynamicClient dynamic.Interface
-	Mapper        meta.RESTMapper
+	Mapper        kmeta.RESTMapper
 	Deleter       deletions.Deleter
 	Waiter        wait.Waiter
 	Printer       printers.ContextPrinter
-	DryRun        bool
+
+	// DryRun if enabled, hooks are only printed but not created.
+	DryRun bool
+
+	// ServerDryRun if enabled, hooks are created with the dry-run flag set so
+	// that admission and validation are performed by the server without
+	// persisting the hooks.
+	ServerDryRun bool
+
+	// KubeContext is the name of the kubeconfig context that is passed to
+	// local hooks.
+	KubeContext string
+
+	// DeleteOnInterrupt if enabled, Job hooks that are still running when
+	// the context passed to the executor is cancelled are deleted.
+	DeleteOnInterrupt bool
+
+	localHooks sync.WaitGroup
 }
 
-// NewHookExecutor creates a new *HookExecutor.
+// NewHookExecutor creates a new *HookExecutor. If serverDryRun is true, the
+// cleanup of hooks already present in the cluster and the creation of new
+// hooks are performed as server side dry run.
 func NewHookExecutor(
 	streams genericclioptions.IOStreams,
 	client dynamic.Interface,
-	mapper meta.RESTMapper,
+	mapper kmeta.RESTMapper,
 	printer printers.ContextPrinter,
 	dryRun bool,
+	serverDryRun bool,
 ) *HookExecutor {
+	deleter := deletions.NewSilentDeleter(streams, client, dryRun)
+	if serverDryRun {
+		deleter = deletions.NewSilentServerDryRunDeleter(streams, client)
+	}
+
 	return &HookExecutor{
 		IOStreams:     streams,
 		DynamicClient: client,
 		Mapper:        mapper,
-		Deleter:       deletions.NewSilentDeleter(streams, client, dryRun),
+		Deleter:       deleter,
 		Waiter:        wait.NewWaiter(streams, printer.WithOperation("completed")),
 		Printer:       printer.WithOperation("triggered"),
 		DryRun:        dryRun,
+		ServerDryRun:  serverDryRun,
 	}
 }
 
 // ExecHooks executes hooks of hookType from chart c. It will attempt to delete
 // job hooks matching a label selector that are already deployed to the cluster
-// before creating the hooks to prevent errors.
-func (e *HookExecutor) ExecHooks(c *Chart, hookType string) error {
+// before creating the hooks to prevent errors. Local hooks are executed in
+// the order they appear in between the creation of job hooks, after all job
+// hooks that appear before them have completed. Once ctx is cancelled, no
+// further hooks are started.
+func (e *HookExecutor) ExecHooks(ctx context.Context, c *Chart, hookType string) error {
 	if e == nil {
 		return nil
 	}
@@ -65,84 +117,395 @@ func (e *HookExecutor) ExecHooks(c *Chart, hookType string) error {
 	}
 
 	// Make sure that there are no conflicting hooks present in the cluster.
-	err := e.cleanupHooks(c.Config.Name, hookType)
+	err := e.cleanupHooks(ctx, c.Config.Name, hookType, nil)
 	if err != nil {
 		return err
 	}
 
-	infos := make([]*resource.Info, 0)
-	resourceOptions := make(wait.ResourceOptions)
+	return e.execHooks(ctx, c, hooks)
+}
 
-	err = hooks.EachItem(func(h *hook.Hook) error {
-		e.printHook(h)
+// Wait blocks until all local hooks that were started without waiting for
+// their completion have exited. Hooks that are still running are killed once
+// the context that was passed to the executor when starting them is
+// cancelled.
+func (e *HookExecutor) Wait() {
+	if e == nil {
+		return
+	}
 
-		if e.DryRun {
-			return nil
-		}
+	e.localHooks.Wait()
+}
+
+// ExecNamedHooks executes the hooks of hookType from chart c whose names are
+// contained in names. In contrast to ExecHooks, only the Jobs of the selected
+// hooks are deleted from the cluster before the hooks are created, Jobs of
+// other hooks of the same type are left untouched.
+func (e *HookExecutor) ExecNamedHooks(ctx context.Context, c *Chart, hookType string, names []string) error {
+	if e == nil {
+		return nil
+	}
 
-		gvk := h.GroupVersionKind()
+	hooks := c.Hooks[hookType].Filter(func(h *hook.Hook) bool {
+		return Include(names, h.GetName())
+	})
 
-		mapping, err := e.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	err := e.cleanupHooks(ctx, c.Config.Name, hookType, names)
+	if err != nil {
+		return err
+	}
+
+	return e.execHooks(ctx, c, hooks)
+}
+
+// ExecFailureHooks executes hooks of hookType from chart c just like
+// ExecHooks does. The error message of cause is passed to the hooks via the
+// kubectl-chart/hook-failure-reason annotation and the
+// KUBECTL_CHART_FAILURE_REASON environment variable.
+func (e *HookExecutor) ExecFailureHooks(ctx context.Context, c *Chart, hookType string, cause error) error {
+	if e == nil {
+		return nil
+	}
+
+	hooks := c.Hooks[hookType]
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	err := e.cleanupHooks(ctx, c.Config.Name, hookType, nil)
+	if err != nil {
+		return err
+	}
+
+	failureHooks := make(hook.List, 0, len(hooks))
+
+	for _, h := range hooks {
+		fh, err := withFailureReason(h, cause.Error())
 		if err != nil {
 			return err
 		}
 
-		obj, err := e.DynamicClient.
-			Resource(mapping.Resource).
-			Namespace(h.GetNamespace()).
-			Create(h.Unstructured, metav1.CreateOptions{})
+		failureHooks = append(failureHooks, fh)
+	}
+
+	return e.execHooks(ctx, c, failureHooks)
+}
+
+// withFailureReason returns a copy of h with the failure reason annotation
+// set. For Job hooks, the reason is also added to the environment of all
+// containers of the pod template.
+func withFailureReason(h *hook.Hook, reason string) (*hook.Hook, error) {
+	fh := *h
+	fh.Unstructured = h.DeepCopy()
+
+	annotations := fh.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[meta.AnnotationHookFailureReason] = reason
+	fh.SetAnnotations(annotations)
+
+	if fh.IsLocal() {
+		return &fh, nil
+	}
+
+	for _, field := range []string{"initContainers", "containers"} {
+		fields := []string{"spec", "template", "spec", field}
+
+		containers, found, err := unstructured.NestedSlice(fh.Object, fields...)
 		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			continue
+		}
+
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			env, _, _ := unstructured.NestedSlice(container, "env")
+
+			container["env"] = append(env, map[string]interface{}{
+				"name":  FailureReasonEnvVar,
+				"value": reason,
+			})
+		}
+
+		err = unstructured.SetNestedSlice(fh.Object, containers, fields...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &fh, nil
+}
+
+// execHooks creates all hooks and waits for their completion. It does not
+// cleanup hooks that are already present in the cluster.
+func (e *HookExecutor) execHooks(ctx context.Context, c *Chart, hooks hook.List) error {
+	infos := make([]*resource.Info, 0)
+	resourceOptions := make(wait.ResourceOptions)
+
+	err := hooks.EachItem(func(h *hook.Hook) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		if h.NoWait {
+		e.printHook(h)
+
+		if h.IsLocal() || (h.Retries > 0 && !e.dryRun()) {
+			// Hooks are ordered, so Job hooks that were created before
+			// have to complete before a hook that runs synchronously is
+			// executed.
+			if err := e.waitForCompletion(ctx, infos, resourceOptions); err != nil {
+				return err
+			}
+
+			infos = make([]*resource.Info, 0)
+			resourceOptions = make(wait.ResourceOptions)
+		}
+
+		if h.Retries > 0 && (h.IsLocal() || !e.dryRun()) {
+			return e.execHookWithRetries(ctx, c, h)
+		}
+
+		if h.IsLocal() {
+			return e.execLocalHook(ctx, c, h)
+		}
+
+		if e.DryRun {
 			return nil
 		}
 
-		info := &resource.Info{
-			Mapping:         mapping,
-			Namespace:       obj.GetNamespace(),
-			Name:            obj.GetName(),
-			Object:          obj,
-			ResourceVersion: obj.GetResourceVersion(),
+		info, err := e.createHook(h)
+		if err != nil || h.NoWait || e.ServerDryRun {
+			return err
 		}
 
 		infos = append(infos, info)
 
-		metadata, err := meta.Accessor(obj)
-		if err != nil {
-			klog.V(1).Info(err)
-			return nil
+		setResourceOptions(resourceOptions, info.Object, hookWaitOptions(h))
+
+		return nil
+	})
+	if err == nil {
+		err = e.waitForCompletion(ctx, infos, resourceOptions)
+	}
+
+	if err != nil && ctx.Err() != nil {
+		e.deleteInterruptedHooks(infos)
+	}
+
+	return err
+}
+
+// deleteInterruptedHooks deletes Job hooks that were still running when the
+// hook execution was interrupted. It is a no-op if DeleteOnInterrupt is
+// disabled.
+func (e *HookExecutor) deleteInterruptedHooks(infos []*resource.Info) {
+	if !e.DeleteOnInterrupt || len(infos) == 0 {
+		return
+	}
+
+	// The context of the hook execution is already cancelled at this point,
+	// so we need a fresh one to be able to delete the hooks.
+	ctx, cancel := context.WithTimeout(context.Background(), InterruptCleanupTimeout)
+	defer cancel()
+
+	err := e.Deleter.Delete(ctx, resource.InfoListVisitor(infos))
+	if err != nil {
+		fmt.Fprintf(e.ErrOut, "error deleting interrupted hooks: %v\n", err)
+		return
+	}
+
+	for _, info := range infos {
+		fmt.Fprintf(e.ErrOut, "deleted interrupted hook %s/%s\n", info.Mapping.Resource.Resource, info.Name)
+	}
+}
+
+// execHookWithRetries executes hook h and waits for its completion. If the
+// hook fails, it is deleted and executed again until it either succeeds or
+// h.Retries is exhausted.
+func (e *HookExecutor) execHookWithRetries(ctx context.Context, c *Chart, h *hook.Hook) error {
+	attempts := h.Retries + 1
+
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(h.RetryDelay):
+			}
+
+			e.Printer.WithContext(fmt.Sprintf("attempt %d/%d", attempt, attempts)).PrintObj(h, e.Out)
 		}
 
-		uid := metadata.GetUID()
-		if uid == "" {
+		var info *resource.Info
+
+		info, err = e.execHookAttempt(ctx, c, h)
+		if err == nil {
 			return nil
 		}
 
-		options := wait.Options{
-			AllowFailure: h.AllowFailure,
-			Timeout:      h.WaitTimeout,
+		if ctx.Err() != nil {
+			if info != nil {
+				e.deleteInterruptedHooks([]*resource.Info{info})
+			}
+
+			return err
+		}
+
+		if attempt == attempts {
+			break
 		}
 
-		if options.Timeout == 0 {
-			options.Timeout = wait.DefaultWaitTimeout
+		klog.V(1).Infof("hook %q failed on attempt %d/%d: %v", h.GetName(), attempt, attempts, err)
+
+		if info == nil {
+			continue
 		}
 
-		resourceOptions[uid] = options
+		// The failed Job has to be removed before it can be created again.
+		err = e.Deleter.Delete(ctx, resource.InfoListVisitor([]*resource.Info{info}))
+		if err != nil {
+			return err
+		}
+	}
 
+	if h.AllowFailure {
+		fmt.Fprintln(e.ErrOut, err.Error())
 		return nil
-	})
+	}
+
+	return err
+}
+
+// execHookAttempt executes hook h once and waits for its completion. For Job
+// hooks the *resource.Info of the created Job is returned.
+func (e *HookExecutor) execHookAttempt(ctx context.Context, c *Chart, h *hook.Hook) (*resource.Info, error) {
+	if h.IsLocal() {
+		return nil, e.runLocalHook(ctx, c, h)
+	}
+
+	info, err := e.createHook(h)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return e.waitForCompletion(infos, resourceOptions)
+	options := hookWaitOptions(h)
+
+	// Failures must not be ignored here, otherwise the hook is never
+	// retried.
+	options.AllowFailure = false
+
+	resourceOptions := make(wait.ResourceOptions)
+
+	setResourceOptions(resourceOptions, info.Object, options)
+
+	return info, e.waitForCompletion(ctx, []*resource.Info{info}, resourceOptions)
 }
 
-func (e *HookExecutor) cleanupHooks(chartName, hookType string) error {
+// createHook creates hook h in the cluster. During server dry-run the hook
+// is created under a generated name.
+func (e *HookExecutor) createHook(h *hook.Hook) (*resource.Info, error) {
+	gvk := h.GroupVersionKind()
+
+	mapping, err := e.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := h.Unstructured
+
+	if e.ServerDryRun {
+		// The cleanup of conflicting hooks was only simulated, so a hook
+		// from a previous run may still be present. Let the server
+		// generate a unique name to have it validate the hook anyway.
+		obj = obj.DeepCopy()
+		obj.SetGenerateName(obj.GetName() + "-")
+		obj.SetName("")
+	}
+
+	obj, err = e.DynamicClient.
+		Resource(mapping.Resource).
+		Namespace(h.GetNamespace()).
+		Create(obj, e.createOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	info := &resource.Info{
+		Mapping:         mapping,
+		Namespace:       obj.GetNamespace(),
+		Name:            obj.GetName(),
+		Object:          obj,
+		ResourceVersion: obj.GetResourceVersion(),
+	}
+
+	return info, nil
+}
+
+// hookWaitOptions returns the wait options for hook h.
+func hookWaitOptions(h *hook.Hook) wait.Options {
+	options := wait.Options{
+		AllowFailure: h.AllowFailure,
+		Timeout:      h.WaitTimeout,
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = wait.DefaultWaitTimeout
+	}
+
+	return options
+}
+
+// setResourceOptions sets options for obj in resourceOptions. It is a no-op
+// if obj does not have a UID.
+func setResourceOptions(resourceOptions wait.ResourceOptions, obj runtime.Object, options wait.Options) {
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		klog.V(1).Info(err)
+		return
+	}
+
+	uid := metadata.GetUID()
+	if uid == "" {
+		return
+	}
+
+	resourceOptions[uid] = options
+}
+
+func (e *HookExecutor) dryRun() bool {
+	return e.DryRun || e.ServerDryRun
+}
+
+func (e *HookExecutor) createOptions() metav1.CreateOptions {
+	if e.ServerDryRun {
+		return metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+	}
+
+	return metav1.CreateOptions{}
+}
+
+// cleanupHooks deletes the Jobs of all hooks of hookType for chart chartName
+// from the cluster. If names is not empty, only the Jobs with these names are
+// deleted.
+func (e *HookExecutor) cleanupHooks(ctx context.Context, chartName, hookType string, names []string) error {
 	objs, err := e.DynamicClient.
-		Resource(jobGVR).
+		Resource(JobGVR).
 		Namespace(metav1.NamespaceAll).
 		List(metav1.ListOptions{
 			LabelSelector: HookLabelSelector(chartName, hookType),
@@ -155,34 +518,159 @@ func (e *HookExecutor) cleanupHooks(chartName, hookType string) error {
 		return err
 	}
 
+	if len(names) > 0 {
+		items := make([]unstructured.Unstructured, 0, len(objs.Items))
+
+		for _, obj := range objs.Items {
+			if Include(names, obj.GetName()) {
+				items = append(items, obj)
+			}
+		}
+
+		objs.Items = items
+	}
+
 	infos, err := resources.ToInfoList(objs, e.Mapper)
 	if err != nil {
 		return err
 	}
 
-	return e.Deleter.Delete(resource.InfoListVisitor(infos))
+	return e.Deleter.Delete(ctx, resource.InfoListVisitor(infos))
 }
 
-func (e *HookExecutor) waitForCompletion(infos []*resource.Info, options wait.ResourceOptions) error {
+func (e *HookExecutor) waitForCompletion(ctx context.Context, infos []*resource.Info, options wait.ResourceOptions) error {
 	if len(infos) == 0 {
 		return nil
 	}
 
-	err := e.Waiter.Wait(&wait.Request{
-		ConditionFn:     wait.NewCompletionConditionFunc(e.DynamicClient, e.ErrOut),
+	err := e.Waiter.Wait(ctx, &wait.Request{
+		ConditionFn: wait.NewCustomConditionFunc(
+			e.DynamicClient,
+			e.ErrOut,
+			wait.NewCompletionConditionFunc(e.DynamicClient, e.ErrOut),
+		),
 		ResourceOptions: options,
 		Visitor:         resource.InfoListVisitor(infos),
+		Timeout:         maxWaitTimeout(options),
 	})
-	if apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err) {
-		// if we're forbidden from waiting, we shouldn't fail.
-		// if the resource doesn't support a verb we need, we shouldn't fail.
+	if err == nil {
+		return nil
+	}
+
+	// if we're forbidden from waiting, we shouldn't fail.
+	// if the resource doesn't support a verb we need, we shouldn't fail.
+	filtered := utilerrors.FilterOut(err, apierrors.IsForbidden, apierrors.IsMethodNotSupported)
+	if filtered == nil {
 		klog.V(1).Info(err)
 		return nil
 	}
 
+	return filtered
+}
+
+// maxWaitTimeout returns the largest timeout in options. Hooks are awaited
+// concurrently, so none of them must be cut off before its own timeout
+// elapsed.
+func maxWaitTimeout(options wait.ResourceOptions) time.Duration {
+	var timeout time.Duration
+
+	for _, o := range options {
+		if o.Timeout > timeout {
+			timeout = o.Timeout
+		}
+	}
+
+	return timeout
+}
+
+// execLocalHook executes the command of local hook h inside the chart
+// directory. Local hooks are also executed during dry run. They can inspect
+// the KUBECTL_CHART_DRY_RUN environment variable to alter their behaviour.
+// Local hooks with NoWait set are not waited for, but they are tracked and
+// killed once ctx is cancelled.
+func (e *HookExecutor) execLocalHook(ctx context.Context, c *Chart, h *hook.Hook) error {
+	if h.NoWait {
+		cmd := e.localHookCommand(ctx, c, h)
+
+		err := cmd.Start()
+		if err != nil {
+			return errors.Wrapf(err, "while starting local hook %q", h.GetName())
+		}
+
+		e.localHooks.Add(1)
+
+		go func() {
+			defer e.localHooks.Done()
+			cmd.Wait()
+		}()
+
+		return nil
+	}
+
+	err := e.runLocalHook(ctx, c, h)
+	if err != nil && h.AllowFailure && ctx.Err() == nil {
+		fmt.Fprintln(e.ErrOut, err.Error())
+		return nil
+	}
+
 	return err
 }
 
+// runLocalHook executes the command of local hook h and waits for it to
+// finish or to time out. The command is killed if ctx is cancelled.
+func (e *HookExecutor) runLocalHook(ctx context.Context, c *Chart, h *hook.Hook) error {
+	timeout := h.WaitTimeout
+	if timeout == 0 {
+		timeout = wait.DefaultWaitTimeout
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := e.localHookCommand(timeoutCtx, c, h)
+
+	err := cmd.Start()
+	if err != nil {
+		return errors.Wrapf(err, "while starting local hook %q", h.GetName())
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "local hook %q interrupted", h.GetName())
+	}
+
+	if timeoutCtx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("timed out waiting for local hook %q after %s", h.GetName(), timeout)
+	}
+
+	return errors.Wrapf(err, "local hook %q failed", h.GetName())
+}
+
+// localHookCommand builds the command for local hook h. The environment of
+// the command contains information about the chart and the cluster.
+func (e *HookExecutor) localHookCommand(ctx context.Context, c *Chart, h *hook.Hook) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Dir = c.Config.Dir
+	cmd.Stdout = e.Out
+	cmd.Stderr = e.ErrOut
+	cmd.Env = append(
+		os.Environ(),
+		"KUBECTL_CHART_NAME="+c.Config.Name,
+		"KUBECTL_CHART_DIR="+c.Config.Dir,
+		"KUBECTL_CHART_NAMESPACE="+c.Config.Namespace,
+		"KUBECTL_CHART_KUBE_CONTEXT="+e.KubeContext,
+		"KUBECTL_CHART_DRY_RUN="+strconv.FormatBool(e.dryRun()),
+		"KUBECTL_CHART_HOOK_NAME="+h.GetName(),
+		"KUBECTL_CHART_HOOK_TYPE="+h.Type,
+	)
+
+	if reason, ok := h.GetAnnotations()[meta.AnnotationHookFailureReason]; ok {
+		cmd.Env = append(cmd.Env, FailureReasonEnvVar+"="+reason)
+	}
+
+	return cmd
+}
+
 // printHook prints a hooks.
 func (e *HookExecutor) printHook(h *hook.Hook) error {
 	options := make([]string, 0)
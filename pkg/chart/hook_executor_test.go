@@ -2,6 +2,8 @@ package chart
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -19,6 +21,7 @@ import (
 	"github.com/stretchr/testify/require"
 	kmeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -34,11 +37,12 @@ import (
 
 func TestHookExecutor_ExecHooks(t *testing.T) {
 	cases := []struct {
-		name       string
-		fakeClient func() *dynamicfakeclient.FakeDynamicClient
-		hooks      hook.Map
-		hookType   string
-		dryRun     bool
+		name         string
+		fakeClient   func() *dynamicfakeclient.FakeDynamicClient
+		hooks        hook.Map
+		hookType     string
+		dryRun       bool
+		serverDryRun bool
 
 		expectedErr          string
 		validateActions      func(t *testing.T, actions []clienttesting.Action)
@@ -258,6 +262,7 @@ func TestHookExecutor_ExecHooks(t *testing.T) {
 
 				require.Equal(t, 1*time.Hour, reqs[0].ResourceOptions["some-uid"].Timeout)
 				require.True(t, reqs[0].ResourceOptions["some-uid"].AllowFailure)
+				require.Equal(t, 1*time.Hour, reqs[0].Timeout)
 			},
 		},
 		{
@@ -377,6 +382,61 @@ func TestHookExecutor_ExecHooks(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "hooks are created but not awaited during server dry-run",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				return dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+			},
+			serverDryRun: true,
+			hookType:     hook.TypePostApply,
+			hooks: hook.Map{
+				hook.TypePostApply: hook.List{
+					hook.MustParse(&unstructured.Unstructured{
+						Object: map[string]interface{}{
+							"apiVersion": "batch/v1",
+							"kind":       "Job",
+							"metadata": map[string]interface{}{
+								"name":      "somehook",
+								"namespace": "bar",
+								"annotations": map[string]interface{}{
+									meta.AnnotationHookType: hook.TypePostApply,
+								},
+								"labels": map[string]interface{}{
+									meta.LabelHookChartName: "foochart",
+									meta.LabelHookType:      hook.TypePostApply,
+								},
+							},
+							"spec": map[string]interface{}{
+								"template": map[string]interface{}{
+									"spec": map[string]interface{}{
+										"restartPolicy": "Never",
+									},
+								},
+							},
+						},
+					}),
+				},
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				if !actions[1].Matches("create", "jobs") {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				obj := actions[1].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+
+				assert.Equal(t, "", obj.GetName())
+				assert.Equal(t, "somehook-", obj.GetGenerateName())
+			},
+			validateWaitRequests: func(t *testing.T, reqs []*wait.Request) {
+				if len(reqs) != 0 {
+					t.Fatal(spew.Sdump(reqs))
+				}
+			},
+		},
 		{
 			name: "no hooks defined",
 			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
@@ -409,9 +469,10 @@ func TestHookExecutor_ExecHooks(t *testing.T) {
 				DynamicClient: fakeClient,
 				Printer:       printers.NewDiscardingContextPrinter(),
 				DryRun:        tc.dryRun,
+				ServerDryRun:  tc.serverDryRun,
 			}
 
-			err := e.ExecHooks(newTestChart(tc.hooks), tc.hookType)
+			err := e.ExecHooks(context.Background(), newTestChart(tc.hooks), tc.hookType)
 			switch {
 			case err == nil && len(tc.expectedErr) == 0:
 			case err != nil && len(tc.expectedErr) == 0:
@@ -439,10 +500,488 @@ func TestHookExecutor_ExecHooks(t *testing.T) {
 	}
 }
 
+func TestHookExecutor_createOptions(t *testing.T) {
+	e := &HookExecutor{}
+
+	assert.Equal(t, metav1.CreateOptions{}, e.createOptions())
+
+	e.ServerDryRun = true
+
+	assert.Equal(t, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}, e.createOptions())
+}
+
 func TestHookExecutor_ExecHooks_Nil(t *testing.T) {
 	var executor *HookExecutor
 
-	assert.NoError(t, executor.ExecHooks(&Chart{}, hook.TypePreApply))
+	assert.NoError(t, executor.ExecHooks(context.Background(), &Chart{}, hook.TypePreApply))
+}
+
+func newLocalHook(hookType string, annotations map[string]interface{}, command ...interface{}) *hook.Hook {
+	if annotations == nil {
+		annotations = make(map[string]interface{})
+	}
+
+	annotations[meta.AnnotationHookType] = hookType
+
+	return hook.MustParse(&unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "kubectl-chart/v1",
+			"kind":       "LocalHook",
+			"metadata": map[string]interface{}{
+				"name":        "localhook",
+				"annotations": annotations,
+			},
+			"spec": map[string]interface{}{
+				"command": command,
+			},
+		},
+	})
+}
+
+func TestHookExecutor_ExecHooks_Local(t *testing.T) {
+	cases := []struct {
+		name     string
+		hook     *hook.Hook
+		dryRun   bool
+		expected string
+
+		expectedErr    string
+		expectedErrOut string
+	}{
+		{
+			name:     "local hook receives environment",
+			hook:     newLocalHook(hook.TypePreApply, nil, "sh", "-c", "echo $KUBECTL_CHART_NAME $KUBECTL_CHART_KUBE_CONTEXT $KUBECTL_CHART_DRY_RUN $KUBECTL_CHART_HOOK_TYPE"),
+			expected: "foochart somecontext false pre-apply\n",
+		},
+		{
+			name:     "local hooks are executed during dry run",
+			hook:     newLocalHook(hook.TypePreApply, nil, "sh", "-c", "echo $KUBECTL_CHART_DRY_RUN"),
+			dryRun:   true,
+			expected: "true\n",
+		},
+		{
+			name:        "failing local hook",
+			hook:        newLocalHook(hook.TypePreApply, nil, "sh", "-c", "exit 1"),
+			expectedErr: `local hook "localhook" failed: exit status 1`,
+		},
+		{
+			name: "failing local hook with allow-failure",
+			hook: newLocalHook(
+				hook.TypePreApply,
+				map[string]interface{}{meta.AnnotationHookAllowFailure: "true"},
+				"sh", "-c", "exit 1",
+			),
+			expectedErrOut: "local hook \"localhook\" failed: exit status 1\n",
+		},
+		{
+			name: "local hook timeout",
+			hook: newLocalHook(
+				hook.TypePreApply,
+				map[string]interface{}{meta.AnnotationHookWaitTimeout: "100ms"},
+				"sleep", "5",
+			),
+			expectedErr: `timed out waiting for local hook "localhook" after 100ms`,
+		},
+		{
+			name:        "nonexistent command",
+			hook:        newLocalHook(hook.TypePreApply, nil, "/nonexistent/command"),
+			expectedErr: `while starting local hook "localhook"`,
+		},
+		{
+			name: "failing local hook is retried",
+			hook: newLocalHook(
+				hook.TypePreApply,
+				map[string]interface{}{meta.AnnotationHookRetries: "2"},
+				"sh", "-c", "echo attempt; exit 1",
+			),
+			expected:    "attempt\nattempt\nattempt\n",
+			expectedErr: `local hook "localhook" failed: exit status 1`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+
+			e := &HookExecutor{
+				IOStreams:     streams,
+				Deleter:       deletions.NewFakeDeleter(),
+				Waiter:        wait.NewFakeWaiter(),
+				Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				DynamicClient: fakeClient,
+				Printer:       printers.NewDiscardingContextPrinter(),
+				DryRun:        tc.dryRun,
+				KubeContext:   "somecontext",
+			}
+
+			err := e.ExecHooks(context.Background(), newTestChart(hook.Map{tc.hook.Type: hook.List{tc.hook}}), tc.hook.Type)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, out.String())
+			assert.Equal(t, tc.expectedErrOut, errOut.String())
+
+			for _, action := range fakeClient.Actions() {
+				if action.GetVerb() == "create" {
+					t.Fatalf("local hook must not be created in the cluster: %s", spew.Sdump(action))
+				}
+			}
+		})
+	}
+}
+
+func TestHookExecutor_ExecHooks_LocalNoWait(t *testing.T) {
+	streams, _, _, _ := genericclioptions.NewTestIOStreams()
+
+	e := &HookExecutor{
+		IOStreams:     streams,
+		Deleter:       deletions.NewFakeDeleter(),
+		Waiter:        wait.NewFakeWaiter(),
+		Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		DynamicClient: dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme),
+		Printer:       printers.NewDiscardingContextPrinter(),
+	}
+
+	h := newLocalHook(
+		hook.TypePreApply,
+		map[string]interface{}{meta.AnnotationHookNoWait: "true"},
+		"sleep", "30",
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := e.ExecHooks(ctx, newTestChart(hook.Map{h.Type: hook.List{h}}), h.Type)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		e.Wait()
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected local hook to be killed after the context was cancelled")
+	}
+}
+
+func TestHookExecutor_ExecHooks_LocalAfterJob(t *testing.T) {
+	cases := []struct {
+		name             string
+		waitErr          error
+		expected         string
+		expectedRequests []int
+	}{
+		{
+			name:             "local hook runs after preceding jobs completed",
+			expected:         "local\n",
+			expectedRequests: []int{2, 1},
+		},
+		{
+			name:             "local hook does not run if preceding jobs fail",
+			waitErr:          errors.New("job failed"),
+			expectedRequests: []int{2},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+			fakeClient.PrependReactor("create", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, action.(clienttesting.CreateAction).GetObject(), nil
+			})
+
+			waiter := wait.NewFakeWaiter()
+			waiter.Err = tc.waitErr
+
+			e := &HookExecutor{
+				IOStreams:     streams,
+				Deleter:       deletions.NewFakeDeleter(),
+				Waiter:        waiter,
+				Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				DynamicClient: fakeClient,
+				Printer:       printers.NewDiscardingContextPrinter(),
+			}
+
+			hooks := hook.List{
+				newTestHook("job-1"),
+				newTestHook("job-2"),
+				newLocalHook(hook.TypeTest, nil, "echo", "local"),
+				newTestHook("job-3"),
+			}
+
+			err := e.ExecHooks(context.Background(), newTestChart(hook.Map{hook.TypeTest: hooks}), hook.TypeTest)
+			if tc.waitErr != nil {
+				require.Equal(t, tc.waitErr, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, out.String())
+
+			require.Len(t, waiter.Requests, len(tc.expectedRequests))
+
+			for i, n := range tc.expectedRequests {
+				assert.Len(t, waiter.Requests[i].Visitor.(resource.InfoListVisitor), n)
+			}
+		})
+	}
+}
+
+func TestHookExecutor_ExecNamedHooks(t *testing.T) {
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+	fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		list := &unstructured.UnstructuredList{}
+		for _, name := range []string{"job-1", "job-2"} {
+			job := newTestHook(name)
+			job.SetLabels(map[string]string{
+				meta.LabelHookChartName: "foochart",
+				meta.LabelHookType:      hook.TypeTest,
+			})
+			list.Items = append(list.Items, *job.Unstructured)
+		}
+
+		return true, list, nil
+	})
+	fakeClient.PrependReactor("create", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, action.(clienttesting.CreateAction).GetObject(), nil
+	})
+
+	deleter := deletions.NewFakeDeleter()
+	waiter := wait.NewFakeWaiter()
+
+	e := &HookExecutor{
+		IOStreams:     genericclioptions.NewTestIOStreamsDiscard(),
+		Deleter:       deleter,
+		Waiter:        waiter,
+		Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		DynamicClient: fakeClient,
+		Printer:       printers.NewDiscardingContextPrinter(),
+	}
+
+	c := newTestChart(hook.Map{hook.TypeTest: hook.List{newTestHook("job-1"), newTestHook("job-2")}})
+
+	require.NoError(t, e.ExecNamedHooks(context.Background(), c, hook.TypeTest, []string{"job-2"}))
+
+	require.Len(t, deleter.Infos, 1)
+	assert.Equal(t, "job-2", deleter.Infos[0].Name)
+
+	require.Len(t, waiter.Requests, 1)
+
+	infos := waiter.Requests[0].Visitor.(resource.InfoListVisitor)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "job-2", infos[0].Name)
+}
+
+func TestHookExecutor_ExecHooks_Retries(t *testing.T) {
+	cases := []struct {
+		name         string
+		allowFailure bool
+		expectedErr  string
+	}{
+		{
+			name:        "failed hook is retried",
+			expectedErr: "job failed",
+		},
+		{
+			name:         "failed hook with allow-failure is retried",
+			allowFailure: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+			fakeClient.PrependReactor("create", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, action.(clienttesting.CreateAction).GetObject(), nil
+			})
+
+			deleter := deletions.NewFakeDeleter()
+			waiter := wait.NewFakeWaiter()
+			waiter.Err = errors.New("job failed")
+
+			e := &HookExecutor{
+				IOStreams:     genericclioptions.NewTestIOStreamsDiscard(),
+				Deleter:       deleter,
+				Waiter:        waiter,
+				Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				DynamicClient: fakeClient,
+				Printer:       printers.NewDiscardingContextPrinter(),
+			}
+
+			h := newTestHook("somehook")
+			h.SetUID("some-uid")
+			h.Retries = 2
+			h.AllowFailure = tc.allowFailure
+
+			err := e.ExecHooks(context.Background(), newTestChart(hook.Map{hook.TypeTest: hook.List{h}}), hook.TypeTest)
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			creates := 0
+			for _, action := range fakeClient.Actions() {
+				if action.Matches("create", "jobs") {
+					creates++
+				}
+			}
+
+			assert.Equal(t, 3, creates)
+			assert.Len(t, waiter.Requests, 3)
+
+			// The failed Job is deleted before each retry, the last one is kept.
+			require.Len(t, deleter.Infos, 2)
+			assert.Equal(t, "somehook", deleter.Infos[0].Name)
+
+			for _, req := range waiter.Requests {
+				require.Len(t, req.ResourceOptions, 1)
+				assert.False(t, req.ResourceOptions["some-uid"].AllowFailure)
+			}
+		})
+	}
+}
+
+func TestHookExecutor_ExecHooks_Interrupted(t *testing.T) {
+	cases := []struct {
+		name              string
+		deleteOnInterrupt bool
+		expectedDeletes   int
+	}{
+		{
+			name:              "running hooks are deleted",
+			deleteOnInterrupt: true,
+			expectedDeletes:   1,
+		},
+		{
+			name: "running hooks are kept",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+			fakeClient.PrependReactor("create", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				cancel()
+				return true, action.(clienttesting.CreateAction).GetObject(), nil
+			})
+
+			deleter := deletions.NewFakeDeleter()
+			waiter := wait.NewFakeWaiter()
+			waiter.Err = context.Canceled
+
+			e := &HookExecutor{
+				IOStreams:         genericclioptions.NewTestIOStreamsDiscard(),
+				Deleter:           deleter,
+				Waiter:            waiter,
+				Mapper:            testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				DynamicClient:     fakeClient,
+				Printer:           printers.NewDiscardingContextPrinter(),
+				DeleteOnInterrupt: tc.deleteOnInterrupt,
+			}
+
+			h1 := newTestHook("somehook")
+			h2 := newTestHook("otherhook")
+
+			c := newTestChart(hook.Map{hook.TypePreApply: hook.List{h1, h2}})
+
+			err := e.ExecHooks(ctx, c, hook.TypePreApply)
+
+			require.Equal(t, context.Canceled, err)
+			require.Len(t, deleter.Infos, tc.expectedDeletes)
+
+			if tc.expectedDeletes > 0 {
+				assert.Equal(t, "somehook", deleter.Infos[0].Name)
+			}
+
+			creates := 0
+			for _, action := range fakeClient.Actions() {
+				if action.Matches("create", "jobs") {
+					creates++
+				}
+			}
+
+			// The second hook must not be started after the interrupt.
+			assert.Equal(t, 1, creates)
+		})
+	}
+}
+
+func TestHookExecutor_ExecFailureHooks(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+	e := &HookExecutor{
+		IOStreams:     streams,
+		Deleter:       deletions.NewFakeDeleter(),
+		Waiter:        wait.NewFakeWaiter(),
+		Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		DynamicClient: dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme),
+		Printer:       printers.NewDiscardingContextPrinter(),
+	}
+
+	h := newLocalHook(hook.TypeApplyFailed, nil, "sh", "-c", "echo $KUBECTL_CHART_FAILURE_REASON")
+
+	c := newTestChart(hook.Map{hook.TypeApplyFailed: hook.List{h}})
+
+	err := e.ExecFailureHooks(context.Background(), c, hook.TypeApplyFailed, errors.New("apply failed"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "apply failed\n", out.String())
+
+	// The original hook must not be modified.
+	assert.NotContains(t, h.GetAnnotations(), meta.AnnotationHookFailureReason)
+}
+
+func TestWithFailureReason(t *testing.T) {
+	h := newTestHook("somehook")
+
+	unstructured.SetNestedSlice(h.Object, []interface{}{
+		map[string]interface{}{
+			"name": "main",
+			"env": []interface{}{
+				map[string]interface{}{"name": "FOO", "value": "bar"},
+			},
+		},
+	}, "spec", "template", "spec", "containers")
+
+	fh, err := withFailureReason(h, "something failed")
+
+	require.NoError(t, err)
+
+	assert.Equal(t, "something failed", fh.GetAnnotations()[meta.AnnotationHookFailureReason])
+
+	containers, _, _ := unstructured.NestedSlice(fh.Object, "spec", "template", "spec", "containers")
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"name": "main",
+			"env": []interface{}{
+				map[string]interface{}{"name": "FOO", "value": "bar"},
+				map[string]interface{}{"name": FailureReasonEnvVar, "value": "something failed"},
+			},
+		},
+	}
+
+	assert.Equal(t, expected, containers)
+
+	originalContainers, _, _ := unstructured.NestedSlice(h.Object, "spec", "template", "spec", "containers")
+
+	assert.Len(t, originalContainers[0].(map[string]interface{})["env"], 1)
 }
 
 func newTestChart(hooks hook.Map) *Chart {
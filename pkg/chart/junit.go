@@ -0,0 +1,115 @@
+package chart
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes results as JUnit XML report to w. Each chart is
+// represented by a testsuite. Failed tests that are allowed to fail are
+// reported as skipped.
+func WriteJUnitReport(w io.Writer, results []*TestResult) error {
+	report := junitTestSuites{}
+	suiteIndex := make(map[string]int)
+	suiteSeconds := make(map[string]float64)
+
+	for _, r := range results {
+		i, ok := suiteIndex[r.Chart]
+		if !ok {
+			i = len(report.Suites)
+			suiteIndex[r.Chart] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.Chart})
+		}
+
+		suite := &report.Suites[i]
+
+		testCase := junitTestCase{
+			Name:      r.Hook.GetName(),
+			ClassName: r.Chart,
+			Time:      formatSeconds(r.Duration.Seconds()),
+		}
+
+		switch {
+		case r.Skipped():
+			testCase.Skipped = &junitSkipped{
+				Message: fmt.Sprintf("allowed failure: %v", r.Err),
+				Text:    r.Logs,
+			}
+
+			suite.Skipped++
+			report.Skipped++
+		case r.Failed():
+			testCase.Failure = &junitFailure{
+				Message: r.Err.Error(),
+				Text:    r.Logs,
+			}
+
+			suite.Failures++
+			report.Failures++
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Tests++
+		report.Tests++
+
+		suiteSeconds[r.Chart] += r.Duration.Seconds()
+		suite.Time = formatSeconds(suiteSeconds[r.Chart])
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	err = enc.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err
+}
+
+func formatSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
@@ -0,0 +1,67 @@
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	results := []*TestResult{
+		{
+			Chart:    "foo",
+			Hook:     newTestHook("test-one"),
+			Duration: 1500 * time.Millisecond,
+		},
+		{
+			Chart:    "foo",
+			Hook:     newTestHook("test-two"),
+			Duration: 500 * time.Millisecond,
+			Err:      errors.New("job failed"),
+			Logs:     "connection refused",
+		},
+		{
+			Chart:    "bar",
+			Hook:     newTestHook("test-three"),
+			Duration: 2 * time.Second,
+		},
+		{
+			Chart: "bar",
+			Hook: func() *hook.Hook {
+				h := newTestHook("test-four")
+				h.AllowFailure = true
+				return h
+			}(),
+			Duration: time.Second,
+			Err:      errors.New("job failed"),
+		},
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, WriteJUnitReport(&buf, results))
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="4" failures="1" skipped="1">
+  <testsuite name="foo" tests="2" failures="1" skipped="0" time="2.000">
+    <testcase name="test-one" classname="foo" time="1.500"></testcase>
+    <testcase name="test-two" classname="foo" time="0.500">
+      <failure message="job failed">connection refused</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="bar" tests="2" failures="0" skipped="1" time="3.000">
+    <testcase name="test-three" classname="bar" time="2.000"></testcase>
+    <testcase name="test-four" classname="bar" time="1.000">
+      <skipped message="allowed failure: job failed"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+	assert.Equal(t, expected, buf.String())
+}
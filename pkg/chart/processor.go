@@ -1,6 +1,8 @@
 package chart
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -11,6 +13,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// LocalHooksFile is the name of the file in the chart root directory which
+// may contain local hooks. Local hooks are not rendered as templates.
+const LocalHooksFile = "hooks.yaml"
+
 // Processor type processes a chart config and renders the contained resources.
 // It will also perform post-processing on these resources.
 type Processor struct {
@@ -42,6 +48,13 @@ func (p *Processor) Process(config *Config) (*Chart, error) {
 		return nil, err
 	}
 
+	localHooks, err := p.decodeLocalHooks(config)
+	if err != nil {
+		return nil, err
+	}
+
+	hookMap.Add(localHooks...)
+
 	c := &Chart{
 		Config:    config,
 		Resources: resources,
@@ -81,3 +94,30 @@ func (p *Processor) decodeTemplates(config *Config, templates map[string]string)
 
 	return objs, hookMap, nil
 }
+
+// decodeLocalHooks decodes the hooks from the LocalHooksFile in the chart
+// directory if it exists. It is an error if the file contains objects that
+// are not hooks.
+func (p *Processor) decodeLocalHooks(config *Config) ([]*hook.Hook, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(config.Dir, LocalHooksFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := newTemplateDecoder(config, p.Decoder)
+
+	resources, hooks, err := decoder.decodeTemplate(buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while parsing %q", LocalHooksFile)
+	}
+
+	if len(resources) > 0 {
+		return nil, errors.Errorf("%q must only contain hooks, found %d other resources", LocalHooksFile, len(resources))
+	}
+
+	return hooks, nil
+}
@@ -166,5 +166,50 @@ func TestProcessor_ProcessUnsupportedHook(t *testing.T) {
 	_, err := p.Process(config)
 
 	require.Error(t, err)
-	assert.Equal(t, `while parsing template "chart1/templates/hook.yaml": invalid hook "foobar-chart1": unsupported hook type "foo", allowed values are: [post-apply post-delete pre-apply pre-delete]`, err.Error())
+	assert.Equal(t, `while parsing template "chart1/templates/hook.yaml": invalid hook "foobar-chart1": unsupported hook type "foo", allowed values are: [apply-failed delete-failed post-apply post-delete pre-apply pre-delete test]`, err.Error())
+}
+
+func TestProcessor_ProcessLocalHooks(t *testing.T) {
+	config := &Config{
+		Dir:       "testdata/local-hook-chart",
+		Name:      "foobar",
+		Namespace: "foo",
+		Values:    map[interface{}]interface{}{},
+	}
+
+	p := NewDefaultProcessor()
+
+	c, err := p.Process(config)
+
+	require.NoError(t, err)
+
+	expectedHooks := hook.Map{
+		hook.TypePostApply: hook.List{
+			hook.MustParse(&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "kubectl-chart/v1",
+					"kind":       "LocalHook",
+					"metadata": map[string]interface{}{
+						"name":      "update-dns",
+						"namespace": "foo",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:         hook.TypePostApply,
+							meta.AnnotationHookAllowFailure: "true",
+						},
+						"labels": map[string]interface{}{
+							meta.LabelHookChartName: "foobar",
+							meta.LabelHookType:      hook.TypePostApply,
+						},
+					},
+					"spec": map[string]interface{}{
+						"command": []interface{}{"./update-dns.sh", "--zone", "example.com"},
+					},
+				},
+			}),
+		},
+	}
+
+	assert.Empty(t, c.Resources)
+	assert.Equal(t, expectedHooks, c.Hooks)
+	assert.True(t, c.Hooks[hook.TypePostApply][0].IsLocal())
 }
@@ -0,0 +1,170 @@
+package chart
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TestResult is the outcome of a single test hook.
+type TestResult struct {
+	// Chart is the name of the chart the test belongs to.
+	Chart string
+
+	// Hook is the test hook that was executed.
+	Hook *hook.Hook
+
+	// Duration is the time it took to run the test.
+	Duration time.Duration
+
+	// Err is the reason for the test failure. It is nil if the test passed.
+	// For tests that are allowed to fail it is set as well, see Skipped.
+	Err error
+
+	// Logs contains the container logs of failed Job tests.
+	Logs string
+}
+
+// Passed returns true if the test did not fail.
+func (r *TestResult) Passed() bool {
+	return r.Err == nil
+}
+
+// Skipped returns true if the test failed but is allowed to fail. Skipped
+// tests do not count as failures.
+func (r *TestResult) Skipped() bool {
+	return r.Err != nil && r.Hook.AllowFailure
+}
+
+// Failed returns true if the test failed and is not allowed to fail.
+func (r *TestResult) Failed() bool {
+	return r.Err != nil && !r.Hook.AllowFailure
+}
+
+// LogFetcher fetches the logs of a hook.
+type LogFetcher interface {
+	// FetchLogs returns the logs of all containers that were started for h.
+	FetchLogs(h *hook.Hook) (string, error)
+}
+
+// TestRunner runs the test hooks of charts.
+type TestRunner struct {
+	HookExecutor *HookExecutor
+	LogFetcher   LogFetcher
+}
+
+// NewTestRunner creates a new *TestRunner. If logFetcher is nil, logs of
+// failed tests are not collected.
+func NewTestRunner(executor *HookExecutor, logFetcher LogFetcher) *TestRunner {
+	return &TestRunner{
+		HookExecutor: executor,
+		LogFetcher:   logFetcher,
+	}
+}
+
+// RunTests executes all test hooks of chart c one after another and waits for
+// them to complete. Test hooks that are already present in the cluster are
+// removed before the first test is started. A test failure does not cause
+// RunTests to return an error, it is recorded in the corresponding
+// *TestResult instead. If ctx is cancelled, no further tests are started and
+// the results collected so far are returned together with the context error.
+func (r *TestRunner) RunTests(ctx context.Context, c *Chart) ([]*TestResult, error) {
+	hooks := c.Hooks[hook.TypeTest]
+
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+
+	err := r.HookExecutor.cleanupHooks(ctx, c.Config.Name, hook.TypeTest, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*TestResult, 0, len(hooks))
+
+	err = hooks.EachItem(func(h *hook.Hook) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		start := time.Now()
+
+		// The hook executor swallows the errors of hooks that are allowed
+		// to fail, but the result must still reflect the failure.
+		test := *h
+		test.AllowFailure = false
+
+		err := r.HookExecutor.execHooks(ctx, c, hook.List{&test})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		result := &TestResult{
+			Chart:    c.Config.Name,
+			Hook:     h,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+
+		if err != nil && !h.IsLocal() && r.LogFetcher != nil {
+			result.Logs, err = r.LogFetcher.FetchLogs(h)
+			if err != nil {
+				result.Logs = fmt.Sprintf("failed to fetch logs: %v", err)
+			}
+		}
+
+		results = append(results, result)
+
+		return nil
+	})
+
+	return results, err
+}
+
+type jobLogFetcher struct {
+	client kubernetes.Interface
+}
+
+// NewJobLogFetcher creates a new LogFetcher which fetches the logs of the pods
+// created by Job hooks.
+func NewJobLogFetcher(client kubernetes.Interface) LogFetcher {
+	return &jobLogFetcher{
+		client: client,
+	}
+}
+
+// FetchLogs implements LogFetcher.
+func (f *jobLogFetcher) FetchLogs(h *hook.Hook) (string, error) {
+	pods, err := f.client.CoreV1().
+		Pods(h.GetNamespace()).
+		List(metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", h.GetName()),
+		})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			logs, err := f.client.CoreV1().
+				Pods(pod.Namespace).
+				GetLogs(pod.Name, &corev1.PodLogOptions{Container: container.Name}).
+				DoRaw()
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(&buf, "==> %s/%s <==\n%s", pod.Name, container.Name, logs)
+		}
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,160 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/deletions"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+type fakeLogFetcher struct {
+	hooks []*hook.Hook
+}
+
+func (f *fakeLogFetcher) FetchLogs(h *hook.Hook) (string, error) {
+	f.hooks = append(f.hooks, h)
+
+	return "some logs", nil
+}
+
+func newTestHook(name string) *hook.Hook {
+	return hook.MustParse(&unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "bar",
+				"annotations": map[string]interface{}{
+					meta.AnnotationHookType: hook.TypeTest,
+				},
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"restartPolicy": "Never",
+					},
+				},
+			},
+		},
+	})
+}
+
+func newTestTestRunner(waiter *wait.FakeWaiter, logFetcher LogFetcher) *TestRunner {
+	return NewTestRunner(&HookExecutor{
+		IOStreams:     genericclioptions.NewTestIOStreamsDiscard(),
+		Deleter:       deletions.NewFakeDeleter(),
+		Waiter:        waiter,
+		Mapper:        testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		DynamicClient: dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme),
+		Printer:       printers.NewDiscardingContextPrinter(),
+	}, logFetcher)
+}
+
+func TestTestRunner_RunTests(t *testing.T) {
+	waiter := wait.NewFakeWaiter()
+	logFetcher := &fakeLogFetcher{}
+
+	r := newTestTestRunner(waiter, logFetcher)
+
+	c := newTestChart(hook.Map{
+		hook.TypeTest: hook.List{
+			newTestHook("test-one"),
+			newTestHook("test-two"),
+		},
+	})
+
+	results, err := r.RunTests(context.Background(), c)
+
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "foochart", results[0].Chart)
+	assert.Equal(t, "test-one", results[0].Hook.GetName())
+	assert.True(t, results[0].Passed())
+	assert.True(t, results[1].Passed())
+
+	// Each test is awaited separately.
+	assert.Len(t, waiter.Requests, 2)
+	assert.Empty(t, logFetcher.hooks)
+}
+
+func TestTestRunner_RunTests_Failure(t *testing.T) {
+	waiter := wait.NewFakeWaiter()
+	waiter.Err = errors.New("job failed")
+	logFetcher := &fakeLogFetcher{}
+
+	r := newTestTestRunner(waiter, logFetcher)
+
+	c := newTestChart(hook.Map{
+		hook.TypeTest: hook.List{
+			newTestHook("test-one"),
+			newLocalHook(hook.TypeTest, nil, "sh", "-c", "exit 1"),
+		},
+	})
+
+	results, err := r.RunTests(context.Background(), c)
+
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.False(t, results[0].Passed())
+	assert.Equal(t, "job failed", results[0].Err.Error())
+	assert.Equal(t, "some logs", results[0].Logs)
+
+	assert.False(t, results[1].Passed())
+	assert.Empty(t, results[1].Logs)
+
+	// Logs are only fetched for Job hooks.
+	require.Len(t, logFetcher.hooks, 1)
+	assert.Equal(t, "test-one", logFetcher.hooks[0].GetName())
+}
+
+func TestTestRunner_RunTests_AllowFailure(t *testing.T) {
+	waiter := wait.NewFakeWaiter()
+	waiter.Err = errors.New("job failed")
+
+	r := newTestTestRunner(waiter, nil)
+
+	h := newTestHook("test-one")
+	h.AllowFailure = true
+
+	c := newTestChart(hook.Map{hook.TypeTest: hook.List{h}})
+
+	results, err := r.RunTests(context.Background(), c)
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	assert.False(t, results[0].Passed())
+	assert.True(t, results[0].Skipped())
+	assert.False(t, results[0].Failed())
+	assert.Equal(t, "job failed", results[0].Err.Error())
+
+	// The failure must not be ignored while waiting for the test.
+	require.Len(t, waiter.Requests, 1)
+	for _, options := range waiter.Requests[0].ResourceOptions {
+		assert.False(t, options.AllowFailure)
+	}
+}
+
+func TestTestRunner_RunTests_NoTests(t *testing.T) {
+	r := newTestTestRunner(wait.NewFakeWaiter(), nil)
+
+	results, err := r.RunTests(context.Background(), newTestChart(hook.Map{}))
+
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
@@ -1,6 +1,7 @@
 package chart
 
 import (
+	"context"
 	"io/ioutil"
 	"path/filepath"
 
@@ -23,8 +24,9 @@ type VisitorFunc func(chart *Chart, err error) error
 // Visitor is a type that visits charts.
 type Visitor interface {
 	// Visit accepts a function that is called for every chart the visitor
-	// encounters.
-	Visit(fn VisitorFunc) error
+	// encounters. Once ctx is cancelled, no further charts are visited and
+	// the context error is returned.
+	Visit(ctx context.Context, fn VisitorFunc) error
 }
 
 // Visitor is a chart visitor.
@@ -44,7 +46,7 @@ func NewVisitor(p *Processor, o VisitorOptions) Visitor {
 
 // Visit implements Visitor. The visitor will use a chart processor to process
 // every chart before passing the chart config, resources and hooks to fn.
-func (v *visitor) Visit(fn VisitorFunc) error {
+func (v *visitor) Visit(ctx context.Context, fn VisitorFunc) error {
 	values, err := LoadValues(v.Options.ValueFiles...)
 	if err != nil {
 		return err
@@ -60,6 +62,10 @@ func (v *visitor) Visit(fn VisitorFunc) error {
 			continue
 		}
 
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		c, err := v.Processor.Process(config)
 		if err != nil {
 			return errors.Wrapf(err, "while processing chart %q", config.Name)
@@ -144,10 +150,10 @@ func NewReverseVisitor(visitor Visitor) *ReverseVisitor {
 }
 
 // Visit implements Visitor.
-func (v *ReverseVisitor) Visit(fn VisitorFunc) error {
+func (v *ReverseVisitor) Visit(ctx context.Context, fn VisitorFunc) error {
 	charts := make([]*Chart, 0)
 
-	err := v.Visitor.Visit(func(c *Chart, err error) error {
+	err := v.Visitor.Visit(ctx, func(c *Chart, err error) error {
 		if err != nil {
 			return err
 		}
@@ -158,6 +164,10 @@ func (v *ReverseVisitor) Visit(fn VisitorFunc) error {
 	})
 
 	for i := len(charts) - 1; i >= 0; i-- {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
 		err = fn(charts[i], err)
 	}
 
@@ -1,6 +1,7 @@
 package chart
 
 import (
+	"context"
 	"sync"
 	"testing"
 
@@ -45,7 +46,7 @@ func TestVisitor_Visit(t *testing.T) {
 	v := NewVisitor(NewDefaultProcessor(), opts)
 	tv := &testVisitor{}
 
-	err := v.Visit(tv.Handle)
+	err := v.Visit(context.Background(), tv.Handle)
 
 	require.NoError(t, err)
 
@@ -63,7 +64,7 @@ func TestVisitor_VisitRecursive(t *testing.T) {
 	v := NewVisitor(NewDefaultProcessor(), opts)
 	tv := &testVisitor{}
 
-	err := v.Visit(tv.Handle)
+	err := v.Visit(context.Background(), tv.Handle)
 
 	require.NoError(t, err)
 
@@ -85,7 +86,7 @@ func TestVisitor_VisitChartFilter(t *testing.T) {
 	v := NewVisitor(NewDefaultProcessor(), opts)
 	tv := &testVisitor{}
 
-	err := v.Visit(tv.Handle)
+	err := v.Visit(context.Background(), tv.Handle)
 
 	require.NoError(t, err)
 
@@ -107,7 +108,7 @@ func TestReverseVisitor_Visit(t *testing.T) {
 
 	seenCharts := make([]string, 0)
 
-	err := v.Visit(func(c *Chart, err error) error {
+	err := v.Visit(context.Background(), func(c *Chart, err error) error {
 		require.NoError(t, err)
 
 		seenCharts = append(seenCharts, c.Config.Name)
@@ -119,3 +120,31 @@ func TestReverseVisitor_Visit(t *testing.T) {
 
 	assert.Equal(t, []string{"chart2", "chart1"}, seenCharts)
 }
+
+func TestVisitor_VisitCancelled(t *testing.T) {
+	opts := VisitorOptions{
+		ChartDir:  "testdata/valid-charts",
+		Namespace: "default",
+		Recursive: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v := NewVisitor(NewDefaultProcessor(), opts)
+
+	seenCharts := make([]string, 0)
+
+	err := v.Visit(ctx, func(c *Chart, err error) error {
+		require.NoError(t, err)
+
+		seenCharts = append(seenCharts, c.Config.Name)
+		cancel()
+
+		return nil
+	})
+
+	require.Equal(t, context.Canceled, err)
+
+	assert.Len(t, seenCharts, 1)
+}
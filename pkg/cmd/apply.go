@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"time"
@@ -12,15 +14,19 @@ import (
 	"github.com/martinohmann/kubectl-chart/pkg/recorders"
 	"github.com/martinohmann/kubectl-chart/pkg/resources"
 	"github.com/martinohmann/kubectl-chart/pkg/resources/statefulset"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
 	"github.com/martinohmann/kubectl-chart/pkg/yaml"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/api/meta"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	kprinters "k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
 	"k8s.io/kubectl/pkg/cmd/apply"
 	"k8s.io/kubectl/pkg/cmd/delete"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
@@ -33,6 +39,10 @@ var (
 	// ErrIllegalDryRunFlagCombination is returned if mutual exclusive dry run
 	// flags are set.
 	ErrIllegalDryRunFlagCombination = errors.Errorf("--dry-run and --server-dry-run can't be used together")
+
+	// ErrOutputRequiresDiff is returned if an output format is set without
+	// --diff.
+	ErrOutputRequiresDiff = errors.Errorf("--output can only be used together with --diff")
 )
 
 func NewApplyCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
@@ -57,23 +67,39 @@ func NewApplyCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.
 			kubectl chart apply -f ~/charts --recursive --chart-filter mychart
 
 			# Skip executing pre and post-apply hooks
-			kubectl chart apply -f ~/charts/mychart --no-hooks`),
+			kubectl chart apply -f ~/charts/mychart --no-hooks
+
+			# Run the chart tests after a successful apply
+			kubectl chart apply -f ~/charts/mychart --test
+
+			# Replace resources whose immutable fields changed
+			kubectl chart apply -f ~/charts/mychart --force-recreate`),
 		Args: cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
+			o.KubeContext = contextFlag(cmd)
 			cmdutil.CheckErr(o.Complete(f))
 			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+
+			ctx, stop := interruptContext(o.ErrOut)
+			defer stop()
+
+			cmdutil.CheckErr(o.Run(ctx))
 		},
 	}
 
 	o.ChartFlags.AddFlags(cmd)
 	o.HookFlags.AddFlags(cmd)
 	o.DiffFlags.AddFlags(cmd)
+	o.TestFlags.AddFlags(cmd)
 
 	cmd.Flags().BoolVar(&o.ServerDryRun, "server-dry-run", o.ServerDryRun, "If true, request will be sent to server with dry-run flag, which means the modifications won't be persisted. This is an alpha feature and flag.")
 	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the object that would be sent, without sending it. Warning: --dry-run cannot accurately output the result of merging the local manifest and the server-side data. Use --server-dry-run to get the merged result instead.")
 	cmd.Flags().BoolVar(&o.ShowDiff, "diff", o.ShowDiff, "If set, a diff for all resources will be displayed")
 	cmd.Flags().BoolVar(&o.Prune, "prune", o.Prune, "If true, chart resources not present anymore in the rendered chart manifest will be pruned by their chart label.")
+	cmd.Flags().BoolVar(&o.Wait, "wait", o.Wait, "If true, wait for workloads to be rolled out, jobs to complete and other resources to become ready before running post-apply hooks. Waiting is skipped during dry run.")
+	cmd.Flags().DurationVar(&o.WaitTimeout, "wait-timeout", o.WaitTimeout, "The maximum time to wait for the resources of a chart to become ready. Only has an effect if --wait is set.")
+	cmd.Flags().BoolVar(&o.Test, "test", o.Test, "If true, the chart tests will be run after the chart was applied successfully. Tests are skipped during dry run.")
+	cmd.Flags().BoolVar(&o.ForceRecreate, "force-recreate", o.ForceRecreate, "If true, resources that cannot be updated because of changes to immutable fields are deleted and created again. This can also be enabled per resource via the kubectl-chart/update-strategy annotation.")
 
 	return cmd
 }
@@ -85,36 +111,48 @@ type ApplyOptions struct {
 	ChartFlags    ChartFlags
 	HookFlags     HookFlags
 	DiffFlags     DiffFlags
+	TestFlags     TestFlags
 	DiffOptions   *DiffOptions
 	DeleteOptions *DeleteOptions
+	TestOptions   *TestOptions
 	DryRun        bool
 	ServerDryRun  bool
 	ShowDiff      bool
 	Prune         bool
+	Test          bool
+	Wait          bool
+	WaitTimeout   time.Duration
+	ForceRecreate bool
 
 	Printer         printers.ContextPrinter
 	Recorder        recorders.OperationRecorder
 	DynamicClient   dynamic.Interface
 	DiscoveryClient discovery.DiscoveryInterface
 	OpenAPISchema   openapi.Resources
-	Mapper          meta.RESTMapper
+	DryRunVerifier  DryRunVerifier
+	Mapper          kmeta.RESTMapper
 	Encoder         resources.Encoder
 	Visitor         chart.Visitor
 	HookExecutor    *chart.HookExecutor
 	Deleter         deletions.Deleter
 	PVCPruner       *statefulset.PersistentVolumeClaimPruner
+	PVCResizer      *statefulset.PersistentVolumeClaimResizer
+	Waiter          wait.Waiter
 
 	Namespace        string
 	EnforceNamespace bool
+	KubeContext      string
 }
 
 func NewApplyOptions(streams genericclioptions.IOStreams) *ApplyOptions {
 	return &ApplyOptions{
-		IOStreams: streams,
-		DiffFlags: NewDefaultDiffFlags(),
-		Recorder:  recorders.NewOperationRecorder(),
-		Encoder:   yaml.NewEncoder(),
-		Prune:     true,
+		IOStreams:   streams,
+		DiffFlags:   NewDefaultDiffFlags(),
+		TestFlags:   NewDefaultTestFlags(),
+		Recorder:    recorders.NewOperationRecorder(),
+		Encoder:     yaml.NewEncoder(),
+		Prune:       true,
+		WaitTimeout: 5 * time.Minute,
 	}
 }
 
@@ -123,6 +161,10 @@ func (o *ApplyOptions) Validate() error {
 		return ErrIllegalDryRunFlagCombination
 	}
 
+	if o.DiffFlags.Output != "" && !o.ShowDiff {
+		return ErrOutputRequiresDiff
+	}
+
 	return nil
 }
 
@@ -131,6 +173,14 @@ func (o *ApplyOptions) Complete(f cmdutil.Factory) error {
 
 	o.Factory = f
 
+	diffStreams := o.IOStreams
+
+	// Structured diffs are written to stdout so that they can be processed
+	// by other tools, everything else goes to stderr in this case.
+	if o.ShowDiff && o.DiffFlags.Output != "" {
+		o.Out = o.ErrOut
+	}
+
 	o.DiscoveryClient, err = f.ToDiscoveryClient()
 	if err != nil {
 		return err
@@ -151,11 +201,18 @@ func (o *ApplyOptions) Complete(f cmdutil.Factory) error {
 		return err
 	}
 
+	o.DryRunVerifier = newDryRunVerifier(o.DynamicClient, o.DiscoveryClient)
+
 	o.Namespace, o.EnforceNamespace, err = f.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
 		return err
 	}
 
+	o.KubeContext, err = currentContext(f, o.KubeContext)
+	if err != nil {
+		return err
+	}
+
 	o.Visitor, err = o.ChartFlags.ToVisitor(o.Namespace)
 	if err != nil {
 		return err
@@ -168,6 +225,7 @@ func (o *ApplyOptions) Complete(f cmdutil.Factory) error {
 		o.DynamicClient,
 		o.Printer.WithOperation("deleted"),
 		o.dryRun(),
+		deletions.Options{},
 	)
 
 	if !o.HookFlags.NoHooks {
@@ -176,8 +234,15 @@ func (o *ApplyOptions) Complete(f cmdutil.Factory) error {
 			o.DynamicClient,
 			o.Mapper,
 			o.Printer,
-			o.dryRun(),
+			o.DryRun,
+			o.ServerDryRun,
 		)
+		o.HookExecutor.KubeContext = o.KubeContext
+		o.HookExecutor.DeleteOnInterrupt = o.HookFlags.DeleteOnInterrupt
+	}
+
+	if o.Wait && !o.dryRun() {
+		o.Waiter = wait.NewWaiter(o.IOStreams, o.Printer.WithOperation("ready"))
 	}
 
 	o.PVCPruner = statefulset.NewPersistentVolumeClaimPruner(
@@ -186,7 +251,28 @@ func (o *ApplyOptions) Complete(f cmdutil.Factory) error {
 		o.Mapper,
 	)
 
+	o.PVCPruner.Snapshotter = statefulset.NewVolumeSnapshotter(
+		o.IOStreams,
+		o.DynamicClient,
+		o.Mapper,
+		o.Printer,
+		o.dryRun(),
+	)
+
+	if !o.dryRun() {
+		o.PVCPruner.Waiter = wait.NewSilentWaiter(o.IOStreams)
+	}
+
+	o.PVCResizer = statefulset.NewPersistentVolumeClaimResizer(
+		o.IOStreams,
+		o.DynamicClient,
+		o.Mapper,
+		o.Printer,
+		o.dryRun(),
+	)
+
 	o.DeleteOptions = &DeleteOptions{
+		IOStreams:     o.IOStreams,
 		DynamicClient: o.DynamicClient,
 		Mapper:        o.Mapper,
 		DryRun:        o.dryRun(),
@@ -202,22 +288,50 @@ func (o *ApplyOptions) Complete(f cmdutil.Factory) error {
 		),
 	}
 
+	if o.Test && o.dryRun() {
+		fmt.Fprintln(o.ErrOut, "warning: chart tests are skipped during dry run")
+	} else if o.Test {
+		o.TestOptions = &TestOptions{
+			IOStreams:   o.IOStreams,
+			TestFlags:   o.TestFlags,
+			KubeContext: o.KubeContext,
+		}
+
+		err = o.TestOptions.completeTestRunner(f, o.DynamicClient, o.Mapper, o.Printer)
+		if err != nil {
+			return err
+		}
+
+		o.TestOptions.TestRunner.HookExecutor.DeleteOnInterrupt = o.HookFlags.DeleteOnInterrupt
+	}
+
 	if !o.ShowDiff {
 		return nil
 	}
 
+	diffPrinter, err := o.DiffFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+
+	ignoreRules, err := o.DiffFlags.ToIgnoreRules()
+	if err != nil {
+		return err
+	}
+
 	o.DiffOptions = &DiffOptions{
-		Factory:       f,
-		IOStreams:     o.IOStreams,
-		OpenAPISchema: o.OpenAPISchema,
-		Namespace:     o.Namespace,
-		DiffPrinter:   o.DiffFlags.ToPrinter(),
-		Encoder:       o.Encoder,
-		Prune:         o.Prune,
-		DryRunVerifier: &apply.DryRunVerifier{
-			Finder:        cmdutil.NewCRDFinder(cmdutil.CRDFromDynamic(o.DynamicClient)),
-			OpenAPIGetter: o.DiscoveryClient,
-		},
+		Factory:        f,
+		IOStreams:      diffStreams,
+		DiffFlags:      o.DiffFlags,
+		OpenAPISchema:  o.OpenAPISchema,
+		Namespace:      o.Namespace,
+		DiffPrinter:    diffPrinter,
+		IgnoreRules:    ignoreRules,
+		Encoder:        o.Encoder,
+		Prune:          o.Prune,
+		DryRunVerifier: o.DryRunVerifier,
+		PVCResizer:     o.PVCResizer,
+		PVCPruner:      o.PVCPruner,
 	}
 
 	return nil
@@ -227,40 +341,235 @@ func (o *ApplyOptions) dryRun() bool {
 	return o.DryRun || o.ServerDryRun
 }
 
-func (o *ApplyOptions) Run() error {
-	err := o.Visitor.Visit(func(c *chart.Chart, err error) error {
+// Run applies all charts. If ctx is cancelled, no further charts are applied
+// and a summary of the completed charts is printed.
+func (o *ApplyOptions) Run(ctx context.Context) error {
+	defer o.HookExecutor.Wait()
+
+	tracker := &chartTracker{}
+
+	err := o.Visitor.Visit(ctx, func(c *chart.Chart, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if o.ShowDiff {
-			err = o.DiffOptions.Diff(c)
-			if err != nil {
-				return err
-			}
+		tracker.Start(c)
+
+		err = o.processChart(ctx, c)
+		if err != nil {
+			return err
 		}
 
-		if len(c.Resources) == 0 {
-			if !o.Prune {
-				return nil
-			}
+		tracker.Done()
+
+		return nil
+	})
 
-			return o.DeleteOptions.DeleteChart(c)
+	// Diffs of the charts processed so far are also written if applying
+	// fails.
+	if o.ShowDiff {
+		flushErr := o.DiffOptions.Flush()
+		if err == nil {
+			err = flushErr
 		}
+	}
 
-		return o.ApplyChart(c)
-	})
 	if err != nil {
-		return err
+		return handleInterrupt(ctx, o.ErrOut, tracker, err)
 	}
 
 	prunedObjs := o.Recorder.RecordedObjects("pruned")
 
-	return o.PVCPruner.PruneClaims(prunedObjs)
+	err = o.PVCPruner.PruneClaims(ctx, prunedObjs)
+	if err != nil || o.TestOptions == nil {
+		return handleInterrupt(ctx, o.ErrOut, tracker, err)
+	}
+
+	return o.TestOptions.Report()
 }
 
-func (o *ApplyOptions) ApplyChart(c *chart.Chart) error {
-	buf, err := o.Encoder.Encode(c.Resources)
+// processChart prints the diff for chart c if requested and applies it. If c
+// does not contain any resources, it is deleted instead.
+func (o *ApplyOptions) processChart(ctx context.Context, c *chart.Chart) error {
+	if o.ShowDiff {
+		_, err := o.DiffOptions.Diff(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(c.Resources) == 0 {
+		if !o.Prune {
+			return nil
+		}
+
+		return o.DeleteOptions.DeleteChart(ctx, c)
+	}
+
+	err := o.ApplyChart(ctx, c)
+	if err != nil || o.TestOptions == nil {
+		return err
+	}
+
+	return o.TestOptions.TestChart(ctx, c)
+}
+
+func (o *ApplyOptions) ApplyChart(ctx context.Context, c *chart.Chart) error {
+	err := o.HookExecutor.ExecHooks(ctx, c, hook.TypePreApply)
+	if err != nil {
+		return o.handleFailure(ctx, c, err)
+	}
+
+	// The applier cannot be cancelled, so we check for interrupts before
+	// starting it.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	resizes, err := o.resizeClaims(ctx, c)
+	if err != nil {
+		return o.handleFailure(ctx, c, err)
+	}
+
+	err = o.applyResources(ctx, c, resizes)
+	if err != nil {
+		// StatefulSets that were deleted for recreation by the resize must
+		// not stay deleted if the applier did not create them again.
+		if restoreErr := o.PVCResizer.Restore(resizes); restoreErr != nil {
+			err = utilerrors.NewAggregate([]error{err, restoreErr})
+		}
+
+		return o.handleFailure(ctx, c, err)
+	}
+
+	err = o.waitForReadiness(ctx, c)
+	if err != nil {
+		return o.handleFailure(ctx, c, err)
+	}
+
+	// Claims of removed StatefulSet replicas are only pruned once the
+	// scale-down was applied and their pods are gone.
+	err = o.PVCPruner.PruneScaledDownClaims(ctx, c.Resources, o.Namespace)
+	if err != nil {
+		return o.handleFailure(ctx, c, err)
+	}
+
+	return o.HookExecutor.ExecHooks(ctx, c, hook.TypePostApply)
+}
+
+// applyResources applies the resources of chart c. If applying fails because
+// of changes to immutable fields, the affected resources are replaced if
+// their update strategy allows it and the applier is run again to create
+// them. In dry run mode, the replacements are only printed. StatefulSets
+// contained in resizes are expected to be deleted already.
+func (o *ApplyOptions) applyResources(ctx context.Context, c *chart.Chart, resizes []*statefulset.Resize) error {
+	if o.DryRun {
+		return o.dryRunResources(ctx, c, resizes)
+	}
+
+	err := o.runApplier(c, c.Resources, o.toPrinter(nil, false))
+
+	failures, ok := immutableFieldFailures(err)
+	if !ok || len(failures) == 0 {
+		return err
+	}
+
+	replacements, err := o.findReplacements(c, failures, resizes)
+	if err != nil {
+		return err
+	}
+
+	if len(replacements) == 0 {
+		return immutableFieldErrorHint(failures[0].(error))
+	}
+
+	err = o.deleteReplacements(ctx, replacements)
+	if err != nil {
+		return err
+	}
+
+	objs := c.Resources
+
+	// The replaced resources still exist in dry run mode, so the applier
+	// would fail again if they were passed to it.
+	if o.dryRun() {
+		objs = withoutReplacements(c.Resources, replacements)
+
+		err = o.printReplacements(c, replacements)
+		if err != nil || len(objs) == 0 {
+			return err
+		}
+	}
+
+	// All resources are passed to the applier again so that pruning works
+	// as expected. Only the replaced and pruned resources are printed as
+	// the others were already printed during the first run.
+	err = o.runApplier(c, objs, o.toPrinter(replacements, true))
+	if isImmutableFieldError(err) {
+		return immutableFieldErrorHint(err)
+	}
+
+	return err
+}
+
+// dryRunResources applies the resources of chart c in client dry run mode.
+// Changes to immutable fields are only detected by the API server, so the
+// resources that would be replaced are predicted using a server dry run
+// beforehand.
+func (o *ApplyOptions) dryRunResources(ctx context.Context, c *chart.Chart, resizes []*statefulset.Resize) error {
+	replacements, err := o.predictReplacements(c, resizes)
+	if err != nil {
+		return err
+	}
+
+	err = o.runApplier(c, withoutReplacements(c.Resources, replacements), o.toPrinter(replacements, false))
+	if err != nil || len(replacements) == 0 {
+		return err
+	}
+
+	err = o.deleteReplacements(ctx, replacements)
+	if err != nil {
+		return err
+	}
+
+	return o.printReplacements(c, replacements)
+}
+
+// predictReplacements performs a silent server dry run of the resources of
+// chart c and returns the resources that would be replaced because of changes
+// to immutable fields. If the server dry run fails for other reasons, e.g.
+// because the cluster does not support it, no replacements are predicted.
+func (o *ApplyOptions) predictReplacements(c *chart.Chart, resizes []*statefulset.Resize) ([]*resource.Info, error) {
+	serverDryRun := *o
+	serverDryRun.DryRun = false
+	serverDryRun.ServerDryRun = true
+	serverDryRun.Prune = false
+
+	err := serverDryRun.runApplier(c, c.Resources, func(string) (kprinters.ResourcePrinter, error) {
+		return kprinters.NewDiscardingPrinter(), nil
+	})
+
+	failures, ok := immutableFieldFailures(err)
+	if !ok {
+		klog.V(1).Infof("skipping prediction of replacements: %v", err)
+		return nil, nil
+	}
+
+	if len(failures) == 0 {
+		return nil, nil
+	}
+
+	replacements, err := o.findReplacements(c, failures, resizes)
+	if err != nil || len(replacements) > 0 {
+		return replacements, err
+	}
+
+	return nil, immutableFieldErrorHint(failures[0].(error))
+}
+
+// runApplier applies objs using printer to print the applied objects.
+func (o *ApplyOptions) runApplier(c *chart.Chart, objs []runtime.Object, toPrinter func(string) (kprinters.ResourcePrinter, error)) error {
+	buf, err := o.Encoder.Encode(objs)
 	if err != nil {
 		return err
 	}
@@ -282,22 +591,95 @@ func (o *ApplyOptions) ApplyChart(c *chart.Chart) error {
 
 	defer os.Remove(f.Name())
 
-	err = o.HookExecutor.ExecHooks(c, hook.TypePreApply)
+	return o.createApplier(c, f.Name(), toPrinter).Run()
+}
+
+// resizeClaims grows the PersistentVolumeClaims of StatefulSets in chart c
+// whose volumeClaimTemplates request more storage than before. The affected
+// StatefulSets are deleted with orphan propagation so that the applier can
+// recreate them with the new volumeClaimTemplates. ApplyChart restores them
+// if applying fails. The performed resizes are returned.
+func (o *ApplyOptions) resizeClaims(ctx context.Context, c *chart.Chart) ([]*statefulset.Resize, error) {
+	resizes, err := o.PVCResizer.FindResizes(c.Resources, o.Namespace)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	applier := o.createApplier(c, f.Name())
+	return resizes, o.PVCResizer.Resize(ctx, resizes)
+}
+
+// waitForReadiness waits until all resources of chart c are ready. Resources
+// with the kubectl-chart/wait-for annotation are waited on using their custom
+// condition. It is a no-op if waiting was not requested.
+func (o *ApplyOptions) waitForReadiness(ctx context.Context, c *chart.Chart) error {
+	if o.Waiter == nil {
+		return nil
+	}
 
-	err = applier.Run()
+	infos, err := resources.ToInfoList(c.Resources, o.Mapper)
 	if err != nil {
 		return err
 	}
 
-	return o.HookExecutor.ExecHooks(c, hook.TypePostApply)
+	readinessInfos := make([]*resource.Info, 0, len(infos))
+	timeout := o.WaitTimeout
+
+	for _, info := range infos {
+		customCondition := wait.HasCustomCondition(info.Object)
+
+		if !wait.SupportsReadiness(info.Mapping.GroupVersionKind.GroupKind()) && !customCondition {
+			continue
+		}
+
+		// The kubectl-chart/wait-timeout annotation may extend the overall
+		// deadline for resources with a custom wait condition. Malformed
+		// annotations are reported by the condition func.
+		if customCondition && timeout > 0 {
+			customTimeout, ok, err := wait.CustomTimeout(info.Object)
+			if err == nil && ok && customTimeout > timeout {
+				timeout = customTimeout
+			}
+		}
+
+		if info.Namespace == "" && info.Mapping.Scope.Name() == kmeta.RESTScopeNameNamespace {
+			info.Namespace = o.Namespace
+		}
+
+		readinessInfos = append(readinessInfos, info)
+	}
+
+	if len(readinessInfos) == 0 {
+		return nil
+	}
+
+	return o.Waiter.Wait(ctx, &wait.Request{
+		ConditionFn: wait.NewCustomConditionFunc(
+			o.DynamicClient,
+			o.ErrOut,
+			wait.NewReadinessConditionFunc(o.DynamicClient, o.ErrOut),
+		),
+		Options: &wait.Options{Timeout: o.WaitTimeout},
+		Visitor: resource.InfoListVisitor(readinessInfos),
+		Timeout: timeout,
+	})
+}
+
+// handleFailure executes the apply-failed hooks of chart c and returns the
+// original error cause. Failure hooks are not executed if ctx was cancelled.
+func (o *ApplyOptions) handleFailure(ctx context.Context, c *chart.Chart, cause error) error {
+	if ctx.Err() != nil {
+		return cause
+	}
+
+	err := o.HookExecutor.ExecFailureHooks(ctx, c, hook.TypeApplyFailed, cause)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "error executing %s hooks: %v\n", hook.TypeApplyFailed, err)
+	}
+
+	return cause
 }
 
-func (o *ApplyOptions) createApplier(c *chart.Chart, filename string) *apply.ApplyOptions {
+func (o *ApplyOptions) createApplier(c *chart.Chart, filename string, toPrinter func(string) (kprinters.ResourcePrinter, error)) *apply.ApplyOptions {
 	return &apply.ApplyOptions{
 		IOStreams:    o.IOStreams,
 		DryRun:       o.DryRun,
@@ -319,19 +701,60 @@ func (o *ApplyOptions) createApplier(c *chart.Chart, filename string) *apply.App
 		Validator:        validation.NullSchema{},
 		Builder:          o.NewBuilder(),
 		DiscoveryClient:  o.DiscoveryClient,
-		DynamicClient:    o.DynamicClient,
+		DynamicClient:    deletions.NewPolicyClient(o.DynamicClient),
 		OpenAPISchema:    o.OpenAPISchema,
 		Mapper:           o.Mapper,
 		Namespace:        o.Namespace,
 		EnforceNamespace: o.EnforceNamespace,
-		ToPrinter: func(operation string) (kprinters.ResourcePrinter, error) {
-			p := o.Printer.WithOperation(operation)
-
-			// Wrap the printer to keep track of the executed operations for
-			// each object. We need that later on to perform additonal tasks.
-			// Sadly, we have to do this for now to avoid duplicating most of
-			// the logic of apply.ApplyOptions.
-			return printers.NewRecordingPrinter(o.Recorder, operation, p), nil
-		},
+		ToPrinter:        toPrinter,
+	}
+}
+
+// toPrinter returns a func that creates the printer for an operation. Pruned
+// resources may be retained or use a non-default propagation policy which is
+// made visible here. Resources contained in replacements are printed with the
+// replaced operation, except in dry run mode where they are printed
+// separately. If quiet is true, only pruned and replaced resources are
+// printed.
+func (o *ApplyOptions) toPrinter(replacements []*resource.Info, quiet bool) func(string) (kprinters.ResourcePrinter, error) {
+	return func(operation string) (kprinters.ResourcePrinter, error) {
+		var p printers.ResourcePrinter
+
+		switch {
+		case operation == "pruned":
+			p = deletions.NewPolicyPrinter(operation, o.recordingPrinter)
+		case quiet:
+			p = kprinters.NewDiscardingPrinter()
+		default:
+			p = o.recordingPrinter(operation)
+		}
+
+		if len(replacements) == 0 {
+			return p, nil
+		}
+
+		var replaced printers.ResourcePrinter = kprinters.NewDiscardingPrinter()
+		if !o.dryRun() {
+			replaced = o.recordingPrinter("replaced")
+		}
+
+		return &replacementPrinter{
+			Replacements: replacements,
+			Printer:      replaced,
+			Delegate:     p,
+		}, nil
+	}
+}
+
+// recordingPrinter returns a printer for operation with optional context.
+// The printer keeps track of the executed operations for each object. We need
+// that later on to perform additonal tasks. Sadly, we have to do this for now
+// to avoid duplicating most of the logic of apply.ApplyOptions.
+func (o *ApplyOptions) recordingPrinter(operation string, context ...string) printers.ResourcePrinter {
+	p := o.Printer.WithOperation(operation)
+	if len(context) > 0 {
+		p = p.WithContext(context...)
 	}
+
+	return printers.NewRecordingPrinter(o.Recorder, operation, p)
 }
@@ -1,14 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"net/http"
 	"testing"
+	"time"
 
+	openapi_v2 "github.com/googleapis/gnostic/OpenAPIv2"
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/discovery"
@@ -23,19 +33,29 @@ var codec = scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups
 
 type fakeCachedDiscovery struct {
 	*fakediscovery.FakeDiscovery
+	OpenAPIDocument *openapi_v2.Document
 }
 
 func (f *fakeCachedDiscovery) Fresh() bool { return false }
 func (f *fakeCachedDiscovery) Invalidate() {}
 
+func (f *fakeCachedDiscovery) OpenAPISchema() (*openapi_v2.Document, error) {
+	if f.OpenAPIDocument != nil {
+		return f.OpenAPIDocument, nil
+	}
+
+	return f.FakeDiscovery.OpenAPISchema()
+}
+
 type testFactoryWithFakeDiscovery struct {
 	*cmdtesting.TestFactory
-	FakeDiscovery *fakediscovery.FakeDiscovery
+	FakeDiscovery   *fakediscovery.FakeDiscovery
+	OpenAPIDocument *openapi_v2.Document
 }
 
 func (f *testFactoryWithFakeDiscovery) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
 	if f.FakeDiscovery != nil {
-		return &fakeCachedDiscovery{f.FakeDiscovery}, nil
+		return &fakeCachedDiscovery{f.FakeDiscovery, f.OpenAPIDocument}, nil
 	}
 
 	return f.TestFactory.ToDiscoveryClient()
@@ -105,15 +125,19 @@ func TestApplyCmd(t *testing.T) {
 	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
 	defer f.Cleanup()
 
-	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+	streams, _, buf, errBuf := genericclioptions.NewTestIOStreams()
 
 	o := NewApplyOptions(streams)
 
 	o.DryRun = true
+	o.Test = true
 	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
 
 	require.NoError(t, o.Complete(f))
-	require.NoError(t, o.Run())
+	require.NoError(t, o.Run(context.Background()))
+
+	assert.Nil(t, o.TestOptions)
+	assert.Equal(t, "warning: chart tests are skipped during dry run\n", errBuf.String())
 
 	expected := `service/chart1 configured (dry run)
 statefulset.apps/chart1 created (dry run)
@@ -123,11 +147,150 @@ job.batch/chart1 triggered (dry run)
 	assert.Equal(t, expected, buf.String())
 }
 
+func TestApplyCmd_ApplyChart_RestoresResizedStatefulSetOnFailure(t *testing.T) {
+	cmdtesting.InitTestErrorHandler(t)
+
+	newStatefulSet := func(storage string) *unstructured.Unstructured {
+		obj := newUnstructured("apps/v1", "StatefulSet", "test", "db")
+		obj.Object["spec"] = map[string]interface{}{
+			"volumeClaimTemplates": []interface{}{
+				map[string]interface{}{
+					"metadata": map[string]interface{}{"name": "data"},
+					"spec": map[string]interface{}{
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{"storage": storage},
+						},
+					},
+				},
+			},
+		}
+
+		return obj
+	}
+
+	claim := newUnstructuredWithLabels("v1", "PersistentVolumeClaim", "test", "data-db-0", map[string]interface{}{meta.LabelOwnedByStatefulSet: "db"})
+	claim.Object["spec"] = map[string]interface{}{
+		"storageClassName": "standard",
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"storage": "1Gi"},
+		},
+	}
+
+	storageClass := newUnstructured("storage.k8s.io/v1", "StorageClass", "", "standard")
+	storageClass.Object["allowVolumeExpansion"] = true
+
+	f := newTestFactoryWithFakeDiscovery(nil)
+	f.UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch p, m := req.URL.Path, req.Method; {
+			case p == "/namespaces/test/statefulsets/db" && m == "GET":
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Header:     cmdtesting.DefaultHeader(),
+					Body:       cmdtesting.StringBody("{}"),
+				}, nil
+			case p == "/namespaces/test/statefulsets" && m == "POST":
+				return &http.Response{
+					StatusCode: http.StatusInternalServerError,
+					Header:     cmdtesting.DefaultHeader(),
+					Body:       cmdtesting.StringBody("{}"),
+				}, nil
+			default:
+				t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
+				return nil, nil
+			}
+		}),
+	}
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	f.FakeDynamicClient.PrependReactor("get", "statefulsets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, newStatefulSet("1Gi"), nil
+	})
+	f.FakeDynamicClient.PrependReactor("list", "persistentvolumeclaims", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, newUnstructuredList(claim), nil
+	})
+	f.FakeDynamicClient.PrependReactor("get", "storageclasses", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, storageClass, nil
+	})
+	f.FakeDynamicClient.PrependReactor("patch", "persistentvolumeclaims", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, claim, nil
+	})
+	f.FakeDynamicClient.PrependReactor("delete", "statefulsets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	defer f.Cleanup()
+
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+	o := NewApplyOptions(streams)
+
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+
+	require.NoError(t, o.Complete(f))
+
+	o.PVCResizer.Waiter = wait.NewFakeWaiter()
+
+	c := &chart.Chart{
+		Config:    &chart.Config{Name: "chart1"},
+		Resources: []runtime.Object{newStatefulSet("2Gi")},
+	}
+
+	require.Error(t, o.ApplyChart(context.Background(), c))
+
+	var creates []clienttesting.CreateAction
+
+	for _, action := range f.FakeDynamicClient.Actions() {
+		if action.Matches("create", "statefulsets") {
+			creates = append(creates, action.(clienttesting.CreateAction))
+		}
+	}
+
+	require.Len(t, creates, 1)
+
+	restored := creates[0].GetObject().(*unstructured.Unstructured)
+
+	vcts, _, _ := unstructured.NestedSlice(restored.Object, "spec", "volumeClaimTemplates")
+	require.Len(t, vcts, 1)
+
+	storage, _, _ := unstructured.NestedString(vcts[0].(map[string]interface{}), "spec", "resources", "requests", "storage")
+
+	assert.Equal(t, "db", restored.GetName())
+	assert.Equal(t, "1Gi", storage)
+
+	expected := `persistentvolumeclaim/data-db-0 resized (1Gi -> 2Gi)
+statefulset.apps/db deleted (propagation=orphan)
+statefulset.apps/db restored
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestApplyCmd_Complete_StructuredDiff(t *testing.T) {
+	f := newTestFactoryWithFakeDiscovery(nil)
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	defer f.Cleanup()
+
+	streams, _, buf, errBuf := genericclioptions.NewTestIOStreams()
+
+	o := NewApplyOptions(streams)
+
+	o.ShowDiff = true
+	o.DiffFlags.Output = "json"
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+
+	require.NoError(t, o.Complete(f))
+
+	assert.True(t, o.Out == errBuf, "expected applier output to be written to stderr")
+	assert.True(t, o.DiffOptions.Out == buf, "expected diff output to be written to stdout")
+}
+
 func TestApplyCmd_Validate(t *testing.T) {
 	tests := []struct {
 		name         string
 		dryRun       bool
 		serverDryRun bool
+		showDiff     bool
+		output       string
 		expectedErr  string
 	}{
 		{
@@ -147,6 +310,16 @@ func TestApplyCmd_Validate(t *testing.T) {
 			name:   "dry run flag set",
 			dryRun: true,
 		},
+		{
+			name:        "output without diff",
+			output:      "json",
+			expectedErr: ErrOutputRequiresDiff.Error(),
+		},
+		{
+			name:     "output with diff",
+			showDiff: true,
+			output:   "yaml",
+		},
 	}
 
 	for _, test := range tests {
@@ -155,6 +328,8 @@ func TestApplyCmd_Validate(t *testing.T) {
 
 			o.DryRun = test.dryRun
 			o.ServerDryRun = test.serverDryRun
+			o.ShowDiff = test.showDiff
+			o.DiffFlags.Output = test.output
 
 			err := o.Validate()
 
@@ -167,3 +342,107 @@ func TestApplyCmd_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyCmd_waitForReadiness(t *testing.T) {
+	waiter := wait.NewFakeWaiter()
+
+	o := NewApplyOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.Namespace = "test"
+	o.Mapper = testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)
+	o.Waiter = waiter
+	o.WaitTimeout = time.Minute
+
+	c := &chart.Chart{
+		Config: &chart.Config{Name: "chart1"},
+		Resources: []runtime.Object{
+			newUnstructured("v1", "Service", "", "chart1"),
+			newUnstructured("apps/v1", "StatefulSet", "", "chart1"),
+			newUnstructured("apps/v1", "Deployment", "bar", "chart1"),
+		},
+	}
+
+	require.NoError(t, o.waitForReadiness(context.Background(), c))
+	require.Len(t, waiter.Requests, 1)
+
+	req := waiter.Requests[0]
+
+	assert.Equal(t, time.Minute, req.Options.Timeout)
+	assert.Equal(t, time.Minute, req.Timeout)
+
+	var names []string
+
+	req.Visitor.Visit(func(info *resource.Info, err error) error {
+		names = append(names, info.Namespace+"/"+info.Mapping.GroupVersionKind.Kind)
+		return nil
+	})
+
+	assert.Equal(t, []string{"test/StatefulSet", "bar/Deployment"}, names)
+}
+
+func TestApplyCmd_waitForReadiness_CustomTimeout(t *testing.T) {
+	waiter := wait.NewFakeWaiter()
+
+	o := NewApplyOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.Namespace = "test"
+	o.Mapper = testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)
+	o.Waiter = waiter
+	o.WaitTimeout = time.Minute
+
+	configMap := newUnstructured("v1", "ConfigMap", "", "chart1")
+	configMap.SetAnnotations(map[string]string{
+		meta.AnnotationWaitFor:     "jsonpath={.data.ready}=true",
+		meta.AnnotationWaitTimeout: "5m",
+	})
+
+	c := &chart.Chart{
+		Config: &chart.Config{Name: "chart1"},
+		Resources: []runtime.Object{
+			newUnstructured("apps/v1", "Deployment", "bar", "chart1"),
+			configMap,
+		},
+	}
+
+	require.NoError(t, o.waitForReadiness(context.Background(), c))
+	require.Len(t, waiter.Requests, 1)
+
+	req := waiter.Requests[0]
+
+	assert.Equal(t, time.Minute, req.Options.Timeout)
+	assert.Equal(t, 5*time.Minute, req.Timeout)
+}
+
+func TestApplyCmd_waitForReadiness_Disabled(t *testing.T) {
+	o := NewApplyOptions(genericclioptions.NewTestIOStreamsDiscard())
+
+	c := &chart.Chart{
+		Config:    &chart.Config{Name: "chart1"},
+		Resources: []runtime.Object{newUnstructured("apps/v1", "Deployment", "bar", "chart1")},
+	}
+
+	require.NoError(t, o.waitForReadiness(context.Background(), c))
+}
+
+func TestApplyCmd_toPrinter(t *testing.T) {
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+	o := NewApplyOptions(streams)
+	o.Printer = printers.NewContextPrinter(false, false)
+
+	retained := newUnstructured("apps/v1", "StatefulSet", "bar", "retained")
+	retained.SetAnnotations(map[string]string{"kubectl-chart/deletion-policy": "retain"})
+
+	pruned := newUnstructured("apps/v1", "StatefulSet", "bar", "pruned")
+
+	p, err := o.toPrinter(nil, false)("pruned")
+	require.NoError(t, err)
+
+	require.NoError(t, p.PrintObj(retained, buf))
+	require.NoError(t, p.PrintObj(pruned, buf))
+
+	assert.Equal(t, "statefulset.apps/retained retained\nstatefulset.apps/pruned pruned\n", buf.String())
+
+	// Retained resources must not be recorded as pruned, otherwise the
+	// PersistentVolumeClaims of retained StatefulSets could get pruned.
+	assert.Equal(t, []runtime.Object{pruned}, o.Recorder.RecordedObjects("pruned"))
+	assert.Equal(t, []runtime.Object{retained}, o.Recorder.RecordedObjects("retained"))
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotConfirmed is returned if the user did not confirm an action.
+var ErrNotConfirmed = errors.New("aborted by user")
+
+// ErrConfirmationRequired is returned if an action requires confirmation but
+// stdin is not a terminal.
+var ErrConfirmationRequired = errors.New("confirmation required but stdin is not a terminal, pass --yes to proceed without confirmation")
+
+// ErrForceFinalizeConfirmationRequired is returned if finalizers should be
+// removed but stdin is not a terminal and confirmation was not skipped.
+var ErrForceFinalizeConfirmationRequired = errors.New("--force-finalize requires confirmation but stdin is not a terminal, pass --yes to proceed without confirmation")
+
+// confirm prints prompt to w and reads the answer from r. It returns
+// ErrNotConfirmed unless the user answered with "y" or "yes".
+func confirm(r io.Reader, w io.Writer, prompt string) error {
+	fmt.Fprintf(w, "%s [y/N]: ", prompt)
+
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return ErrNotConfirmed
+	}
+}
+
+// confirmContext behaves like confirm but returns ctx.Err() if ctx is
+// cancelled while waiting for the answer.
+func confirmContext(ctx context.Context, r io.Reader, w io.Writer, prompt string) error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- confirm(r, w, prompt)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		fmt.Fprintln(w)
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr error
+	}{
+		{name: "yes", input: "yes\n"},
+		{name: "y", input: "Y\n"},
+		{name: "y without newline", input: "y"},
+		{name: "no", input: "n\n", expectedErr: ErrNotConfirmed},
+		{name: "empty", input: "\n", expectedErr: ErrNotConfirmed},
+		{name: "eof", input: "", expectedErr: ErrNotConfirmed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := confirm(strings.NewReader(test.input), &buf, "Continue?")
+
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, "Continue? [y/N]: ", buf.String())
+		})
+	}
+}
+
+func TestConfirmContext_Cancelled(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := confirmContext(ctx, r, ioutil.Discard, "Continue?")
+
+	assert.Equal(t, context.Canceled, err)
+}
@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/martinohmann/kubectl-chart/pkg/chart"
 	"github.com/martinohmann/kubectl-chart/pkg/deletions"
 	"github.com/martinohmann/kubectl-chart/pkg/hook"
 	"github.com/martinohmann/kubectl-chart/pkg/resources"
 	"github.com/martinohmann/kubectl-chart/pkg/resources/statefulset"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -13,6 +18,7 @@ import (
 	"k8s.io/client-go/dynamic"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/kubectl/pkg/util/term"
 )
 
 func NewDeleteCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
@@ -22,7 +28,15 @@ func NewDeleteCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra
 		Use:   "delete",
 		Short: "Delete resources from one or multiple helm charts",
 		Long: templates.LongDesc(`
-			Deletes resources of one or multiple helm charts from a cluster.`),
+			Deletes resources of one or multiple helm charts from a cluster.
+
+			Before anything is deleted, the resources, hooks and PersistentVolumeClaims
+			affected by the deletion are printed and confirmation is requested if stdin
+			is a terminal. Pass --yes to skip the confirmation or --require-confirmation
+			to fail if it cannot be requested. Removing finalizers via --force-finalize
+			always requires confirmation or --yes. Deletion of resources whose kind is
+			listed in --protected-kinds (Namespace and CustomResourceDefinition by
+			default) is refused.`),
 		Example: templates.Examples(`
 			# Delete resources of a single chart
 			kubectl chart delete -f ~/charts/mychart
@@ -30,15 +44,33 @@ func NewDeleteCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra
 			# Delete resources of multiple charts
 			kubectl chart delete -f ~/charts --recursive
 
+			# Delete resources without asking for confirmation
+			kubectl chart delete -f ~/charts/mychart --yes
+
+			# Allow deletion of namespaces
+			kubectl chart delete -f ~/charts/mychart --protected-kinds CustomResourceDefinition
+
 			# Dry run resource deletion
 			kubectl chart delete -f ~/charts/mychart --dry-run
 
 			# Skip executing pre and post-delete hooks
-			kubectl chart delete -f ~/charts/mychart --no-hooks`),
+			kubectl chart delete -f ~/charts/mychart --no-hooks
+
+			# Orphan dependents of deleted resources
+			kubectl chart delete -f ~/charts/mychart --cascade orphan
+
+			# Remove finalizers of resources that are stuck in deletion for more than 5 minutes
+			kubectl chart delete -f ~/charts/mychart --force-finalize --force-finalize-after 5m`),
 		Args: cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
+			o.KubeContext = contextFlag(cmd)
+			cmdutil.CheckErr(o.Validate())
 			cmdutil.CheckErr(o.Complete(f))
-			cmdutil.CheckErr(o.Run())
+
+			ctx, stop := interruptContext(o.ErrOut)
+			defer stop()
+
+			cmdutil.CheckErr(o.Run(ctx))
 		},
 	}
 
@@ -47,7 +79,14 @@ func NewDeleteCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra
 	o.PrintFlags.AddFlags(cmd)
 
 	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the object that would be sent, without sending it. Warning: --dry-run cannot accurately output the result of merging the local manifest and the server-side data. Use --server-dry-run to get the merged result instead.")
+	cmd.Flags().BoolVar(&o.ServerDryRun, "server-dry-run", o.ServerDryRun, "If true, request will be sent to server with dry-run flag, which means the modifications won't be persisted. This is an alpha feature and flag.")
 	cmd.Flags().BoolVar(&o.Prune, "prune", o.Prune, "If true, chart resources will be pruned by their chart label. This also removes resources not present in the chart anymore")
+	cmd.Flags().BoolVar(&o.Yes, "yes", o.Yes, "If true, resources are deleted without asking for confirmation")
+	cmd.Flags().BoolVar(&o.RequireConfirmation, "require-confirmation", o.RequireConfirmation, "If true, deletion fails if stdin is not a terminal and --yes is not set instead of proceeding without confirmation")
+	cmd.Flags().StringSliceVar(&o.ProtectedKinds, "protected-kinds", o.ProtectedKinds, "Kinds of resources that must not be deleted. If the deletion would remove any resource of these kinds, it is refused. Pass an empty value to allow the deletion of all kinds")
+	cmd.Flags().BoolVar(&o.ForceFinalize, "force-finalize", o.ForceFinalize, "If true, finalizers of resources that are stuck in deletion are removed after the grace period set via --force-finalize-after")
+	cmd.Flags().DurationVar(&o.ForceFinalizeAfter, "force-finalize-after", o.ForceFinalizeAfter, "The time a resource has to be terminating before its finalizers are removed. Only has an effect if --force-finalize is set")
+	cmd.Flags().StringVar(&o.Cascade, "cascade", o.Cascade, "The propagation policy for dependents of deleted resources. Must be one of background, foreground or orphan. Can be overridden per resource via the kubectl-chart/propagation-policy annotation")
 
 	return cmd
 }
@@ -55,11 +94,21 @@ func NewDeleteCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra
 type DeleteOptions struct {
 	genericclioptions.IOStreams
 
-	ChartFlags ChartFlags
-	HookFlags  HookFlags
-	PrintFlags PrintFlags
-	DryRun     bool
-	Prune      bool
+	ChartFlags   ChartFlags
+	HookFlags    HookFlags
+	PrintFlags   PrintFlags
+	DryRun       bool
+	ServerDryRun bool
+	Prune        bool
+	Cascade      string
+
+	Yes                 bool
+	RequireConfirmation bool
+	Interactive         bool
+	ProtectedKinds      []string
+
+	ForceFinalize      bool
+	ForceFinalizeAfter time.Duration
 
 	DynamicClient  dynamic.Interface
 	Mapper         meta.RESTMapper
@@ -69,23 +118,42 @@ type DeleteOptions struct {
 	ResourceFinder *resources.Finder
 	PVCPruner      *statefulset.PersistentVolumeClaimPruner
 
-	Namespace string
+	Namespace   string
+	KubeContext string
 }
 
 func NewDeleteOptions(streams genericclioptions.IOStreams) *DeleteOptions {
 	return &DeleteOptions{
-		IOStreams: streams,
+		IOStreams:          streams,
+		Cascade:            "background",
+		ProtectedKinds:     DefaultProtectedKinds,
+		ForceFinalizeAfter: time.Minute,
+	}
+}
+
+func (o *DeleteOptions) Validate() error {
+	if o.DryRun && o.ServerDryRun {
+		return ErrIllegalDryRunFlagCombination
 	}
+
+	return nil
 }
 
 func (o *DeleteOptions) Complete(f cmdutil.Factory) error {
 	var err error
 
+	o.Interactive = term.IsTerminal(o.In)
+
 	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
 		return err
 	}
 
+	o.KubeContext, err = currentContext(f, o.KubeContext)
+	if err != nil {
+		return err
+	}
+
 	o.DynamicClient, err = f.DynamicClient()
 	if err != nil {
 		return err
@@ -105,9 +173,24 @@ func (o *DeleteOptions) Complete(f cmdutil.Factory) error {
 		o.ResourceFinder = resources.NewFinder(discoveryClient, o.DynamicClient, o.Mapper)
 	}
 
-	p := o.PrintFlags.ToPrinter(o.DryRun)
+	policy, err := deletions.ParsePropagationPolicy(o.Cascade)
+	if err != nil {
+		return err
+	}
+
+	p := o.PrintFlags.ToPrinter(o.dryRun())
+
+	options := deletions.Options{PropagationPolicy: policy}
 
-	o.Deleter = deletions.NewDeleter(o.IOStreams, o.DynamicClient, p, o.DryRun)
+	if o.ForceFinalize && !o.dryRun() {
+		options.ForceFinalizeAfter = o.ForceFinalizeAfter
+	}
+
+	if o.ServerDryRun {
+		o.Deleter = deletions.NewServerDryRunDeleter(o.IOStreams, o.DynamicClient, p, options)
+	} else {
+		o.Deleter = deletions.NewDeleter(o.IOStreams, o.DynamicClient, p, o.DryRun, options)
+	}
 
 	if !o.HookFlags.NoHooks {
 		o.HookExecutor = chart.NewHookExecutor(
@@ -116,7 +199,10 @@ func (o *DeleteOptions) Complete(f cmdutil.Factory) error {
 			o.Mapper,
 			p,
 			o.DryRun,
+			o.ServerDryRun,
 		)
+		o.HookExecutor.KubeContext = o.KubeContext
+		o.HookExecutor.DeleteOnInterrupt = o.HookFlags.DeleteOnInterrupt
 	}
 
 	visitor, err := o.ChartFlags.ToVisitor(o.Namespace)
@@ -131,52 +217,203 @@ func (o *DeleteOptions) Complete(f cmdutil.Factory) error {
 		o.Deleter,
 		o.Mapper,
 	)
+	o.PVCPruner.Snapshotter = statefulset.NewVolumeSnapshotter(
+		o.IOStreams,
+		o.DynamicClient,
+		o.Mapper,
+		p,
+		o.dryRun(),
+	)
 
 	return err
 }
 
-func (o *DeleteOptions) Run() error {
-	return o.Visitor.Visit(func(c *chart.Chart, err error) error {
+func (o *DeleteOptions) dryRun() bool {
+	return o.DryRun || o.ServerDryRun
+}
+
+// Run deletes all charts. Before deleting anything, the deletion plan is
+// printed and confirmation is requested unless o.Yes or dry run is enabled. If
+// ctx is cancelled, no further charts are deleted and a summary of the
+// completed charts is printed.
+func (o *DeleteOptions) Run(ctx context.Context) error {
+	defer o.HookExecutor.Wait()
+
+	tracker := &chartTracker{}
+
+	plan, err := o.Plan(ctx)
+	if err != nil {
+		return handleInterrupt(ctx, o.ErrOut, tracker, err)
+	}
+
+	err = o.confirmPlan(ctx, plan)
+	if err != nil {
+		return handleInterrupt(ctx, o.ErrOut, tracker, err)
+	}
+
+	for _, cp := range plan {
+		if err := ctx.Err(); err != nil {
+			return handleInterrupt(ctx, o.ErrOut, tracker, err)
+		}
+
+		tracker.Start(cp.Chart)
+
+		err = o.deleteResources(ctx, cp.Chart, cp.Resources)
+		if err != nil {
+			return handleInterrupt(ctx, o.ErrOut, tracker, err)
+		}
+
+		tracker.Done()
+	}
+
+	return nil
+}
+
+// Plan visits all charts and collects the resources, hooks and
+// PersistentVolumeClaims that would be affected by their deletion.
+func (o *DeleteOptions) Plan(ctx context.Context) (deletionPlan, error) {
+	plan := make(deletionPlan, 0)
+
+	err := o.Visitor.Visit(ctx, func(c *chart.Chart, err error) error {
 		if err != nil {
 			return err
 		}
 
-		return o.DeleteChart(c)
+		cp := &chartDeletionPlan{Chart: c}
+
+		cp.Resources, err = o.getResourceInfos(c)
+		if err != nil {
+			return err
+		}
+
+		if o.HookExecutor != nil {
+			cp.Hooks = c.Hooks
+		}
+
+		cp.Claims, err = o.PVCPruner.FindClaims(resources.ToObjectList(cp.Resources))
+		if err != nil {
+			return err
+		}
+
+		plan = append(plan, cp)
+
+		return nil
 	})
+
+	return plan, err
 }
 
+// confirmPlan refuses plans containing protected resources, prints the plan
+// and asks the user for confirmation. If stdin is not a terminal, deletion
+// proceeds without confirmation unless o.RequireConfirmation or
+// o.ForceFinalize is set. In dry run mode, protected resources only cause a
+// warning and the plan is printed without requesting confirmation.
+func (o *DeleteOptions) confirmPlan(ctx context.Context, plan deletionPlan) error {
+	if protected := plan.Protected(o.ProtectedKinds); len(protected) > 0 {
+		if !o.dryRun() {
+			return errors.Errorf("refusing to delete protected resources %s, remove their kinds from --protected-kinds to delete them", formatInfos(protected))
+		}
+
+		fmt.Fprintf(o.ErrOut, "warning: deletion of protected resources %s will be refused\n", formatInfos(protected))
+	}
+
+	if plan.IsEmpty() {
+		fmt.Fprintln(o.Out, "nothing to delete")
+		return nil
+	}
+
+	err := plan.Print(o.Out)
+	if err != nil || o.Yes || o.dryRun() {
+		return err
+	}
+
+	if !o.Interactive {
+		if o.ForceFinalize {
+			return ErrForceFinalizeConfirmationRequired
+		}
+
+		if o.RequireConfirmation {
+			return ErrConfirmationRequired
+		}
+
+		fmt.Fprintln(o.ErrOut, "stdin is not a terminal, proceeding without confirmation")
+		return nil
+	}
+
+	prompt := "Delete the resources listed above?"
+	if o.ForceFinalize {
+		prompt = fmt.Sprintf("Delete the resources listed above? Finalizers of resources that are terminating for more than %s will be removed, which may leave orphaned external resources behind.", o.ForceFinalizeAfter)
+	}
+
+	return confirmContext(ctx, o.In, o.Out, prompt)
+}
+
+// getResourceInfos returns the resources of chart c in deletion order.
 func (o *DeleteOptions) getResourceInfos(c *chart.Chart) ([]*resource.Info, error) {
+	var infos []*resource.Info
+	var err error
+
 	if o.Prune {
-		return o.ResourceFinder.FindByLabelSelector(chart.LabelSelector(c))
+		infos, err = o.ResourceFinder.FindByLabelSelector(chart.LabelSelector(c))
+	} else {
+		infos, err = resources.ToInfoList(c.Resources, o.Mapper)
 	}
 
-	return resources.ToInfoList(c.Resources, o.Mapper)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.SortInfosByKind(infos, resources.DeleteOrder)
+
+	return infos, nil
 }
 
-func (o *DeleteOptions) DeleteChart(c *chart.Chart) error {
+// DeleteChart deletes the resources of chart c.
+func (o *DeleteOptions) DeleteChart(ctx context.Context, c *chart.Chart) error {
 	infos, err := o.getResourceInfos(c)
-	if err != nil || len(infos) == 0 {
+	if err != nil {
 		return err
 	}
 
-	resources.SortInfosByKind(infos, resources.DeleteOrder)
+	return o.deleteResources(ctx, c, infos)
+}
+
+func (o *DeleteOptions) deleteResources(ctx context.Context, c *chart.Chart, infos []*resource.Info) error {
+	if len(infos) == 0 {
+		return nil
+	}
 
-	err = o.HookExecutor.ExecHooks(c, hook.TypePreDelete)
+	err := o.HookExecutor.ExecHooks(ctx, c, hook.TypePreDelete)
 	if err != nil {
-		return err
+		return o.handleFailure(ctx, c, err)
 	}
 
-	err = o.Deleter.Delete(resource.InfoListVisitor(infos))
+	err = o.Deleter.Delete(ctx, resource.InfoListVisitor(infos))
 	if err != nil {
-		return err
+		return o.handleFailure(ctx, c, err)
 	}
 
-	err = o.HookExecutor.ExecHooks(c, hook.TypePostDelete)
+	err = o.HookExecutor.ExecHooks(ctx, c, hook.TypePostDelete)
 	if err != nil {
 		return err
 	}
 
 	deletedObjs := resources.ToObjectList(infos)
 
-	return o.PVCPruner.PruneClaims(deletedObjs)
+	return o.PVCPruner.PruneClaims(ctx, deletedObjs)
+}
+
+// handleFailure executes the delete-failed hooks of chart c and returns the
+// original error cause. Failure hooks are not executed if ctx was cancelled.
+func (o *DeleteOptions) handleFailure(ctx context.Context, c *chart.Chart, cause error) error {
+	if ctx.Err() != nil {
+		return cause
+	}
+
+	err := o.HookExecutor.ExecFailureHooks(ctx, c, hook.TypeDeleteFailed, cause)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "error executing %s hooks: %v\n", hook.TypeDeleteFailed, err)
+	}
+
+	return cause
 }
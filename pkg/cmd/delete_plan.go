@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/deletions"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// DefaultProtectedKinds are the kinds of resources that kubectl chart delete
+// refuses to delete unless they are explicitly removed from the list of
+// protected kinds.
+var DefaultProtectedKinds = []string{"Namespace", "CustomResourceDefinition"}
+
+// deletionPlan contains everything that is affected by the deletion of a
+// list of charts.
+type deletionPlan []*chartDeletionPlan
+
+// chartDeletionPlan contains the resources, hooks and PersistentVolumeClaims
+// that are affected by the deletion of a single chart.
+type chartDeletionPlan struct {
+	Chart     *chart.Chart
+	Resources []*resource.Info
+	Hooks     hook.Map
+	Claims    []*resource.Info
+}
+
+// IsEmpty returns true if the plan does not contain any resources.
+func (p deletionPlan) IsEmpty() bool {
+	for _, cp := range p {
+		if len(cp.Resources) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Protected returns the resources of the plan whose kind is contained in
+// kinds. Retained resources are never considered protected as they are not
+// deleted.
+func (p deletionPlan) Protected(kinds []string) []*resource.Info {
+	protected := make([]*resource.Info, 0)
+
+	for _, cp := range p {
+		for _, info := range cp.Resources {
+			if deletions.IsRetained(info.Object) {
+				continue
+			}
+
+			if containsKind(kinds, info.Mapping.GroupVersionKind.Kind) {
+				protected = append(protected, info)
+			}
+		}
+	}
+
+	return protected
+}
+
+// Print prints the plan as a table to w. Rows are printed in the order in
+// which the deletion will take place.
+func (p deletionPlan) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 10, 4, 3, ' ', 0)
+
+	fmt.Fprintln(tw, "CHART\tACTION\tKIND\tNAMESPACE\tNAME")
+
+	for _, cp := range p {
+		if len(cp.Resources) == 0 {
+			continue
+		}
+
+		name := cp.Chart.Config.Name
+
+		printHooks(tw, name, cp.Hooks[hook.TypePreDelete])
+
+		for _, info := range cp.Resources {
+			action := "delete"
+			if deletions.IsRetained(info.Object) {
+				action = "retain"
+			}
+
+			printInfo(tw, name, action, info)
+		}
+
+		printHooks(tw, name, cp.Hooks[hook.TypePostDelete])
+
+		for _, info := range cp.Claims {
+			printInfo(tw, name, "prune", info)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func printHooks(w io.Writer, chartName string, hooks hook.List) {
+	for _, h := range hooks {
+		fmt.Fprintf(w, "%s\t%s hook\t%s\t%s\t%s\n", chartName, h.Type, h.GetKind(), h.GetNamespace(), h.GetName())
+	}
+}
+
+func printInfo(w io.Writer, chartName, action string, info *resource.Info) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", chartName, action, info.Mapping.GroupVersionKind.Kind, info.Namespace, info.Name)
+}
+
+// formatInfos formats infos as a comma separated list of kind/name pairs.
+func formatInfos(infos []*resource.Info) string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = fmt.Sprintf("%s/%s", strings.ToLower(info.Mapping.GroupVersionKind.Kind), info.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func newPlanInfo(kind, namespace, name string) *resource.Info {
+	obj := newUnstructured("v1", kind, namespace, name)
+
+	return &resource.Info{
+		Mapping: &kmeta.RESTMapping{
+			GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: kind},
+		},
+		Namespace: namespace,
+		Name:      name,
+		Object:    obj,
+	}
+}
+
+func newPlanHook(hookType, name string) *hook.Hook {
+	return &hook.Hook{
+		Unstructured: newUnstructured("batch/v1", "Job", "bar", name),
+		Type:         hookType,
+	}
+}
+
+func newTestDeletionPlan() deletionPlan {
+	retained := newPlanInfo("Secret", "foo", "credentials")
+	retained.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{
+		meta.AnnotationDeletionPolicy: meta.DeletionPolicyRetain.String(),
+	})
+
+	return deletionPlan{
+		{
+			Chart: &chart.Chart{Config: &chart.Config{Name: "chart1"}},
+			Resources: []*resource.Info{
+				newPlanInfo("Namespace", "", "foo"),
+				newPlanInfo("StatefulSet", "foo", "db"),
+				retained,
+			},
+			Hooks: hook.Map{}.Add(
+				newPlanHook(hook.TypePreDelete, "backup"),
+				newPlanHook(hook.TypePostDelete, "cleanup"),
+				newPlanHook(hook.TypePostApply, "migrate"),
+			),
+			Claims: []*resource.Info{
+				newPlanInfo("PersistentVolumeClaim", "foo", "data-db-0"),
+			},
+		},
+		{
+			Chart: &chart.Chart{Config: &chart.Config{Name: "chart2"}},
+			Hooks: hook.Map{}.Add(newPlanHook(hook.TypePreDelete, "skipped")),
+		},
+	}
+}
+
+func TestDeletionPlan_Print(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, newTestDeletionPlan().Print(&buf))
+
+	expected := `CHART     ACTION             KIND                    NAMESPACE   NAME
+chart1    pre-delete hook    Job                     bar         backup
+chart1    delete             Namespace                           foo
+chart1    delete             StatefulSet             foo         db
+chart1    retain             Secret                  foo         credentials
+chart1    post-delete hook   Job                     bar         cleanup
+chart1    prune              PersistentVolumeClaim   foo         data-db-0
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestDeletionPlan_Protected(t *testing.T) {
+	plan := newTestDeletionPlan()
+
+	assert.Empty(t, plan.Protected(nil))
+	assert.Equal(t, "namespace/foo", formatInfos(plan.Protected([]string{"Namespace", "CustomResourceDefinition"})))
+	assert.Equal(t, "namespace/foo, statefulset/db", formatInfos(plan.Protected([]string{"namespace", "StatefulSet", "Secret"})))
+}
+
+func TestDeletionPlan_IsEmpty(t *testing.T) {
+	assert.True(t, deletionPlan{}.IsEmpty())
+	assert.True(t, newTestDeletionPlan()[1:].IsEmpty())
+	assert.False(t, newTestDeletionPlan().IsEmpty())
+}
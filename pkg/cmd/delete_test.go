@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -55,9 +56,10 @@ func TestDeleteCmd(t *testing.T) {
 	o := NewDeleteOptions(genericclioptions.NewTestIOStreamsDiscard())
 
 	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+	o.Yes = true
 
 	require.NoError(t, o.Complete(f))
-	require.NoError(t, o.Run())
+	require.NoError(t, o.Run(context.Background()))
 
 	actions := f.FakeDynamicClient.Actions()
 
@@ -74,6 +76,182 @@ func TestDeleteCmd(t *testing.T) {
 	}
 }
 
+func TestDeleteCmd_Interrupted(t *testing.T) {
+	cmdtesting.InitTestErrorHandler(t)
+
+	f := cmdtesting.NewTestFactory().WithNamespace("test")
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	defer f.Cleanup()
+
+	streams, _, _, errBuf := genericclioptions.NewTestIOStreams()
+
+	o := NewDeleteOptions(streams)
+
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, o.Complete(f))
+	require.Equal(t, ErrInterrupted, o.Run(ctx))
+
+	assert.Empty(t, f.FakeDynamicClient.Actions())
+	assert.Equal(t, "charts completed: none\nall other charts were skipped\n", errBuf.String())
+}
+
+func TestDeleteCmd_Validate(t *testing.T) {
+	o := NewDeleteOptions(genericclioptions.NewTestIOStreamsDiscard())
+
+	o.DryRun = true
+	o.ServerDryRun = true
+
+	err := o.Validate()
+
+	require.Error(t, err)
+	assert.Equal(t, ErrIllegalDryRunFlagCombination.Error(), err.Error())
+}
+
+func TestDeleteCmd_InvalidCascade(t *testing.T) {
+	f := cmdtesting.NewTestFactory().WithNamespace("test")
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	defer f.Cleanup()
+
+	o := NewDeleteOptions(genericclioptions.NewTestIOStreamsDiscard())
+
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+	o.Cascade = "true"
+
+	err := o.Complete(f)
+
+	require.Error(t, err)
+	assert.Equal(t, `invalid propagation policy "true", must be one of background, foreground or orphan`, err.Error())
+}
+
+func TestDeleteCmd_Confirmation(t *testing.T) {
+	plan := `CHART     ACTION    KIND          NAMESPACE   NAME
+chart1    delete    Service       test        chart1
+chart1    delete    StatefulSet   test        chart1
+`
+
+	tests := []struct {
+		name            string
+		input           string
+		yes             bool
+		requireConfirm  bool
+		nonInteractive  bool
+		forceFinalize   bool
+		protectedKinds  []string
+		expectedErr     string
+		expectedOut     string
+		expectedDeletes int
+	}{
+		{
+			name:            "confirmed",
+			input:           "y\n",
+			expectedOut:     plan + "Delete the resources listed above? [y/N]: ",
+			expectedDeletes: 2,
+		},
+		{
+			name:        "not confirmed",
+			input:       "n\n",
+			expectedErr: ErrNotConfirmed.Error(),
+			expectedOut: plan + "Delete the resources listed above? [y/N]: ",
+		},
+		{
+			name:            "confirmation skipped",
+			yes:             true,
+			nonInteractive:  true,
+			expectedOut:     plan,
+			expectedDeletes: 2,
+		},
+		{
+			name:            "non-interactive without --yes",
+			nonInteractive:  true,
+			expectedOut:     plan,
+			expectedDeletes: 2,
+		},
+		{
+			name:           "non-interactive with --require-confirmation",
+			nonInteractive: true,
+			requireConfirm: true,
+			expectedErr:    ErrConfirmationRequired.Error(),
+			expectedOut:    plan,
+		},
+		{
+			name:           "non-interactive with --force-finalize",
+			nonInteractive: true,
+			forceFinalize:  true,
+			expectedErr:    ErrForceFinalizeConfirmationRequired.Error(),
+			expectedOut:    plan,
+		},
+		{
+			name:            "non-interactive with --force-finalize and --yes",
+			yes:             true,
+			nonInteractive:  true,
+			forceFinalize:   true,
+			expectedOut:     plan,
+			expectedDeletes: 2,
+		},
+		{
+			name:            "force finalize",
+			input:           "yes\n",
+			forceFinalize:   true,
+			expectedOut:     plan + "Delete the resources listed above? Finalizers of resources that are terminating for more than 1m0s will be removed, which may leave orphaned external resources behind. [y/N]: ",
+			expectedDeletes: 2,
+		},
+		{
+			name:           "protected kinds",
+			yes:            true,
+			protectedKinds: []string{"service"},
+			expectedErr:    "refusing to delete protected resources service/chart1, remove their kinds from --protected-kinds to delete them",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := cmdtesting.NewTestFactory().WithNamespace("test")
+			f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+			defer f.Cleanup()
+
+			streams, in, out, _ := genericclioptions.NewTestIOStreams()
+			in.WriteString(test.input)
+
+			o := NewDeleteOptions(streams)
+
+			o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+			o.ForceFinalize = test.forceFinalize
+			o.Yes = test.yes
+			o.RequireConfirmation = test.requireConfirm
+
+			if test.protectedKinds != nil {
+				o.ProtectedKinds = test.protectedKinds
+			}
+
+			require.NoError(t, o.Complete(f))
+
+			o.Interactive = !test.nonInteractive
+
+			err := o.Run(context.Background())
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			deletes := 0
+			for _, action := range f.FakeDynamicClient.Actions() {
+				if action.GetVerb() == "delete" {
+					deletes++
+				}
+			}
+
+			assert.Equal(t, test.expectedOut, out.String())
+			assert.Equal(t, test.expectedDeletes, deletes)
+		})
+	}
+}
+
 func TestDeleteCmd_DryRun(t *testing.T) {
 	cmdtesting.InitTestErrorHandler(t)
 
@@ -95,7 +273,7 @@ func TestDeleteCmd_DryRun(t *testing.T) {
 	o.DryRun = true
 
 	require.NoError(t, o.Complete(f))
-	require.NoError(t, o.Run())
+	require.NoError(t, o.Run(context.Background()))
 
 	actions := f.FakeDynamicClient.Actions()
 
@@ -111,7 +289,10 @@ func TestDeleteCmd_DryRun(t *testing.T) {
 		t.Error(spew.Sdump(actions))
 	}
 
-	expected := `service/chart1 deleted (dry run)
+	expected := `CHART     ACTION    KIND          NAMESPACE   NAME
+chart1    delete    Service       test        chart1
+chart1    delete    StatefulSet   test        chart1
+service/chart1 deleted (dry run)
 statefulset.apps/chart1 deleted (dry run)
 `
 
@@ -148,9 +329,10 @@ func TestDeleteCmd_Prune(t *testing.T) {
 
 	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
 	o.Prune = true
+	o.Yes = true
 
 	require.NoError(t, o.Complete(f))
-	require.NoError(t, o.Run())
+	require.NoError(t, o.Run(context.Background()))
 
 	actions := f.FakeDynamicClient.Actions()
 
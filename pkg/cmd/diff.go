@@ -2,13 +2,24 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/martinohmann/kubectl-chart/pkg/chart"
 	"github.com/martinohmann/kubectl-chart/pkg/diff"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
 	"github.com/martinohmann/kubectl-chart/pkg/resources"
+	"github.com/martinohmann/kubectl-chart/pkg/resources/statefulset"
 	"github.com/martinohmann/kubectl-chart/pkg/yaml"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -23,6 +34,12 @@ import (
 	"k8s.io/kubectl/pkg/util/templates"
 )
 
+var statefulSetGK = schema.GroupKind{Group: "apps", Kind: "StatefulSet"}
+
+// ErrDiffChanged is returned by DiffOptions.Run if ExitCode is set and the
+// diff contains changes.
+var ErrDiffChanged = errors.New("diff contains changes")
+
 // DryRunVerifier verifies if a given group-version-kind supports DryRun
 // against the current server. Sending dryRun requests to apiserver that
 // don't support it will result in objects being unwillingly persisted.
@@ -45,11 +62,20 @@ func NewDiffCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 			kubectl chart diff -f ~/charts/mychart
 
 			# Diff multiple charts with custom diff context and no coloring
-			kubectl chart diff -f ~/charts --recursive --diff-context 20 --no-color`),
+			kubectl chart diff -f ~/charts --recursive --diff-context 20 --no-color
+
+			# Print the changes of a chart as JSON
+			kubectl chart diff -f ~/charts/mychart -o json
+
+			# Fail if applying the chart would change the cluster
+			kubectl chart diff -f ~/charts/mychart --exit-code
+
+			# Fail only on resource changes, even if the chart has apply hooks
+			kubectl chart diff -f ~/charts/mychart --exit-code --exit-code-ignore-hooks`),
 		Args: cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			cmdutil.CheckErr(o.Complete(f))
-			cmdutil.CheckErr(o.Run())
+			checkDiffErr(o.ErrOut, o.ExitCode, o.Complete(f))
+			checkDiffErr(o.ErrOut, o.ExitCode, o.Run())
 		},
 	}
 
@@ -57,10 +83,38 @@ func NewDiffCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 	o.DiffFlags.AddFlags(cmd)
 
 	cmd.Flags().BoolVar(&o.Prune, "prune", o.Prune, "If true, chart resources not present anymore in the rendered chart manifest will be printed as deletions in the diff.")
+	cmd.Flags().BoolVar(&o.ExitCode, "exit-code", o.ExitCode, "If true, exit with 1 if there are changes and with 2 on errors. Charts with pre-apply or post-apply hooks are always considered changed unless --exit-code-ignore-hooks is set.")
+	cmd.Flags().BoolVar(&o.ExitCodeIgnoreHooks, "exit-code-ignore-hooks", o.ExitCodeIgnoreHooks, "If true, pre-apply and post-apply hooks are not considered changes for --exit-code.")
 
 	return cmd
 }
 
+// checkDiffErr behaves like cmdutil.CheckErr unless exitCode is true. In that
+// case errors exit with code 2 and ErrDiffChanged exits with code 1 without
+// printing anything, just like `diff --exit-code`.
+func checkDiffErr(errOut io.Writer, exitCode bool, err error) {
+	if !exitCode {
+		cmdutil.CheckErr(err)
+		return
+	}
+
+	if err == ErrDiffChanged {
+		os.Exit(1)
+	}
+
+	cmdutil.BehaviorOnFatal(func(msg string, code int) {
+		if msg != "" && !strings.HasSuffix(msg, "\n") {
+			msg += "\n"
+		}
+
+		fmt.Fprint(errOut, msg)
+		os.Exit(2)
+	})
+	defer cmdutil.DefaultBehaviorOnFatal()
+
+	cmdutil.CheckErr(err)
+}
+
 type DiffOptions struct {
 	genericclioptions.IOStreams
 	cmdutil.Factory
@@ -68,10 +122,16 @@ type DiffOptions struct {
 	ChartFlags ChartFlags
 	DiffFlags  DiffFlags
 	Prune      bool
+	ExitCode   bool
+
+	ExitCodeIgnoreHooks bool
 
 	OpenAPISchema  openapi.Resources
 	DryRunVerifier DryRunVerifier
+	PVCResizer     *statefulset.PersistentVolumeClaimResizer
+	PVCPruner      *statefulset.PersistentVolumeClaimPruner
 	DiffPrinter    diff.Printer
+	IgnoreRules    []diff.IgnoreRule
 	Encoder        resources.Encoder
 	Visitor        chart.Visitor
 
@@ -89,7 +149,18 @@ func NewDiffOptions(streams genericclioptions.IOStreams) *DiffOptions {
 
 func (o *DiffOptions) Complete(f cmdutil.Factory) error {
 	o.Factory = f
-	o.DiffPrinter = o.DiffFlags.ToPrinter()
+
+	var err error
+
+	o.DiffPrinter, err = o.DiffFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+
+	o.IgnoreRules, err = o.DiffFlags.ToIgnoreRules()
+	if err != nil {
+		return err
+	}
 
 	discoveryClient, err := f.ToDiscoveryClient()
 	if err != nil {
@@ -103,6 +174,23 @@ func (o *DiffOptions) Complete(f cmdutil.Factory) error {
 
 	o.DryRunVerifier = newDryRunVerifier(dynamicClient, discoveryClient)
 
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	o.PVCResizer = statefulset.NewPersistentVolumeClaimResizer(
+		o.IOStreams,
+		dynamicClient,
+		mapper,
+		printers.NewDiscardingContextPrinter(),
+		true,
+	)
+
+	// The pruner is only used to find the claims of scaled down
+	// StatefulSets, so it does not need a deleter.
+	o.PVCPruner = statefulset.NewPersistentVolumeClaimPruner(dynamicClient, nil, mapper)
+
 	o.OpenAPISchema, err = f.OpenAPISchema()
 	if err != nil {
 		return err
@@ -118,24 +206,80 @@ func (o *DiffOptions) Complete(f cmdutil.Factory) error {
 	return err
 }
 
+// Run diffs all charts. If ExitCode is set, it returns ErrDiffChanged if
+// any of the charts contains changes.
 func (o *DiffOptions) Run() error {
-	return o.Visitor.Visit(func(c *chart.Chart, err error) error {
+	var changed bool
+
+	err := o.Visitor.Visit(context.Background(), func(c *chart.Chart, err error) error {
+		if err != nil {
+			return err
+		}
+
+		chartChanged, err := o.Diff(c)
 		if err != nil {
 			return err
 		}
 
-		return o.Diff(c)
+		changed = changed || chartChanged
+
+		return nil
 	})
-}
+	if err != nil {
+		return err
+	}
 
-// Diff performs a diff of a rendered chart and prints it.
-func (o *DiffOptions) Diff(c *chart.Chart) error {
-	err := o.diffRenderedResources(c)
+	err = o.Flush()
 	if err != nil {
 		return err
 	}
 
-	return o.diffRemovedResources(c)
+	if o.ExitCode && changed {
+		return ErrDiffChanged
+	}
+
+	return nil
+}
+
+// Flush writes the output of diff printers that buffer it until all charts
+// are diffed. It is a no-op for all other printers.
+func (o *DiffOptions) Flush() error {
+	if f, ok := o.DiffPrinter.(diff.Flusher); ok {
+		return f.Flush(o.Out)
+	}
+
+	return nil
+}
+
+// Diff performs a diff of a rendered chart and prints it. It returns true if
+// applying the chart changes the cluster, that is, if resources are created,
+// updated or removed or if the chart has hooks that are executed on apply.
+// Hooks are not taken into account if ExitCodeIgnoreHooks is set.
+func (o *DiffOptions) Diff(c *chart.Chart) (bool, error) {
+	p := diff.NewChangeDetector(o.DiffPrinter)
+
+	err := o.diffRenderedResources(c, p)
+	if err != nil {
+		return false, err
+	}
+
+	err = o.diffScaledDownClaims(c, p)
+	if err != nil {
+		return false, err
+	}
+
+	err = o.diffRemovedResources(c, p)
+	if err != nil {
+		return false, err
+	}
+
+	return p.Changed || (!o.ExitCodeIgnoreHooks && hasApplyHooks(c)), nil
+}
+
+// hasApplyHooks returns true if c contains hooks that are executed every
+// time it is applied.
+func hasApplyHooks(c *chart.Chart) bool {
+	return len(c.Hooks[hook.TypePreApply]) > 0 || len(c.Hooks[hook.TypePostApply]) > 0
 }
 
 // Number of times we try to diff before giving-up
@@ -143,13 +287,20 @@ const maxRetries = 4
 
 // diffRenderedResources retrieves information about all rendered resources and
 // produces a diff of potential changes. The resources are merged with live
-// object information to avoid showing diffs for generated fields.
-func (o *DiffOptions) diffRenderedResources(c *chart.Chart) error {
+// object information to avoid showing diffs for generated fields. If
+// StatefulSets request more storage in their volumeClaimTemplates, the diff
+// also includes the resized PersistentVolumeClaims.
+func (o *DiffOptions) diffRenderedResources(c *chart.Chart, p diff.Printer) error {
 	buf, err := o.Encoder.Encode(c.Resources)
 	if err != nil {
 		return err
 	}
 
+	resizes, err := o.PVCResizer.FindResizes(c.Resources, o.Namespace)
+	if err != nil {
+		return err
+	}
+
 	kdiffer, err := kdiff.NewDiffer("LIVE", "MERGED")
 	if err != nil {
 		return err
@@ -180,9 +331,19 @@ func (o *DiffOptions) diffRenderedResources(c *chart.Chart) error {
 
 		local := info.Object.DeepCopyObject()
 
+		resize := findResize(resizes, info)
+		if resize != nil {
+			// The volumeClaimTemplates are immutable, so we have to merge
+			// the local object using the live storage requests. The new
+			// requests are put back into the merged object afterwards.
+			if err := resize.Revert(local.(*unstructured.Unstructured)); err != nil {
+				return err
+			}
+		}
+
 		for i := 1; i <= maxRetries; i++ {
 			if err = info.Get(); err != nil {
-				if !errors.IsNotFound(err) {
+				if !apierrors.IsNotFound(err) {
 					return err
 				}
 				info.Object = nil
@@ -197,7 +358,7 @@ func (o *DiffOptions) diffRenderedResources(c *chart.Chart) error {
 				)
 			}
 
-			obj := kdiff.InfoObject{
+			var obj kdiff.Object = kdiff.InfoObject{
 				LocalObj: local,
 				Info:     info,
 				Encoder:  scheme.DefaultJSONEncoder(),
@@ -205,8 +366,14 @@ func (o *DiffOptions) diffRenderedResources(c *chart.Chart) error {
 				Force:    force,
 			}
 
+			if resize != nil {
+				obj = resizedObject{Object: obj, Resize: resize}
+			}
+
+			obj = &filteredObject{Object: obj, Filter: o.filter}
+
 			err = kdiffer.Diff(obj, kprinter)
-			if !errors.IsConflict(err) {
+			if !apierrors.IsConflict(err) {
 				break
 			}
 		}
@@ -217,16 +384,27 @@ func (o *DiffOptions) diffRenderedResources(c *chart.Chart) error {
 		return err
 	}
 
+	for _, resize := range resizes {
+		for i := range resize.Claims {
+			obj := &filteredObject{Object: claimObject{&resize.Claims[i]}, Filter: o.filter}
+
+			err = kdiffer.Diff(obj, kprinter)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	differ := diff.NewPathDiffer(kdiffer.From.Dir.Name, kdiffer.To.Dir.Name)
 
-	return differ.Print(o.DiffPrinter, o.Out)
+	return differ.Print(p, o.Out)
 }
 
 // diffRemovedResources retrieves all resources matching the chart label from
 // the cluster and compares them to the rendered resources from the helm chart.
 // It will produce a deletion diff for resources that have been removed from
 // the helm chart but which are still present in the cluster.
-func (o *DiffOptions) diffRemovedResources(c *chart.Chart) error {
+func (o *DiffOptions) diffRemovedResources(c *chart.Chart, p diff.Printer) error {
 	if !o.Prune {
 		return nil
 	}
@@ -238,7 +416,7 @@ func (o *DiffOptions) diffRemovedResources(c *chart.Chart) error {
 		ResourceTypeOrNameArgs(true, "all").
 		Flatten().
 		Do().
-		IgnoreErrors(errors.IsNotFound)
+		IgnoreErrors(apierrors.IsNotFound)
 	if err := r.Err(); err != nil {
 		return err
 	}
@@ -257,12 +435,157 @@ func (o *DiffOptions) diffRemovedResources(c *chart.Chart) error {
 			return nil
 		}
 
-		differ := diff.NewRemovalDiffer(obj.Name(), obj.Live())
-
-		return differ.Print(o.DiffPrinter, o.Out)
+		return o.printRemoval(obj, p)
 	})
 }
 
+// diffScaledDownClaims produces a deletion diff for the PersistentVolumeClaims
+// of StatefulSet replicas that are removed by a scale-down and are pruned
+// because of the delete-pvcs-on-scaledown deletion policy.
+func (o *DiffOptions) diffScaledDownClaims(c *chart.Chart, p diff.Printer) error {
+	claims, err := o.PVCPruner.FindScaledDownClaims(c.Resources, o.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range claims {
+		err = o.printRemoval(kdiff.InfoObject{Info: info}, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printRemoval prints a deletion diff for the live state of obj using p.
+func (o *DiffOptions) printRemoval(obj kdiff.Object, p diff.Printer) error {
+	live, _, err := o.filter(obj.Live(), nil)
+	if err != nil {
+		return err
+	}
+
+	differ := diff.NewRemovalDiffer(obj.Name(), live)
+
+	return differ.Print(p, o.Out)
+}
+
+// filter removes the fields matching the ignore rules from the live and
+// merged objects. Sensitive values are redacted unless ShowSecrets is set.
+func (o *DiffOptions) filter(live, merged runtime.Object) (runtime.Object, runtime.Object, error) {
+	live, merged, err := diff.IgnorePair(o.IgnoreRules, live, merged)
+	if err != nil || o.DiffFlags.ShowSecrets {
+		return live, merged, err
+	}
+
+	return diff.MaskPair(live, merged)
+}
+
+// findResize returns the resize for the StatefulSet described by info or
+// nil if there is none.
+func findResize(resizes []*statefulset.Resize, info *resource.Info) *statefulset.Resize {
+	if info.Mapping.GroupVersionKind.GroupKind() != statefulSetGK {
+		return nil
+	}
+
+	for _, resize := range resizes {
+		if resize.Matches(info.Namespace, info.Name) {
+			return resize
+		}
+	}
+
+	return nil
+}
+
+// resizedObject is a kdiff.Object for a StatefulSet whose volumeClaimTemplates
+// are resized. The new storage requests are set on the merged object.
+type resizedObject struct {
+	kdiff.Object
+	Resize *statefulset.Resize
+}
+
+// Merged implements kdiff.Object.
+func (o resizedObject) Merged() (runtime.Object, error) {
+	obj, err := o.Object.Merged()
+	if err != nil {
+		return nil, err
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+
+	return u, o.Resize.Apply(u)
+}
+
+// filterFunc transforms the live and merged objects before they are diffed.
+type filterFunc func(live, merged runtime.Object) (runtime.Object, runtime.Object, error)
+
+// filteredObject is a kdiff.Object whose live and merged objects are passed
+// through a filter. Both are filtered together so that the filter can
+// compare them, e.g. to tell changed sensitive values from unchanged ones.
+type filteredObject struct {
+	kdiff.Object
+	Filter filterFunc
+
+	filtered bool
+	live     runtime.Object
+	merged   runtime.Object
+	err      error
+}
+
+// Live implements kdiff.Object.
+func (o *filteredObject) Live() runtime.Object {
+	o.filter()
+
+	return o.live
+}
+
+// Merged implements kdiff.Object.
+func (o *filteredObject) Merged() (runtime.Object, error) {
+	o.filter()
+
+	return o.merged, o.err
+}
+
+func (o *filteredObject) filter() {
+	if o.filtered {
+		return
+	}
+
+	o.filtered = true
+
+	merged, err := o.Object.Merged()
+	if err != nil {
+		o.live, o.err = o.Object.Live(), err
+		return
+	}
+
+	o.live, o.merged, o.err = o.Filter(o.Object.Live(), merged)
+}
+
+// claimObject is a kdiff.Object for a PersistentVolumeClaim that is going to
+// be resized.
+type claimObject struct {
+	*statefulset.ClaimResize
+}
+
+// Live implements kdiff.Object.
+func (o claimObject) Live() runtime.Object {
+	return o.Claim
+}
+
+// Merged implements kdiff.Object.
+func (o claimObject) Merged() (runtime.Object, error) {
+	return o.Resized(), nil
+}
+
+// Name implements kdiff.Object.
+func (o claimObject) Name() string {
+	return fmt.Sprintf("v1.PersistentVolumeClaim.%s.%s", o.Claim.GetNamespace(), o.Claim.GetName())
+}
+
 func newDryRunVerifier(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface) *apply.DryRunVerifier {
 	return &apply.DryRunVerifier{
 		Finder:        cmdutil.NewCRDFinder(cmdutil.CRDFromDynamic(dynamicClient)),
@@ -4,10 +4,17 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/diff"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/martinohmann/kubectl-chart/pkg/resources/statefulset"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -97,12 +104,13 @@ func TestDiffCmd(t *testing.T) {
 	o := NewDiffOptions(streams)
 
 	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+	o.ExitCode = true
 
 	require.NoError(t, o.Complete(f))
 
 	o.DryRunVerifier = &permissiveDryRunVerifier{}
 
-	require.NoError(t, o.Run())
+	require.Equal(t, ErrDiffChanged, o.Run())
 
 	expected := `--- apps.v1.StatefulSet.test.chart1
 +++ apps.v1.StatefulSet.test.chart1
@@ -151,3 +159,190 @@ func TestDiffCmd(t *testing.T) {
 `
 	assert.Equal(t, expected, buf.String())
 }
+
+type fakeDiffObject struct {
+	live, merged runtime.Object
+}
+
+func (o fakeDiffObject) Live() runtime.Object            { return o.live }
+func (o fakeDiffObject) Merged() (runtime.Object, error) { return o.merged, nil }
+func (o fakeDiffObject) Name() string                    { return "apps.v1.StatefulSet.test.db" }
+
+func newStorageObject(apiVersion, kind, name, storage string) *unstructured.Unstructured {
+	obj := newUnstructured(apiVersion, kind, "test", name)
+	spec := map[string]interface{}{
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"storage": storage},
+		},
+	}
+
+	if kind == "StatefulSet" {
+		spec = map[string]interface{}{
+			"volumeClaimTemplates": []interface{}{
+				map[string]interface{}{
+					"metadata": map[string]interface{}{"name": "data"},
+					"spec":     spec,
+				},
+			},
+		}
+	}
+
+	obj.Object["spec"] = spec
+
+	return obj
+}
+
+func TestResizedObjects(t *testing.T) {
+	resize := &statefulset.Resize{
+		Templates: []statefulset.TemplateResize{
+			{Name: "data", From: kresource.MustParse("1Gi"), To: kresource.MustParse("2Gi")},
+		},
+		Claims: []statefulset.ClaimResize{
+			{
+				Claim: newStorageObject("v1", "PersistentVolumeClaim", "data-db-0", "1Gi"),
+				From:  kresource.MustParse("1Gi"),
+				To:    kresource.MustParse("2Gi"),
+			},
+		},
+	}
+
+	obj := resizedObject{
+		Object: fakeDiffObject{
+			live:   newStorageObject("apps/v1", "StatefulSet", "db", "1Gi"),
+			merged: newStorageObject("apps/v1", "StatefulSet", "db", "1Gi"),
+		},
+		Resize: resize,
+	}
+
+	merged, err := obj.Merged()
+
+	require.NoError(t, err)
+	assert.Equal(t, newStorageObject("apps/v1", "StatefulSet", "db", "2Gi"), merged)
+	assert.Equal(t, newStorageObject("apps/v1", "StatefulSet", "db", "1Gi"), obj.Live())
+
+	claim := claimObject{&resize.Claims[0]}
+
+	merged, err = claim.Merged()
+
+	require.NoError(t, err)
+	assert.Equal(t, newStorageObject("v1", "PersistentVolumeClaim", "data-db-0", "2Gi"), merged)
+	assert.Equal(t, newStorageObject("v1", "PersistentVolumeClaim", "data-db-0", "1Gi"), claim.Live())
+	assert.Equal(t, "v1.PersistentVolumeClaim.test.data-db-0", claim.Name())
+}
+
+func TestHasApplyHooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		hooks    hook.Map
+		expected bool
+	}{
+		{
+			name: "no hooks",
+		},
+		{
+			name:  "delete and test hooks",
+			hooks: hook.Map{}.Add(&hook.Hook{Type: hook.TypePreDelete}, &hook.Hook{Type: hook.TypeTest}),
+		},
+		{
+			name:     "pre-apply hook",
+			hooks:    hook.Map{}.Add(&hook.Hook{Type: hook.TypePreApply}),
+			expected: true,
+		},
+		{
+			name:     "post-apply hook",
+			hooks:    hook.Map{}.Add(&hook.Hook{Type: hook.TypePostApply}),
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, hasApplyHooks(&chart.Chart{Hooks: test.hooks}))
+		})
+	}
+}
+
+func TestFilteredObject(t *testing.T) {
+	newSecret := func(value string) *unstructured.Unstructured {
+		obj := newUnstructured("v1", "Secret", "test", "foo")
+		obj.Object["data"] = map[string]interface{}{"password": value}
+		obj.Object["spec"] = map[string]interface{}{"replicas": int64(1)}
+		return obj
+	}
+
+	rules, err := diff.ParseIgnoreRules([]string{"Secret:spec"})
+	require.NoError(t, err)
+
+	o := &DiffOptions{IgnoreRules: rules}
+
+	obj := &filteredObject{
+		Object: fakeDiffObject{live: newSecret("Zm9v"), merged: newSecret("YmFy")},
+		Filter: o.filter,
+	}
+
+	expectedLive := newUnstructured("v1", "Secret", "test", "foo")
+	expectedLive.Object["data"] = map[string]interface{}{"password": diff.RedactedValue}
+
+	expectedMerged := newUnstructured("v1", "Secret", "test", "foo")
+	expectedMerged.Object["data"] = map[string]interface{}{"password": diff.ChangedValue}
+
+	merged, err := obj.Merged()
+	require.NoError(t, err)
+
+	assert.Equal(t, expectedLive, obj.Live())
+	assert.Equal(t, expectedMerged, merged)
+
+	o.DiffFlags.ShowSecrets = true
+
+	obj = &filteredObject{
+		Object: fakeDiffObject{live: newSecret("Zm9v"), merged: newSecret("YmFy")},
+		Filter: o.filter,
+	}
+
+	expectedLive.Object["data"] = map[string]interface{}{"password": "Zm9v"}
+
+	assert.Equal(t, expectedLive, obj.Live())
+}
+
+func TestDiffOptions_Diff_Hooks(t *testing.T) {
+	tests := []struct {
+		name        string
+		ignoreHooks bool
+		expected    bool
+	}{
+		{
+			name:     "apply hooks are considered changes",
+			expected: true,
+		},
+		{
+			name:        "apply hooks are ignored",
+			ignoreHooks: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newTestFactoryWithFakeDiscovery(nil)
+			f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+			defer f.Cleanup()
+
+			o := NewDiffOptions(genericclioptions.NewTestIOStreamsDiscard())
+
+			o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+			o.ExitCodeIgnoreHooks = test.ignoreHooks
+			o.Prune = false
+
+			require.NoError(t, o.Complete(f))
+
+			c := &chart.Chart{
+				Config: &chart.Config{Name: "chart1"},
+				Hooks:  hook.Map{}.Add(&hook.Hook{Type: hook.TypePostApply}),
+			}
+
+			changed, err := o.Diff(c)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, changed)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/martinohmann/kubectl-chart/pkg/diff"
 	"github.com/martinohmann/kubectl-chart/pkg/printers"
 	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
 type ChartFlags struct {
@@ -41,8 +42,11 @@ func (f *ChartFlags) ToVisitor(namespace string) (chart.Visitor, error) {
 }
 
 type DiffFlags struct {
-	Context    int
-	PrintFlags PrintFlags
+	Context     int
+	Output      string
+	ShowSecrets bool
+	Ignore      []string
+	PrintFlags  PrintFlags
 }
 
 func NewDefaultDiffFlags() DiffFlags {
@@ -55,21 +59,57 @@ func (f *DiffFlags) AddFlags(cmd *cobra.Command) {
 	f.PrintFlags.AddFlags(cmd)
 
 	cmd.Flags().IntVar(&f.Context, "diff-context", f.Context, "Line context to display before and after each changed block")
+	cmd.Flags().StringVarP(&f.Output, "output", "o", f.Output, "Output format of the diff. One of: json|yaml. If empty, a unified diff is printed.")
+	cmd.Flags().BoolVar(&f.ShowSecrets, "show-secrets", f.ShowSecrets, "If set, the values of Secrets and other sensitive fields are shown in diffs instead of being redacted")
+	cmd.Flags().StringArrayVar(&f.Ignore, "diff-ignore", f.Ignore, "Ignore a field in diffs. Format: [<kind>[.<version>][.<group>]:]<path>, e.g. Deployment.apps:spec.replicas or spec.template.spec.containers[name=istio-proxy]. Can be specified multiple times")
 }
 
-func (f *DiffFlags) ToPrinter() diff.Printer {
-	return diff.NewUnifiedPrinter(diff.Options{
-		Color:   !f.PrintFlags.NoColor,
-		Context: f.Context,
-	})
+// ToPrinter creates the diff printer for the configured output format. It
+// returns an error if the output format is invalid.
+func (f *DiffFlags) ToPrinter() (diff.Printer, error) {
+	switch f.Output {
+	case "":
+		return diff.NewUnifiedPrinter(diff.Options{
+			Color:   !f.PrintFlags.NoColor,
+			Context: f.Context,
+		}), nil
+	case "json", "yaml":
+		return diff.NewStructuredPrinter(f.Output), nil
+	default:
+		return nil, ErrInvalidOutputFormat
+	}
+}
+
+// ToIgnoreRules parses the diff ignore rules. It returns an error if any of
+// them is invalid.
+func (f *DiffFlags) ToIgnoreRules() ([]diff.IgnoreRule, error) {
+	return diff.ParseIgnoreRules(f.Ignore)
 }
 
 type HookFlags struct {
-	NoHooks bool
+	NoHooks           bool
+	DeleteOnInterrupt bool
 }
 
 func (f *HookFlags) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&f.NoHooks, "no-hooks", f.NoHooks, "If set, no hooks will be executed")
+	cmd.Flags().BoolVar(&f.DeleteOnInterrupt, "delete-hooks-on-interrupt", f.DeleteOnInterrupt, "If set, hook Jobs that are still running when the command is interrupted will be deleted")
+}
+
+type TestFlags struct {
+	Logs        bool
+	JUnitReport string
+}
+
+func NewDefaultTestFlags() TestFlags {
+	return TestFlags{
+		Logs: true,
+	}
+}
+
+func (f *TestFlags) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.Logs, "logs", f.Logs, "If true, the logs of failed tests will be printed")
+	cmd.Flags().StringVar(&f.JUnitReport, "junit-report", f.JUnitReport, "If set, a JUnit XML report of the test results will be written to given file")
 }
 
 type PrintFlags struct {
@@ -83,3 +123,30 @@ func (f *PrintFlags) AddFlags(cmd *cobra.Command) {
 func (f *PrintFlags) ToPrinter(dryRun bool) printers.ContextPrinter {
 	return printers.NewContextPrinter(!f.NoColor, dryRun)
 }
+
+// contextFlag returns the value of the --context flag if it is defined on cmd
+// or any of its parents.
+func contextFlag(cmd *cobra.Command) string {
+	flag := cmd.Flag("context")
+	if flag == nil {
+		return ""
+	}
+
+	return flag.Value.String()
+}
+
+// currentContext returns the name of the kubeconfig context in use. A
+// non-empty override (usually the value of the --context flag) takes
+// precedence over the current-context from the kubeconfig.
+func currentContext(f genericclioptions.RESTClientGetter, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+
+	rawConfig, err := f.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return rawConfig.CurrentContext, nil
+}
@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+var (
+	// ErrHookTypeMissing is returned if the hook type was not specified.
+	ErrHookTypeMissing = errors.New("--type is required")
+)
+
+func NewHooksCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Run and inspect hooks of one or multiple helm charts",
+		Long: templates.LongDesc(`
+			Runs or lists the lifecycle hooks of one or multiple helm charts without applying or deleting any chart resources.`),
+		Args: cobra.ExactArgs(0),
+	}
+
+	cmd.AddCommand(NewHooksRunCmd(f, streams))
+	cmd.AddCommand(NewHooksListCmd(f, streams))
+
+	return cmd
+}
+
+func NewHooksRunCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewHooksRunOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run hooks of one or multiple helm charts",
+		Long: templates.LongDesc(`
+			Renders one or multiple helm charts and runs all hooks of given type.`),
+		Example: templates.Examples(`
+			# Run all pre-apply hooks of a chart
+			kubectl chart hooks run --type pre-apply -f ~/charts/mychart
+
+			# Run a single post-delete hook of a chart
+			kubectl chart hooks run --type post-delete -f ~/charts/mychart --hook myhook
+
+			# Dry run hooks of multiple charts
+			kubectl chart hooks run --type pre-apply -f ~/charts --recursive --dry-run`),
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			o.KubeContext = contextFlag(cmd)
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Complete(f))
+
+			ctx, stop := interruptContext(o.ErrOut)
+			defer stop()
+
+			cmdutil.CheckErr(o.Run(ctx))
+		},
+	}
+
+	o.ChartFlags.AddFlags(cmd)
+	o.PrintFlags.AddFlags(cmd)
+
+	cmd.Flags().StringVar(&o.HookType, "type", o.HookType, fmt.Sprintf("Type of the hooks to run. Must be one of: %s", strings.Join(hook.SupportedTypes.List(), ", ")))
+	cmd.Flags().StringSliceVar(&o.HookNames, "hook", o.HookNames, "If set, only hooks with given names will be run")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the hooks that would be run, without running them.")
+	cmd.Flags().BoolVar(&o.ServerDryRun, "server-dry-run", o.ServerDryRun, "If true, hook Jobs are sent to the server with dry-run flag, which means they are validated but not persisted. Local hooks are still executed.")
+
+	return cmd
+}
+
+type HooksRunOptions struct {
+	genericclioptions.IOStreams
+
+	ChartFlags   ChartFlags
+	PrintFlags   PrintFlags
+	HookType     string
+	HookNames    []string
+	DryRun       bool
+	ServerDryRun bool
+
+	Visitor      chart.Visitor
+	HookExecutor *chart.HookExecutor
+
+	Namespace   string
+	KubeContext string
+}
+
+func NewHooksRunOptions(streams genericclioptions.IOStreams) *HooksRunOptions {
+	return &HooksRunOptions{
+		IOStreams: streams,
+	}
+}
+
+func (o *HooksRunOptions) Validate() error {
+	if o.HookType == "" {
+		return ErrHookTypeMissing
+	}
+
+	if o.DryRun && o.ServerDryRun {
+		return ErrIllegalDryRunFlagCombination
+	}
+
+	if !hook.SupportedTypes.Has(o.HookType) {
+		return hook.NewUnsupportedTypeError(o.HookType)
+	}
+
+	return nil
+}
+
+func (o *HooksRunOptions) Complete(f cmdutil.Factory) error {
+	var err error
+
+	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+
+	o.KubeContext, err = currentContext(f, o.KubeContext)
+	if err != nil {
+		return err
+	}
+
+	client, err := f.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	o.HookExecutor = chart.NewHookExecutor(
+		o.IOStreams,
+		client,
+		mapper,
+		o.PrintFlags.ToPrinter(o.DryRun || o.ServerDryRun),
+		o.DryRun,
+		o.ServerDryRun,
+	)
+	o.HookExecutor.KubeContext = o.KubeContext
+
+	o.Visitor, err = o.ChartFlags.ToVisitor(o.Namespace)
+
+	return err
+}
+
+// Run executes the hooks of all charts. All charts are rendered and the
+// requested hook names are validated before any hook is executed. If ctx is
+// cancelled, no further hooks are started and a summary of the completed
+// charts is printed.
+func (o *HooksRunOptions) Run(ctx context.Context) error {
+	defer o.HookExecutor.Wait()
+
+	tracker := &chartTracker{}
+
+	charts, err := o.visitCharts(ctx)
+	if err != nil {
+		return handleInterrupt(ctx, o.ErrOut, tracker, err)
+	}
+
+	err = o.validateHookNames(charts)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range charts {
+		if err := ctx.Err(); err != nil {
+			return handleInterrupt(ctx, o.ErrOut, tracker, err)
+		}
+
+		tracker.Start(c)
+
+		if len(o.HookNames) > 0 {
+			err = o.HookExecutor.ExecNamedHooks(ctx, c, o.HookType, o.HookNames)
+		} else {
+			err = o.HookExecutor.ExecHooks(ctx, c, o.HookType)
+		}
+
+		if err != nil {
+			return handleInterrupt(ctx, o.ErrOut, tracker, err)
+		}
+
+		tracker.Done()
+	}
+
+	return nil
+}
+
+// visitCharts renders all charts.
+func (o *HooksRunOptions) visitCharts(ctx context.Context) ([]*chart.Chart, error) {
+	charts := make([]*chart.Chart, 0)
+
+	err := o.Visitor.Visit(ctx, func(c *chart.Chart, err error) error {
+		if err != nil {
+			return err
+		}
+
+		charts = append(charts, c)
+
+		return nil
+	})
+
+	return charts, err
+}
+
+// validateHookNames returns an error if any of the requested hook names is
+// not present in charts. Hook names are checked against all charts as each of
+// them may only be present in some of the charts.
+func (o *HooksRunOptions) validateHookNames(charts []*chart.Chart) error {
+	if len(o.HookNames) == 0 {
+		return nil
+	}
+
+	found := sets.NewString()
+
+	for _, c := range charts {
+		for _, h := range c.Hooks[o.HookType] {
+			found.Insert(h.GetName())
+		}
+	}
+
+	unknown := sets.NewString(o.HookNames...).Difference(found)
+	if unknown.Len() > 0 {
+		return errors.Errorf("unknown %s hooks: %s", o.HookType, strings.Join(unknown.List(), ", "))
+	}
+
+	return nil
+}
+
+func NewHooksListCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewHooksListOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List hooks of one or multiple helm charts",
+		Long: templates.LongDesc(`
+			Lists the rendered hooks of one or multiple helm charts together with the status of their Jobs in the cluster.`),
+		Example: templates.Examples(`
+			# List all hooks of a chart
+			kubectl chart hooks list -f ~/charts/mychart
+
+			# List all pre-apply hooks of multiple charts
+			kubectl chart hooks list -f ~/charts --recursive --type pre-apply`),
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Complete(f))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	o.ChartFlags.AddFlags(cmd)
+
+	cmd.Flags().StringVar(&o.HookType, "type", o.HookType, "If set, only hooks of given type will be listed")
+
+	return cmd
+}
+
+type HooksListOptions struct {
+	genericclioptions.IOStreams
+
+	ChartFlags ChartFlags
+	HookType   string
+
+	DynamicClient dynamic.Interface
+	Visitor       chart.Visitor
+
+	Namespace string
+}
+
+func NewHooksListOptions(streams genericclioptions.IOStreams) *HooksListOptions {
+	return &HooksListOptions{
+		IOStreams: streams,
+	}
+}
+
+func (o *HooksListOptions) Validate() error {
+	if o.HookType != "" && !hook.SupportedTypes.Has(o.HookType) {
+		return hook.NewUnsupportedTypeError(o.HookType)
+	}
+
+	return nil
+}
+
+func (o *HooksListOptions) Complete(f cmdutil.Factory) error {
+	var err error
+
+	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+
+	o.DynamicClient, err = f.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	o.Visitor, err = o.ChartFlags.ToVisitor(o.Namespace)
+
+	return err
+}
+
+func (o *HooksListOptions) Run() error {
+	w := tabwriter.NewWriter(o.Out, 10, 4, 3, ' ', 0)
+
+	fmt.Fprintln(w, "CHART\tTYPE\tKIND\tNAMESPACE\tNAME\tSTATUS")
+
+	err := o.Visitor.Visit(context.Background(), func(c *chart.Chart, err error) error {
+		if err != nil {
+			return err
+		}
+
+		for _, hookType := range o.hookTypes() {
+			err := o.printHooks(w, c, hookType)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func (o *HooksListOptions) hookTypes() []string {
+	if o.HookType != "" {
+		return []string{o.HookType}
+	}
+
+	return hook.SupportedTypes.List()
+}
+
+func (o *HooksListOptions) printHooks(w *tabwriter.Writer, c *chart.Chart, hookType string) error {
+	hooks := c.Hooks[hookType]
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	jobs, err := o.DynamicClient.
+		Resource(chart.JobGVR).
+		Namespace(metav1.NamespaceAll).
+		List(metav1.ListOptions{
+			LabelSelector: chart.HookLabelSelector(c.Config.Name, hookType),
+		})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return hooks.EachItem(func(h *hook.Hook) error {
+		status := "local"
+		if !h.IsLocal() {
+			status = liveJobStatus(jobs, h)
+		}
+
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Config.Name, h.Type, h.GetKind(), h.GetNamespace(), h.GetName(), status)
+
+		return err
+	})
+}
+
+// liveJobStatus looks up the Job for hook h in jobs and returns a short
+// description of its status. If jobs does not contain a matching Job, <none>
+// is returned.
+func liveJobStatus(jobs *unstructured.UnstructuredList, h *hook.Hook) string {
+	if jobs == nil {
+		return "<none>"
+	}
+
+	for _, job := range jobs.Items {
+		if job.GetNamespace() == h.GetNamespace() && job.GetName() == h.GetName() {
+			return jobStatus(&job)
+		}
+	}
+
+	return "<none>"
+}
+
+// jobStatus returns the status of a Job based on its conditions and the
+// number of active pods.
+func jobStatus(job *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(job.Object, "status", "conditions")
+
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+
+		switch condition["type"] {
+		case "Complete":
+			return "Complete"
+		case "Failed":
+			return "Failed"
+		}
+	}
+
+	active, _, _ := unstructured.NestedInt64(job.Object, "status", "active")
+	if active > 0 {
+		return "Running"
+	}
+
+	return "Pending"
+}
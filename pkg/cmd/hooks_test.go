@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestHooksRunCmd(t *testing.T) {
+	cmdtesting.InitTestErrorHandler(t)
+
+	f := cmdtesting.NewTestFactory().WithNamespace("test")
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	defer f.Cleanup()
+
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+	o := NewHooksRunOptions(streams)
+
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+	o.HookType = hook.TypePostApply
+	o.DryRun = true
+
+	require.NoError(t, o.Validate())
+	require.NoError(t, o.Complete(f))
+	require.NoError(t, o.Run(context.Background()))
+
+	actions := f.FakeDynamicClient.Actions()
+
+	if len(actions) != 1 {
+		t.Fatal(spew.Sdump(actions))
+	}
+
+	if !actions[0].Matches("list", "jobs") || actions[0].(clienttesting.ListAction).GetListRestrictions().Labels.String() != "kubectl-chart/hook-chart-name=chart1,kubectl-chart/hook-type=post-apply" {
+		t.Error(spew.Sdump(actions))
+	}
+
+	assert.Equal(t, "job.batch/chart1 triggered (dry run)\n", buf.String())
+}
+
+func TestHooksRunCmd_HookFilter(t *testing.T) {
+	cmdtesting.InitTestErrorHandler(t)
+
+	f := cmdtesting.NewTestFactory().WithNamespace("test")
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	defer f.Cleanup()
+
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+	o := NewHooksRunOptions(streams)
+
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+	o.HookType = hook.TypePostApply
+	o.HookNames = []string{"chart1", "nonexistent"}
+	o.DryRun = true
+
+	require.NoError(t, o.Complete(f))
+
+	err := o.Run(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "unknown post-apply hooks: nonexistent", err.Error())
+
+	assert.Empty(t, f.FakeDynamicClient.Actions())
+	assert.Empty(t, buf.String())
+}
+
+func TestHooksRunCmd_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		hookType     string
+		dryRun       bool
+		serverDryRun bool
+		expectedErr  string
+	}{
+		{
+			name:        "missing hook type",
+			expectedErr: ErrHookTypeMissing.Error(),
+		},
+		{
+			name:        "unsupported hook type",
+			hookType:    "foo",
+			expectedErr: hook.NewUnsupportedTypeError("foo").Error(),
+		},
+		{
+			name:     "supported hook type",
+			hookType: hook.TypePreDelete,
+		},
+		{
+			name:         "both dry run flags set",
+			hookType:     hook.TypePreDelete,
+			dryRun:       true,
+			serverDryRun: true,
+			expectedErr:  ErrIllegalDryRunFlagCombination.Error(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := NewHooksRunOptions(genericclioptions.NewTestIOStreamsDiscard())
+
+			o.HookType = test.hookType
+			o.DryRun = test.dryRun
+			o.ServerDryRun = test.serverDryRun
+
+			err := o.Validate()
+
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHooksListCmd(t *testing.T) {
+	cmdtesting.InitTestErrorHandler(t)
+
+	f := cmdtesting.NewTestFactory().WithNamespace("test")
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	f.FakeDynamicClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+		job := newUnstructuredWithLabels("batch/v1", "Job", "bar", "chart1", map[string]interface{}{
+			"kubectl-chart/hook-chart-name": "chart1",
+			"kubectl-chart/hook-type":       "post-apply",
+		})
+		unstructured.SetNestedSlice(job.Object, []interface{}{
+			map[string]interface{}{"type": "Complete", "status": "True"},
+		}, "status", "conditions")
+
+		return true, newUnstructuredList(job), nil
+	})
+	defer f.Cleanup()
+
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+	o := NewHooksListOptions(streams)
+
+	o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+
+	require.NoError(t, o.Validate())
+	require.NoError(t, o.Complete(f))
+	require.NoError(t, o.Run())
+
+	expected := `CHART     TYPE         KIND      NAMESPACE   NAME      STATUS
+chart1    post-apply   Job       bar         chart1    Complete
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestJobStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []interface{}
+		active     int64
+		expected   string
+	}{
+		{
+			name:     "pending",
+			expected: "Pending",
+		},
+		{
+			name:     "running",
+			active:   1,
+			expected: "Running",
+		},
+		{
+			name: "failed",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Failed", "status": "True"},
+			},
+			expected: "Failed",
+		},
+		{
+			name: "complete",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Failed", "status": "False"},
+				map[string]interface{}{"type": "Complete", "status": "True"},
+			},
+			expected: "Complete",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := newUnstructured("batch/v1", "Job", "bar", "foo")
+			unstructured.SetNestedSlice(job.Object, test.conditions, "status", "conditions")
+			unstructured.SetNestedField(job.Object, test.active, "status", "active")
+
+			assert.Equal(t, test.expected, jobStatus(job))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/pkg/errors"
+)
+
+// ErrInterrupted is returned by commands that were interrupted by SIGINT or
+// SIGTERM.
+var ErrInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context which is cancelled once the process
+// receives SIGINT or SIGTERM. A second signal terminates the process
+// immediately. The returned func stops listening for signals and must be
+// called once the context is not needed anymore.
+func interruptContext(errOut io.Writer) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigCh := make(chan os.Signal, 2)
+	stopCh := make(chan struct{})
+
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigCh:
+			fmt.Fprintf(errOut, "received %s, shutting down gracefully (repeat to exit immediately)\n", sig)
+			cancel()
+		case <-stopCh:
+			return
+		}
+
+		select {
+		case <-sigCh:
+			os.Exit(1)
+		case <-stopCh:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigCh)
+		close(stopCh)
+		cancel()
+	}
+}
+
+// chartTracker keeps track of the charts processed by a command so that a
+// summary can be printed if the command gets interrupted.
+type chartTracker struct {
+	completed []string
+	current   string
+}
+
+// Start marks chart c as being processed.
+func (t *chartTracker) Start(c *chart.Chart) {
+	t.current = c.Config.Name
+}
+
+// Done marks the chart currently being processed as completed.
+func (t *chartTracker) Done() {
+	t.completed = append(t.completed, t.current)
+	t.current = ""
+}
+
+// PrintSummary prints the completed charts and the chart that was
+// interrupted to w.
+func (t *chartTracker) PrintSummary(w io.Writer) {
+	completed := "none"
+	if len(t.completed) > 0 {
+		completed = strings.Join(t.completed, ", ")
+	}
+
+	fmt.Fprintf(w, "charts completed: %s\n", completed)
+
+	if t.current != "" {
+		fmt.Fprintf(w, "chart interrupted: %s\n", t.current)
+	}
+
+	fmt.Fprintln(w, "all other charts were skipped")
+}
+
+// handleInterrupt prints the summary of t to w and returns ErrInterrupted if
+// ctx was cancelled. Otherwise err is returned unchanged.
+func handleInterrupt(ctx context.Context, w io.Writer, t *chartTracker, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+
+	t.PrintSummary(w)
+
+	return ErrInterrupted
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleInterrupt(t *testing.T) {
+	newChart := func(name string) *chart.Chart {
+		return &chart.Chart{Config: &chart.Config{Name: name}}
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		charts      []string
+		current     string
+		err         error
+		expectedErr error
+		expected    string
+	}{
+		{
+			name:        "not interrupted",
+			ctx:         context.Background(),
+			charts:      []string{"chart1"},
+			err:         errors.New("whoops"),
+			expectedErr: errors.New("whoops"),
+		},
+		{
+			name:        "interrupted before any chart completed",
+			ctx:         cancelled,
+			current:     "chart1",
+			err:         context.Canceled,
+			expectedErr: ErrInterrupted,
+			expected: `charts completed: none
+chart interrupted: chart1
+all other charts were skipped
+`,
+		},
+		{
+			name:        "interrupted between charts",
+			ctx:         cancelled,
+			charts:      []string{"chart1", "chart2"},
+			err:         context.Canceled,
+			expectedErr: ErrInterrupted,
+			expected: `charts completed: chart1, chart2
+all other charts were skipped
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			tracker := &chartTracker{}
+
+			for _, name := range test.charts {
+				tracker.Start(newChart(name))
+				tracker.Done()
+			}
+
+			if test.current != "" {
+				tracker.Start(newChart(test.current))
+			}
+
+			err := handleInterrupt(test.ctx, &buf, tracker, test.err)
+
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/martinohmann/kubectl-chart/pkg/chart"
@@ -87,7 +88,7 @@ func (o *RenderOptions) Complete(f genericclioptions.RESTClientGetter) error {
 }
 
 func (o *RenderOptions) Run() error {
-	return o.Visitor.Visit(func(c *chart.Chart, err error) error {
+	return o.Visitor.Visit(context.Background(), func(c *chart.Chart, err error) error {
 		if err != nil {
 			return err
 		}
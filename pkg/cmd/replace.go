@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/deletions"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/resources/statefulset"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// orphanedKinds are the kinds of resources whose dependents are orphaned when
+// they are replaced. This way the pods of workloads are not interrupted and
+// get adopted by the recreated resource.
+var orphanedKinds = map[schema.GroupKind]bool{
+	{Group: "apps", Kind: "DaemonSet"}:   true,
+	{Group: "apps", Kind: "Deployment"}:  true,
+	{Group: "apps", Kind: "ReplicaSet"}:  true,
+	{Group: "apps", Kind: "StatefulSet"}: true,
+}
+
+// findReplacements returns the live counterparts of the resources of chart c
+// whose apply failed with one of the immutable field errors in failures. It
+// returns an error if such a resource neither has the recreate update
+// strategy nor --force-recreate is set. StatefulSets which are migrated to
+// the template claim tracking mode are always replaced. StatefulSets
+// contained in resizes are skipped as they are recreated anyway.
+func (o *ApplyOptions) findReplacements(c *chart.Chart, failures []apierrors.APIStatus, resizes []*statefulset.Resize) ([]*resource.Info, error) {
+	buf, err := o.Encoder.Encode(c.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	r := o.NewBuilder().
+		Unstructured().
+		NamespaceParam(o.Namespace).DefaultNamespace().
+		Stream(bytes.NewBuffer(buf), c.Config.Name).
+		Flatten().
+		Do()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	replacements := make([]*resource.Info, 0)
+
+	err = r.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		failure := findImmutableFieldFailure(failures, info)
+		if failure == nil || findResize(resizes, info) != nil {
+			return nil
+		}
+
+		local := info.Object.DeepCopyObject()
+
+		if err := info.Get(); err != nil {
+			return err
+		}
+
+		// StatefulSets that switched to the template claim tracking mode
+		// have to be recreated to remove the owner label from their
+		// selector. Their pods are orphaned and adopted again, so this is
+		// done without asking.
+		migrate := statefulset.NeedsClaimTrackingMigration(local, info.Object)
+
+		if !migrate && !o.ForceRecreate && !meta.HasAnnotation(local, meta.AnnotationUpdateStrategy, meta.UpdateStrategyRecreate.String()) {
+			return immutableFieldError(info, failure.(error))
+		}
+
+		if deletions.IsRetained(info.Object) {
+			return errors.Errorf("%s %q has changes to immutable fields but cannot be replaced because of its retain deletion policy", info.Mapping.GroupVersionKind.Kind, info.Name)
+		}
+
+		replacements = append(replacements, info)
+
+		return nil
+	})
+
+	return replacements, err
+}
+
+// deleteReplacements deletes the live objects of the replacements so that the
+// applier can create them again. Dependents of workload resources are
+// orphaned, for all other resources background propagation is used. In dry
+// run mode, the deletions are only printed.
+func (o *ApplyOptions) deleteReplacements(ctx context.Context, replacements []*resource.Info) error {
+	for _, info := range replacements {
+		policy := metav1.DeletePropagationBackground
+		if orphanedKinds[info.Mapping.GroupVersionKind.GroupKind()] {
+			policy = metav1.DeletePropagationOrphan
+		}
+
+		options := deletions.Options{PropagationPolicy: policy}
+
+		var deleter deletions.Deleter
+
+		switch {
+		case o.ServerDryRun:
+			deleter = deletions.NewServerDryRunDeleter(o.IOStreams, o.DynamicClient, o.Printer, options)
+		case o.DryRun:
+			deleter = deletions.NewDeleter(o.IOStreams, o.DynamicClient, o.Printer, true, options)
+		default:
+			deleter = deletions.NewDeleter(o.IOStreams, o.DynamicClient, printers.NewDiscardingContextPrinter(), false, options)
+		}
+
+		err := deleter.Delete(ctx, resource.InfoListVisitor([]*resource.Info{info}))
+		if err != nil {
+			return errors.Wrapf(err, "while replacing %s %q", info.Mapping.GroupVersionKind.Kind, info.Name)
+		}
+	}
+
+	return nil
+}
+
+// printReplacements prints the objects of chart c that would be created again
+// after their live counterparts contained in replacements were deleted. It is
+// used in dry run mode where the replaced objects are not passed to the
+// applier.
+func (o *ApplyOptions) printReplacements(c *chart.Chart, replacements []*resource.Info) error {
+	p := o.recordingPrinter("created")
+
+	for _, obj := range c.Resources {
+		if _, found := findReplacement(replacements, obj); !found {
+			continue
+		}
+
+		err := p.PrintObj(obj, o.Out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withoutReplacements returns the objects that are not contained in
+// replacements.
+func withoutReplacements(objs []runtime.Object, replacements []*resource.Info) []runtime.Object {
+	result := make([]runtime.Object, 0, len(objs))
+
+	for _, obj := range objs {
+		if _, found := findReplacement(replacements, obj); !found {
+			result = append(result, obj)
+		}
+	}
+
+	return result
+}
+
+// findReplacement returns the info from replacements that matches obj. Objects
+// without namespace match infos in any namespace.
+func findReplacement(replacements []*resource.Info, obj runtime.Object) (*resource.Info, bool) {
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		return nil, false
+	}
+
+	kind := obj.GetObjectKind().GroupVersionKind().GroupKind()
+
+	for _, info := range replacements {
+		if info.Mapping.GroupVersionKind.GroupKind() != kind || info.Name != metadata.GetName() {
+			continue
+		}
+
+		if metadata.GetNamespace() == "" || metadata.GetNamespace() == info.Namespace {
+			return info, true
+		}
+	}
+
+	return nil, false
+}
+
+// isImmutableFieldError returns true if err was caused by an attempt to
+// change immutable fields of a resource.
+func isImmutableFieldError(err error) bool {
+	err = errors.Cause(err)
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+
+	status, ok := err.(apierrors.APIStatus)
+	if !ok {
+		return false
+	}
+
+	message := status.Status().Message
+
+	// StatefulSets do not report immutable fields individually but reject
+	// all spec changes other than to a few mutable fields.
+	return strings.Contains(message, "field is immutable") ||
+		strings.Contains(message, "Forbidden: updates to statefulset spec")
+}
+
+// immutableFieldFailures returns the API errors contained in err that were
+// caused by changes to immutable fields. The second return value is false if
+// err also contains other errors.
+func immutableFieldFailures(err error) ([]apierrors.APIStatus, bool) {
+	if err == nil {
+		return nil, true
+	}
+
+	errs := []error{err}
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		errs = agg.Errors()
+	}
+
+	failures := make([]apierrors.APIStatus, 0, len(errs))
+
+	for _, err := range errs {
+		if !isImmutableFieldError(err) {
+			return nil, false
+		}
+
+		failures = append(failures, errors.Cause(err).(apierrors.APIStatus))
+	}
+
+	return failures, true
+}
+
+// findImmutableFieldFailure returns the failure from failures that was
+// reported for the resource described by info or nil if there is none.
+func findImmutableFieldFailure(failures []apierrors.APIStatus, info *resource.Info) apierrors.APIStatus {
+	gk := info.Mapping.GroupVersionKind.GroupKind()
+
+	for _, failure := range failures {
+		details := failure.Status().Details
+		if details == nil {
+			continue
+		}
+
+		if details.Group == gk.Group && details.Kind == gk.Kind && details.Name == info.Name {
+			return failure
+		}
+	}
+
+	return nil
+}
+
+// immutableFieldError wraps err with a hint on how to replace the resource
+// described by info.
+func immutableFieldError(info *resource.Info, err error) error {
+	return errors.Wrapf(
+		err,
+		"%s %q has changes to immutable fields, set annotation %s=%s or use --force-recreate to replace it",
+		info.Mapping.GroupVersionKind.Kind,
+		info.Name,
+		meta.AnnotationUpdateStrategy,
+		meta.UpdateStrategyRecreate,
+	)
+}
+
+// immutableFieldErrorHint wraps err with a hint on how to replace resources
+// with immutable field changes.
+func immutableFieldErrorHint(err error) error {
+	return errors.Wrapf(err, "changes to immutable fields detected, set annotation %s=%s or use --force-recreate to replace the resource", meta.AnnotationUpdateStrategy, meta.UpdateStrategyRecreate)
+}
+
+// replacementPrinter prints objects that were replaced using Printer and all
+// other objects using Delegate.
+type replacementPrinter struct {
+	Replacements []*resource.Info
+	Printer      printers.ResourcePrinter
+	Delegate     printers.ResourcePrinter
+}
+
+// PrintObj implements printers.ResourcePrinter.
+func (p *replacementPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	if _, found := findReplacement(p.Replacements, obj); found {
+		return p.Printer.PrintObj(obj, w)
+	}
+
+	return p.Delegate.PrintObj(obj, w)
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	openapi_v2 "github.com/googleapis/gnostic/OpenAPIv2"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func newImmutableFieldError() error {
+	return apierrors.NewInvalid(
+		schema.GroupKind{Kind: "Service"},
+		"chart1",
+		field.ErrorList{field.Invalid(field.NewPath("spec", "clusterIP"), "10.0.0.2", "field is immutable")},
+	)
+}
+
+func newReplacementInfo(group, kind, namespace, name string) *resource.Info {
+	gvk := schema.GroupVersionKind{Group: group, Version: "v1", Kind: kind}
+	gvr, _ := kmeta.UnsafeGuessKindToResource(gvk)
+
+	return &resource.Info{
+		Mapping: &kmeta.RESTMapping{
+			GroupVersionKind: gvk,
+			Resource:         gvr,
+		},
+		Namespace: namespace,
+		Name:      name,
+		Object:    newUnstructured(schema.GroupVersion{Group: group, Version: "v1"}.String(), kind, namespace, name),
+	}
+}
+
+// newDryRunOpenAPIDocument returns an OpenAPI document that declares server
+// dry run support for the PATCH endpoints of gvks.
+func newDryRunOpenAPIDocument(gvks ...schema.GroupVersionKind) *openapi_v2.Document {
+	paths := make([]*openapi_v2.NamedPathItem, 0, len(gvks))
+
+	for _, gvk := range gvks {
+		paths = append(paths, &openapi_v2.NamedPathItem{
+			Name: gvk.String(),
+			Value: &openapi_v2.PathItem{
+				Patch: &openapi_v2.Operation{
+					VendorExtension: []*openapi_v2.NamedAny{{
+						Name:  "x-kubernetes-group-version-kind",
+						Value: &openapi_v2.Any{Yaml: fmt.Sprintf("group: %q\nkind: %s\nversion: %s\n", gvk.Group, gvk.Kind, gvk.Version)},
+					}},
+					Parameters: []*openapi_v2.ParametersItem{{
+						Oneof: &openapi_v2.ParametersItem_Parameter{
+							Parameter: &openapi_v2.Parameter{
+								Oneof: &openapi_v2.Parameter_NonBodyParameter{
+									NonBodyParameter: &openapi_v2.NonBodyParameter{
+										Oneof: &openapi_v2.NonBodyParameter_QueryParameterSubSchema{
+											QueryParameterSubSchema: &openapi_v2.QueryParameterSubSchema{Name: "dryRun"},
+										},
+									},
+								},
+							},
+						},
+					}},
+				},
+			},
+		})
+	}
+
+	return &openapi_v2.Document{Paths: &openapi_v2.Paths{Path: paths}}
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil"},
+		{name: "other error", err: errors.New("foo")},
+		{name: "not found", err: apierrors.NewNotFound(schema.GroupResource{Resource: "services"}, "chart1")},
+		{
+			name: "other invalid error",
+			err: apierrors.NewInvalid(schema.GroupKind{Kind: "Service"}, "chart1", field.ErrorList{
+				field.Required(field.NewPath("spec", "ports"), ""),
+			}),
+		},
+		{name: "immutable field", err: newImmutableFieldError(), expected: true},
+		{name: "wrapped immutable field", err: errors.Wrap(newImmutableFieldError(), "while applying"), expected: true},
+		{
+			name: "forbidden StatefulSet update",
+			err: apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, "chart1", field.ErrorList{
+				field.Forbidden(field.NewPath("spec"), "updates to statefulset spec for fields other than 'replicas', 'template', and 'updateStrategy' are forbidden"),
+			}),
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isImmutableFieldError(test.err))
+		})
+	}
+}
+
+func TestFindReplacement(t *testing.T) {
+	replacements := []*resource.Info{
+		newReplacementInfo("apps", "StatefulSet", "foo", "db"),
+	}
+
+	tests := []struct {
+		name     string
+		obj      runtime.Object
+		expected bool
+	}{
+		{name: "same object", obj: newUnstructured("apps/v1", "StatefulSet", "foo", "db"), expected: true},
+		{name: "object without namespace", obj: newUnstructured("apps/v1", "StatefulSet", "", "db"), expected: true},
+		{name: "other namespace", obj: newUnstructured("apps/v1", "StatefulSet", "bar", "db")},
+		{name: "other name", obj: newUnstructured("apps/v1", "StatefulSet", "foo", "web")},
+		{name: "other kind", obj: newUnstructured("v1", "Service", "foo", "db")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, found := findReplacement(replacements, test.obj)
+
+			assert.Equal(t, test.expected, found)
+		})
+	}
+}
+
+func TestApplyCmd_toPrinter_Replacements(t *testing.T) {
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+	o := NewApplyOptions(streams)
+	o.Printer = printers.NewContextPrinter(false, false)
+
+	replaced := newUnstructured("v1", "Service", "bar", "replaced")
+	created := newUnstructured("v1", "Service", "bar", "created")
+
+	p, err := o.toPrinter([]*resource.Info{newReplacementInfo("", "Service", "bar", "replaced")}, false)("created")
+	require.NoError(t, err)
+
+	require.NoError(t, p.PrintObj(replaced, buf))
+	require.NoError(t, p.PrintObj(created, buf))
+
+	assert.Equal(t, "service/replaced replaced\nservice/created created\n", buf.String())
+	assert.Equal(t, []runtime.Object{replaced}, o.Recorder.RecordedObjects("replaced"))
+}
+
+func TestApplyCmd_deleteReplacements(t *testing.T) {
+	f := cmdtesting.NewTestFactory().WithNamespace("test")
+	f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	defer f.Cleanup()
+
+	o := NewApplyOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.DynamicClient = f.FakeDynamicClient
+
+	replacements := []*resource.Info{
+		newReplacementInfo("apps", "StatefulSet", "test", "db"),
+		newReplacementInfo("", "Service", "test", "db"),
+	}
+
+	require.NoError(t, o.deleteReplacements(context.Background(), replacements))
+
+	deletes := make([]string, 0)
+	for _, action := range f.FakeDynamicClient.Actions() {
+		if action.GetVerb() == "delete" {
+			deletes = append(deletes, action.GetResource().Resource+"/"+action.(clienttesting.DeleteAction).GetName())
+		}
+	}
+
+	assert.Equal(t, []string{"statefulsets/db", "services/db"}, deletes)
+}
+
+func TestApplyCmd_Replace(t *testing.T) {
+	tests := []struct {
+		name          string
+		dryRun        bool
+		forceRecreate bool
+		expectedErr   string
+		expected      string
+	}{
+		{
+			name:        "immutable field changes without recreate strategy",
+			expectedErr: `Service "chart1" has changes to immutable fields, set annotation kubectl-chart/update-strategy=recreate or use --force-recreate to replace it: error when applying patch:`,
+		},
+		{
+			name:          "immutable field changes with --force-recreate",
+			forceRecreate: true,
+			expected: `statefulset.apps/chart1 created (dry run)
+service/chart1 deleted (dry run)
+service/chart1 created (dry run)
+job.batch/chart1 triggered (dry run)
+`,
+		},
+		{
+			name:          "immutable field changes with --force-recreate and client dry run",
+			dryRun:        true,
+			forceRecreate: true,
+			expected: `statefulset.apps/chart1 created (dry run)
+service/chart1 deleted (dry run)
+service/chart1 created (dry run)
+job.batch/chart1 triggered (dry run)
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newTestFactoryWithFakeDiscovery(nil)
+			f.OpenAPIDocument = newDryRunOpenAPIDocument(
+				schema.GroupVersionKind{Version: "v1", Kind: "Service"},
+				schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+			)
+			f.UnstructuredClient = &fake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					switch p, m := req.URL.Path, req.Method; {
+					case p == "/namespaces/test/services/chart1" && m == "GET":
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     cmdtesting.DefaultHeader(),
+							Body:       cmdtesting.ObjBody(codec, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "chart1", Namespace: "test"}}),
+						}, nil
+					case p == "/namespaces/test/services/chart1" && m == "PATCH" && req.URL.Query().Get("dryRun") == "All":
+						status := newImmutableFieldError().(apierrors.APIStatus).Status()
+						return &http.Response{
+							StatusCode: http.StatusUnprocessableEntity,
+							Header:     cmdtesting.DefaultHeader(),
+							Body:       cmdtesting.ObjBody(codec, &status),
+						}, nil
+					case p == "/namespaces/test/statefulsets/chart1" && m == "GET":
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Header:     cmdtesting.DefaultHeader(),
+							Body:       cmdtesting.ObjBody(codec, &appsv1.StatefulSet{}),
+						}, nil
+					case p == "/namespaces/test/statefulsets" && m == "POST" && req.URL.Query().Get("dryRun") == "All":
+						return &http.Response{
+							StatusCode: http.StatusCreated,
+							Header:     cmdtesting.DefaultHeader(),
+							Body:       cmdtesting.ObjBody(codec, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "chart1", Namespace: "test"}}),
+						}, nil
+					case p == "/api/v1/namespaces/test" && m == "GET":
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Header:     cmdtesting.DefaultHeader(),
+							Body:       cmdtesting.StringBody("{}"),
+						}, nil
+					default:
+						t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
+						return nil, nil
+					}
+				}),
+			}
+			f.ClientConfigVal = cmdtesting.DefaultClientConfig()
+			defer f.Cleanup()
+
+			f.FakeDynamicClient.PrependReactor("get", "services", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, newUnstructured("v1", "Service", "test", "chart1"), nil
+			})
+			f.FakeDynamicClient.PrependReactor("delete", "services", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+			o := NewApplyOptions(streams)
+
+			o.DryRun = test.dryRun
+			o.ServerDryRun = !test.dryRun
+			o.ForceRecreate = test.forceRecreate
+			o.ChartFlags.ChartDir = "../chart/testdata/valid-charts/chart1"
+
+			require.NoError(t, o.Complete(f))
+
+			o.DryRunVerifier = &permissiveDryRunVerifier{}
+
+			err := o.Run(context.Background())
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
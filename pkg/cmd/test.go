@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+)
+
+func NewTestCmd(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewTestOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Run the tests of one or multiple helm charts",
+		Long: templates.LongDesc(`
+			Renders one or multiple helm charts and runs all hooks of type test against the deployed chart resources.`),
+		Example: templates.Examples(`
+			# Run the tests of a chart
+			kubectl chart test -f ~/charts/mychart
+
+			# Run the tests of multiple charts and write a JUnit report
+			kubectl chart test -f ~/charts --recursive --junit-report report.xml`),
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			o.KubeContext = contextFlag(cmd)
+			cmdutil.CheckErr(o.Complete(f))
+
+			ctx, stop := interruptContext(o.ErrOut)
+			defer stop()
+
+			cmdutil.CheckErr(o.Run(ctx))
+		},
+	}
+
+	o.ChartFlags.AddFlags(cmd)
+	o.PrintFlags.AddFlags(cmd)
+	o.TestFlags.AddFlags(cmd)
+
+	return cmd
+}
+
+type TestOptions struct {
+	genericclioptions.IOStreams
+
+	ChartFlags ChartFlags
+	PrintFlags PrintFlags
+	TestFlags  TestFlags
+
+	Visitor    chart.Visitor
+	TestRunner *chart.TestRunner
+	Results    []*chart.TestResult
+
+	Namespace   string
+	KubeContext string
+}
+
+func NewTestOptions(streams genericclioptions.IOStreams) *TestOptions {
+	return &TestOptions{
+		IOStreams: streams,
+		TestFlags: NewDefaultTestFlags(),
+	}
+}
+
+func (o *TestOptions) Complete(f cmdutil.Factory) error {
+	var err error
+
+	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+
+	o.KubeContext, err = currentContext(f, o.KubeContext)
+	if err != nil {
+		return err
+	}
+
+	client, err := f.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	err = o.completeTestRunner(f, client, mapper, o.PrintFlags.ToPrinter(false))
+	if err != nil {
+		return err
+	}
+
+	o.Visitor, err = o.ChartFlags.ToVisitor(o.Namespace)
+
+	return err
+}
+
+// completeTestRunner sets up the *chart.TestRunner. It is also used by other
+// commands that run chart tests.
+func (o *TestOptions) completeTestRunner(
+	f cmdutil.Factory,
+	client dynamic.Interface,
+	mapper meta.RESTMapper,
+	printer printers.ContextPrinter,
+) error {
+	executor := chart.NewHookExecutor(o.IOStreams, client, mapper, printer, false, false)
+	executor.KubeContext = o.KubeContext
+
+	var logFetcher chart.LogFetcher
+
+	if o.TestFlags.Logs || o.TestFlags.JUnitReport != "" {
+		clientset, err := f.KubernetesClientSet()
+		if err != nil {
+			return err
+		}
+
+		logFetcher = chart.NewJobLogFetcher(clientset)
+	}
+
+	o.TestRunner = chart.NewTestRunner(executor, logFetcher)
+
+	return nil
+}
+
+// Run runs the tests of all charts. If ctx is cancelled, no further tests are
+// started and a summary of the completed charts is printed.
+func (o *TestOptions) Run(ctx context.Context) error {
+	tracker := &chartTracker{}
+
+	err := o.Visitor.Visit(ctx, func(c *chart.Chart, err error) error {
+		if err != nil {
+			return err
+		}
+
+		tracker.Start(c)
+
+		err = o.TestChart(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		tracker.Done()
+
+		return nil
+	})
+	if err != nil {
+		return handleInterrupt(ctx, o.ErrOut, tracker, err)
+	}
+
+	return o.Report()
+}
+
+// TestChart runs the tests of chart c and prints the result of each test.
+// Results are collected for the final report.
+func (o *TestOptions) TestChart(ctx context.Context, c *chart.Chart) error {
+	results, err := o.TestRunner.RunTests(ctx, c)
+
+	for _, r := range results {
+		o.printResult(r)
+	}
+
+	o.Results = append(o.Results, results...)
+
+	return err
+}
+
+// Report writes the JUnit report if requested and returns an error if any of
+// the tests failed. Failed tests that are allowed to fail are not counted.
+func (o *TestOptions) Report() error {
+	if o.TestFlags.JUnitReport != "" {
+		err := o.writeJUnitReport()
+		if err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, r := range o.Results {
+		if r.Failed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d tests failed", failed, len(o.Results))
+	}
+
+	return nil
+}
+
+func (o *TestOptions) writeJUnitReport() error {
+	f, err := os.Create(o.TestFlags.JUnitReport)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	return chart.WriteJUnitReport(f, o.Results)
+}
+
+func (o *TestOptions) printResult(r *chart.TestResult) {
+	name := fmt.Sprintf("%s/%s", r.Chart, r.Hook.GetName())
+
+	if r.Passed() {
+		fmt.Fprintf(o.Out, "PASS %s (%s)\n", name, r.Duration)
+		return
+	}
+
+	if r.Skipped() {
+		fmt.Fprintf(o.Out, "SKIP %s (%s): allowed failure: %v\n", name, r.Duration, r.Err)
+	} else {
+		fmt.Fprintf(o.Out, "FAIL %s (%s): %v\n", name, r.Duration, r.Err)
+	}
+
+	if !o.TestFlags.Logs || r.Logs == "" {
+		return
+	}
+
+	fmt.Fprintln(o.Out, strings.TrimRight(r.Logs, "\n"))
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/chart"
+	"github.com/martinohmann/kubectl-chart/pkg/hook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func newTestResult(name string, err error, logs string) *chart.TestResult {
+	return &chart.TestResult{
+		Chart:    "chart1",
+		Hook:     &hook.Hook{Unstructured: newUnstructured("batch/v1", "Job", "bar", name)},
+		Duration: time.Second,
+		Err:      err,
+		Logs:     logs,
+	}
+}
+
+func newAllowedFailureTestResult(name string, err error) *chart.TestResult {
+	r := newTestResult(name, err, "")
+	r.Hook.AllowFailure = true
+
+	return r
+}
+
+func TestTestOptions_printResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   *chart.TestResult
+		logs     bool
+		expected string
+	}{
+		{
+			name:     "passed",
+			result:   newTestResult("test-one", nil, ""),
+			expected: "PASS chart1/test-one (1s)\n",
+		},
+		{
+			name:     "failed with logs",
+			result:   newTestResult("test-one", errors.New("job failed"), "some logs\n"),
+			logs:     true,
+			expected: "FAIL chart1/test-one (1s): job failed\nsome logs\n",
+		},
+		{
+			name:     "failed without logs",
+			result:   newTestResult("test-one", errors.New("job failed"), "some logs\n"),
+			expected: "FAIL chart1/test-one (1s): job failed\n",
+		},
+		{
+			name:     "allowed failure",
+			result:   newAllowedFailureTestResult("test-one", errors.New("job failed")),
+			expected: "SKIP chart1/test-one (1s): allowed failure: job failed\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+
+			o := NewTestOptions(streams)
+			o.TestFlags.Logs = test.logs
+
+			o.printResult(test.result)
+
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestTestOptions_Report(t *testing.T) {
+	o := NewTestOptions(genericclioptions.NewTestIOStreamsDiscard())
+
+	o.Results = []*chart.TestResult{
+		newTestResult("test-one", nil, ""),
+	}
+
+	require.NoError(t, o.Report())
+
+	o.Results = append(o.Results, newAllowedFailureTestResult("test-two", errors.New("job failed")))
+
+	require.NoError(t, o.Report())
+
+	o.Results = append(o.Results, newTestResult("test-three", errors.New("job failed"), ""))
+
+	err := o.Report()
+
+	require.Error(t, err)
+	assert.Equal(t, "1 of 3 tests failed", err.Error())
+}
+
+func TestTestOptions_Report_JUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubectl-chart")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	o := NewTestOptions(genericclioptions.NewTestIOStreamsDiscard())
+	o.TestFlags.JUnitReport = filepath.Join(dir, "report.xml")
+	o.Results = []*chart.TestResult{
+		newTestResult("test-one", nil, ""),
+	}
+
+	require.NoError(t, o.Report())
+
+	buf, err := ioutil.ReadFile(o.TestFlags.JUnitReport)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(buf), `<testcase name="test-one" classname="chart1" time="1.000"></testcase>`)
+}
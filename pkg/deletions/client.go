@@ -0,0 +1,87 @@
+package deletions
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+type policyClient struct {
+	dynamic.Interface
+}
+
+// NewPolicyClient wraps client so that deletions honor the
+// kubectl-chart/deletion-policy and kubectl-chart/propagation-policy
+// annotations of the live object. Resources with the retain deletion policy
+// are detached from their chart instead of being deleted. This is used to
+// make deletions performed by third party code (e.g. kubectl's apply pruning)
+// respect the annotations. Each deletion requires an additional request to
+// retrieve the live object.
+func NewPolicyClient(client dynamic.Interface) dynamic.Interface {
+	return &policyClient{client}
+}
+
+// Resource implements dynamic.Interface.
+func (c *policyClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &policyNamespaceableResourceClient{c.Interface.Resource(resource)}
+}
+
+type policyNamespaceableResourceClient struct {
+	dynamic.NamespaceableResourceInterface
+}
+
+// Namespace implements dynamic.NamespaceableResourceInterface.
+func (c *policyNamespaceableResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return &policyResourceClient{c.NamespaceableResourceInterface.Namespace(namespace)}
+}
+
+// Delete implements dynamic.ResourceInterface.
+func (c *policyNamespaceableResourceClient) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	return deleteWithPolicy(c.NamespaceableResourceInterface, name, options, subresources...)
+}
+
+type policyResourceClient struct {
+	dynamic.ResourceInterface
+}
+
+// Delete implements dynamic.ResourceInterface.
+func (c *policyResourceClient) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	return deleteWithPolicy(c.ResourceInterface, name, options, subresources...)
+}
+
+func deleteWithPolicy(client dynamic.ResourceInterface, name string, options *metav1.DeleteOptions, subresources ...string) error {
+	if len(subresources) > 0 {
+		return client.Delete(name, options, subresources...)
+	}
+
+	obj, err := client.Get(name, metav1.GetOptions{})
+	if err != nil {
+		// Let the actual delete request produce the appropriate error.
+		return client.Delete(name, options)
+	}
+
+	opts := &metav1.DeleteOptions{}
+	if options != nil {
+		opts = options.DeepCopy()
+	}
+
+	if IsRetained(obj) {
+		return detach(client, name, opts.DryRun)
+	}
+
+	var defaultPolicy metav1.DeletionPropagation
+	if opts.PropagationPolicy != nil {
+		defaultPolicy = *opts.PropagationPolicy
+	}
+
+	policy, err := PropagationPolicyFor(obj, defaultPolicy)
+	if err != nil {
+		return err
+	}
+
+	if policy != "" {
+		opts.PropagationPolicy = &policy
+	}
+
+	return client.Delete(name, opts)
+}
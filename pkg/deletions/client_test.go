@@ -0,0 +1,119 @@
+package deletions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestPolicyClient_Delete(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	tests := []struct {
+		name        string
+		obj         runtime.Object
+		getErr      error
+		namespaced  bool
+		expected    metav1.DeletionPropagation
+		expectedErr string
+	}{
+		{
+			name:       "keeps policy of delete options without annotation",
+			obj:        newUnstructured("apps/v1", "Deployment", "ns-foo", "foo"),
+			namespaced: true,
+			expected:   metav1.DeletePropagationForeground,
+		},
+		{
+			name:       "overrides policy from annotation",
+			obj:        newUnstructuredWithPropagationPolicy("foo", "orphan"),
+			namespaced: true,
+			expected:   metav1.DeletePropagationOrphan,
+		},
+		{
+			name:     "overrides policy from annotation for cluster scoped resources",
+			obj:      newUnstructuredWithPropagationPolicy("foo", "background"),
+			expected: metav1.DeletePropagationBackground,
+		},
+		{
+			name:       "deletes with original options if get fails",
+			getErr:     apierrors.NewForbidden(gvr.GroupResource(), "foo", nil),
+			namespaced: true,
+			expected:   metav1.DeletePropagationForeground,
+		},
+		{
+			name:       "detaches retained resources",
+			obj:        newUnstructuredWithDeletionPolicy("foo", "retain"),
+			namespaced: true,
+		},
+		{
+			name:        "invalid annotation",
+			obj:         newUnstructuredWithPropagationPolicy("foo", "cascade"),
+			namespaced:  true,
+			expectedErr: `malformed annotation "kubectl-chart/propagation-policy": invalid propagation policy "cascade", must be one of background, foreground or orphan`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+			fakeClient.PrependReactor("get", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, test.obj, test.getErr
+			})
+			fakeClient.PrependReactor("patch", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, test.obj, nil
+			})
+			fakeClient.PrependReactor("delete", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			recorder := &deleteOptionsRecorder{Interface: fakeClient}
+
+			client := NewPolicyClient(recorder)
+
+			policy := metav1.DeletePropagationForeground
+			options := &metav1.DeleteOptions{PropagationPolicy: &policy}
+
+			var err error
+			if test.namespaced {
+				err = client.Resource(gvr).Namespace("ns-foo").Delete("foo", options)
+			} else {
+				err = client.Resource(gvr).Delete("foo", options)
+			}
+
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				assert.Empty(t, recorder.policies)
+				return
+			}
+
+			require.NoError(t, err)
+
+			if test.expected == "" {
+				assert.Empty(t, recorder.policies)
+
+				patches := 0
+				for _, action := range fakeClient.Actions() {
+					if action.Matches("patch", "deployments") {
+						patches++
+					}
+				}
+
+				assert.Equal(t, 1, patches)
+				return
+			}
+
+			assert.Equal(t, []metav1.DeletionPropagation{test.expected}, recorder.policies)
+
+			// The options passed by the caller must not be mutated.
+			assert.Equal(t, metav1.DeletePropagationForeground, *options.PropagationPolicy)
+		})
+	}
+}
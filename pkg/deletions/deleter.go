@@ -1,7 +1,10 @@
 package deletions
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/martinohmann/kubectl-chart/pkg/printers"
 	"github.com/martinohmann/kubectl-chart/pkg/wait"
@@ -9,6 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
@@ -19,32 +23,93 @@ import (
 type Deleter interface {
 	// Delete walks all resources in the visitor and attempts to delete them.
 	// Optionally, it waits for until the deletion of the resources is complete
-	// if the Deleter supports waiting.
-	Delete(v resource.Visitor) error
+	// if the Deleter supports waiting. Resources with the retain deletion
+	// policy are detached from their chart instead of being deleted. No
+	// further resources are deleted once ctx is cancelled.
+	Delete(ctx context.Context, v resource.Visitor) error
+}
+
+// Options configure the deletion behaviour of a Deleter.
+type Options struct {
+	// PropagationPolicy is the propagation policy used for deleted resources.
+	// It can be overridden per resource via the
+	// kubectl-chart/propagation-policy annotation. If empty, background
+	// propagation is used.
+	PropagationPolicy metav1.DeletionPropagation
+
+	// ForceFinalizeAfter if greater than zero, the finalizers of resources
+	// that are still terminating after this duration are removed.
+	ForceFinalizeAfter time.Duration
+
+	// WaitTimeout is the maximum time to wait for deleted resources to be
+	// gone. If zero, wait.DefaultWaitTimeout is used.
+	WaitTimeout time.Duration
+}
+
+// waitTimeout returns the timeout for waiting on deleted resources.
+func (o Options) waitTimeout() time.Duration {
+	if o.WaitTimeout > 0 {
+		return o.WaitTimeout
+	}
+
+	return wait.DefaultWaitTimeout
 }
 
 // deleter is a Deleter implementation.
 type deleter struct {
 	genericclioptions.IOStreams
+	Options
 	DynamicClient dynamic.Interface
-	Printer       printers.ResourcePrinter
+	Printer       printers.ContextPrinter
 	Waiter        wait.Waiter
 
 	// DryRun if enabled, deletion is only simulated and printed.
 	DryRun bool
+
+	// ServerDryRun if enabled, deletion requests are sent to the server with
+	// the dry-run flag set. This way admission and validation are performed
+	// without actually deleting anything.
+	ServerDryRun bool
 }
 
 // NewSilentDeleter creates a new resource deleter that does not print deleted
 // objects.
 func NewSilentDeleter(streams genericclioptions.IOStreams, client dynamic.Interface, dryRun bool) Deleter {
-	return NewDeleter(streams, client, printers.NewDiscardingContextPrinter(), dryRun)
+	return NewDeleter(streams, client, printers.NewDiscardingContextPrinter(), dryRun, Options{})
 }
 
-// NewDeleter creates a new resource deleter.
-func NewDeleter(streams genericclioptions.IOStreams, client dynamic.Interface, printer printers.ContextPrinter, dryRun bool) Deleter {
+// NewSilentServerDryRunDeleter creates a new resource deleter that does not
+// print deleted objects and sends all deletion requests with the dry-run flag
+// set.
+func NewSilentServerDryRunDeleter(streams genericclioptions.IOStreams, client dynamic.Interface) Deleter {
 	return &deleter{
 		IOStreams:     streams,
 		DynamicClient: client,
+		ServerDryRun:  true,
+		Printer:       printers.NewDiscardingContextPrinter(),
+	}
+}
+
+// NewServerDryRunDeleter creates a new resource deleter which sends all
+// deletion requests with the dry-run flag set. It uses options to configure
+// the deletion behaviour.
+func NewServerDryRunDeleter(streams genericclioptions.IOStreams, client dynamic.Interface, printer printers.ContextPrinter, options Options) Deleter {
+	return &deleter{
+		IOStreams:     streams,
+		Options:       options,
+		DynamicClient: client,
+		ServerDryRun:  true,
+		Printer:       printer.WithOperation("deleted"),
+	}
+}
+
+// NewDeleter creates a new resource deleter which uses options to configure
+// the deletion behaviour.
+func NewDeleter(streams genericclioptions.IOStreams, client dynamic.Interface, printer printers.ContextPrinter, dryRun bool, options Options) Deleter {
+	return &deleter{
+		IOStreams:     streams,
+		Options:       options,
+		DynamicClient: client,
 		DryRun:        dryRun,
 		Waiter:        wait.NewSilentWaiter(streams),
 		Printer:       printer.WithOperation("deleted"),
@@ -52,34 +117,58 @@ func NewDeleter(streams genericclioptions.IOStreams, client dynamic.Interface, p
 }
 
 // Delete implements Deleter.
-func (d *deleter) Delete(v resource.Visitor) error {
+func (d *deleter) Delete(ctx context.Context, v resource.Visitor) error {
 	deletedInfos := []*resource.Info{}
 	uidMap := wait.UIDMap{}
 
 	err := v.Visit(func(info *resource.Info, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
 		if errors.IsNotFound(err) {
 			fmt.Fprintln(d.ErrOut, err)
 		} else if err != nil {
 			return err
 		}
 
+		if IsRetained(info.Object) {
+			return d.retainResource(info)
+		}
+
+		policy, err := PropagationPolicyFor(info.Object, d.defaultPropagationPolicy())
+		if err != nil {
+			return err
+		}
+
 		if d.DryRun {
-			_, err := d.getResource(info)
+			obj, err := d.getResource(info)
+			if err != nil {
+				return err
+			}
+
+			err = d.printerFor(policy).PrintObj(info.Object, d.Out)
 			if err != nil {
 				return err
 			}
 
-			return d.Printer.PrintObj(info.Object, d.Out)
+			d.printFinalizers(info, obj)
+
+			return nil
 		}
 
-		err = d.deleteResource(info)
+		err = d.deleteResource(info, policy)
 		if err != nil {
 			return err
 		}
 
-		deletedInfos = append(deletedInfos, info)
+		d.printerFor(policy).PrintObj(info.Object, d.Out)
 
-		d.Printer.PrintObj(info.Object, d.Out)
+		if d.ServerDryRun {
+			return nil
+		}
+
+		deletedInfos = append(deletedInfos, info)
 
 		resourceLocation := wait.ResourceLocation{
 			GroupResource: info.Mapping.Resource.GroupResource(),
@@ -109,18 +198,24 @@ func (d *deleter) Delete(v resource.Visitor) error {
 		return nil
 	}
 
-	err = d.Waiter.Wait(&wait.Request{
-		ConditionFn: wait.NewDeletedConditionFunc(d.DynamicClient, d.ErrOut, uidMap),
+	err = d.Waiter.Wait(ctx, &wait.Request{
+		ConditionFn: wait.NewDeletedConditionFunc(d.DynamicClient, d.ErrOut, uidMap, d.ForceFinalizeAfter),
+		Options:     &wait.Options{Timeout: d.waitTimeout()},
 		Visitor:     resource.InfoListVisitor(deletedInfos),
+		Timeout:     d.waitTimeout(),
 	})
-	if errors.IsForbidden(err) || errors.IsMethodNotSupported(err) {
-		// if we're forbidden from waiting, we shouldn't fail.
-		// if the resource doesn't support a verb we need, we shouldn't fail.
-		klog.V(1).Info(err)
+	if err == nil {
 		return nil
 	}
 
-	return err
+	// if we're forbidden from waiting, we shouldn't fail.
+	// if the resource doesn't support a verb we need, we shouldn't fail.
+	filtered := utilerrors.FilterOut(err, errors.IsForbidden, errors.IsMethodNotSupported)
+	if filtered == nil {
+		klog.V(1).Info(err)
+	}
+
+	return filtered
 }
 
 func (d *deleter) getResource(info *resource.Info) (*unstructured.Unstructured, error) {
@@ -130,13 +225,83 @@ func (d *deleter) getResource(info *resource.Info) (*unstructured.Unstructured,
 		Get(info.Name, metav1.GetOptions{})
 }
 
-func (d *deleter) deleteResource(info *resource.Info) error {
-	policy := metav1.DeletePropagationBackground
-
+func (d *deleter) deleteResource(info *resource.Info, policy metav1.DeletionPropagation) error {
 	return d.DynamicClient.
 		Resource(info.Mapping.Resource).
 		Namespace(info.Namespace).
-		Delete(info.Name, &metav1.DeleteOptions{
-			PropagationPolicy: &policy,
-		})
+		Delete(info.Name, d.deleteOptions(policy))
+}
+
+// printFinalizers prints the finalizers of obj which would block its
+// deletion. This is used to give insights during dry run.
+func (d *deleter) printFinalizers(info *resource.Info, obj *unstructured.Unstructured) {
+	if obj == nil || len(obj.GetFinalizers()) == 0 {
+		return
+	}
+
+	name := fmt.Sprintf("%s/%s", info.Mapping.Resource.Resource, info.Name)
+	finalizers := strings.Join(obj.GetFinalizers(), ", ")
+
+	if _, terminating, blocked := wait.BlockingFinalizers(obj); blocked {
+		fmt.Fprintf(d.ErrOut, "%s is blocked by finalizers %s (terminating for %s)\n", name, finalizers, terminating.Truncate(time.Second))
+		return
+	}
+
+	fmt.Fprintf(d.ErrOut, "%s has finalizers %s which may block its deletion\n", name, finalizers)
+}
+
+// retainResource detaches the resource from its chart instead of deleting it.
+func (d *deleter) retainResource(info *resource.Info) error {
+	p := d.Printer.WithOperation("retained")
+
+	if d.DryRun {
+		_, err := d.getResource(info)
+		if err != nil {
+			return err
+		}
+
+		return p.PrintObj(info.Object, d.Out)
+	}
+
+	client := d.DynamicClient.Resource(info.Mapping.Resource).Namespace(info.Namespace)
+
+	err := detach(client, info.Name, d.dryRunOption())
+	if err != nil {
+		return err
+	}
+
+	return p.PrintObj(info.Object, d.Out)
+}
+
+func (d *deleter) dryRunOption() []string {
+	if d.ServerDryRun {
+		return []string{metav1.DryRunAll}
+	}
+
+	return nil
+}
+
+func (d *deleter) defaultPropagationPolicy() metav1.DeletionPropagation {
+	if d.PropagationPolicy == "" {
+		return metav1.DeletePropagationBackground
+	}
+
+	return d.PropagationPolicy
+}
+
+// printerFor returns a printer which adds policy to the printer context if it
+// differs from background propagation.
+func (d *deleter) printerFor(policy metav1.DeletionPropagation) printers.ResourcePrinter {
+	if policy == metav1.DeletePropagationBackground {
+		return d.Printer
+	}
+
+	return d.Printer.WithContext(propagationPolicyContext(policy))
+}
+
+func (d *deleter) deleteOptions(policy metav1.DeletionPropagation) *metav1.DeleteOptions {
+	return &metav1.DeleteOptions{
+		PropagationPolicy: &policy,
+		DryRun:            d.dryRunOption(),
+	}
 }
@@ -1,19 +1,25 @@
 package deletions
 
 import (
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
 	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
 	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
 	clienttesting "k8s.io/client-go/testing"
 )
@@ -44,13 +50,15 @@ func TestDeleter_Delete(t *testing.T) {
 	scheme := runtime.NewScheme()
 
 	tests := []struct {
-		name       string
-		infos      []*resource.Info
-		dryRun     bool
-		fakeClient func() *dynamicfakeclient.FakeDynamicClient
+		name         string
+		infos        []*resource.Info
+		dryRun       bool
+		serverDryRun bool
+		fakeClient   func() *dynamicfakeclient.FakeDynamicClient
 
 		expectedErr     string
 		validateActions func(t *testing.T, actions []clienttesting.Action)
+		validateWaiter  func(t *testing.T, waiter *wait.FakeWaiter)
 	}{
 		{
 			name: "deletes a single resource",
@@ -109,6 +117,40 @@ func TestDeleter_Delete(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:         "deletes a single resource in server dry run mode",
+			serverDryRun: true,
+			infos: []*resource.Info{
+				{
+					Mapping: &meta.RESTMapping{
+						Resource: schema.GroupVersionResource{Group: "group", Version: "version", Resource: "theresource"},
+					},
+					Name:      "name-foo",
+					Namespace: "ns-foo",
+				},
+			},
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme)
+				fakeClient.PrependReactor("delete", "theresource", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+					return true, newUnstructuredList(newUnstructured("group/version", "TheKind", "ns-foo", "name-foo")), nil
+				})
+				return fakeClient
+			},
+
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 {
+					t.Fatal(spew.Sdump(actions))
+				}
+				if !actions[0].Matches("delete", "theresource") || actions[0].(clienttesting.DeleteAction).GetName() != "name-foo" {
+					t.Error(spew.Sdump(actions))
+				}
+			},
+			validateWaiter: func(t *testing.T, waiter *wait.FakeWaiter) {
+				if len(waiter.Requests) != 0 {
+					t.Fatal(spew.Sdump(waiter.Requests))
+				}
+			},
+		},
 		{
 			name: "continues on NotFound errors",
 			infos: []*resource.Info{
@@ -287,16 +329,18 @@ func TestDeleter_Delete(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			fakeClient := test.fakeClient()
+			waiter := wait.NewFakeWaiter()
 
 			d := &deleter{
 				IOStreams:     genericclioptions.NewTestIOStreamsDiscard(),
 				DynamicClient: fakeClient,
-				Printer:       printers.NewDiscardingPrinter(),
-				Waiter:        wait.NewFakeWaiter(),
+				Printer:       printers.NewDiscardingContextPrinter(),
+				Waiter:        waiter,
 				DryRun:        test.dryRun,
+				ServerDryRun:  test.serverDryRun,
 			}
 
-			err := d.Delete(resource.InfoListVisitor(test.infos))
+			err := d.Delete(context.Background(), resource.InfoListVisitor(test.infos))
 			switch {
 			case err == nil && len(test.expectedErr) == 0:
 			case err != nil && len(test.expectedErr) == 0:
@@ -310,6 +354,313 @@ func TestDeleter_Delete(t *testing.T) {
 			}
 
 			test.validateActions(t, fakeClient.Actions())
+
+			if test.validateWaiter != nil {
+				test.validateWaiter(t, waiter)
+			}
 		})
 	}
 }
+
+func TestDeleter_deleteOptions(t *testing.T) {
+	d := &deleter{}
+
+	options := d.deleteOptions(metav1.DeletePropagationBackground)
+
+	assert.Equal(t, metav1.DeletePropagationBackground, *options.PropagationPolicy)
+	assert.Empty(t, options.DryRun)
+
+	d.ServerDryRun = true
+
+	options = d.deleteOptions(metav1.DeletePropagationOrphan)
+
+	assert.Equal(t, metav1.DeletePropagationOrphan, *options.PropagationPolicy)
+	assert.Equal(t, []string{metav1.DryRunAll}, options.DryRun)
+}
+
+// deleteOptionsRecorder records the propagation policies of all delete
+// requests as the fake dynamic client does not pass them to its reactors.
+type deleteOptionsRecorder struct {
+	dynamic.Interface
+	policies []metav1.DeletionPropagation
+}
+
+func (r *deleteOptionsRecorder) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	client := r.Interface.Resource(resource)
+
+	return &recordingResourceClient{ResourceInterface: client, parent: client, recorder: r}
+}
+
+type recordingResourceClient struct {
+	dynamic.ResourceInterface
+	parent   dynamic.NamespaceableResourceInterface
+	recorder *deleteOptionsRecorder
+}
+
+func (c *recordingResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return &recordingResourceClient{ResourceInterface: c.parent.Namespace(namespace), recorder: c.recorder}
+}
+
+func (c *recordingResourceClient) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	c.recorder.policies = append(c.recorder.policies, *options.PropagationPolicy)
+
+	return c.ResourceInterface.Delete(name, options, subresources...)
+}
+
+func TestDeleter_Delete_PropagationPolicy(t *testing.T) {
+	newInfo := func(name string, annotations map[string]interface{}) *resource.Info {
+		obj := newUnstructured("group/version", "TheKind", "ns-foo", name)
+		obj.Object["metadata"].(map[string]interface{})["annotations"] = annotations
+
+		return &resource.Info{
+			Mapping: &meta.RESTMapping{
+				Resource: schema.GroupVersionResource{Group: "group", Version: "version", Resource: "theresource"},
+			},
+			Name:      name,
+			Namespace: "ns-foo",
+			Object:    obj,
+		}
+	}
+
+	tests := []struct {
+		name             string
+		policy           metav1.DeletionPropagation
+		dryRun           bool
+		infos            []*resource.Info
+		expectedPolicies []metav1.DeletionPropagation
+		expectedOutput   string
+		expectedErr      string
+	}{
+		{
+			name:             "uses background propagation by default",
+			infos:            []*resource.Info{newInfo("foo", nil)},
+			expectedPolicies: []metav1.DeletionPropagation{metav1.DeletePropagationBackground},
+			expectedOutput:   "thekind.group/foo deleted\n",
+		},
+		{
+			name:   "uses configured policy",
+			policy: metav1.DeletePropagationForeground,
+			infos:  []*resource.Info{newInfo("foo", nil)},
+			expectedPolicies: []metav1.DeletionPropagation{
+				metav1.DeletePropagationForeground,
+			},
+			expectedOutput: "thekind.group/foo deleted (propagation=foreground)\n",
+		},
+		{
+			name:   "annotation overrides configured policy",
+			policy: metav1.DeletePropagationForeground,
+			infos: []*resource.Info{
+				newInfo("foo", map[string]interface{}{"kubectl-chart/propagation-policy": "Orphan"}),
+				newInfo("bar", nil),
+			},
+			expectedPolicies: []metav1.DeletionPropagation{
+				metav1.DeletePropagationOrphan,
+				metav1.DeletePropagationForeground,
+			},
+			expectedOutput: "thekind.group/foo deleted (propagation=orphan)\nthekind.group/bar deleted (propagation=foreground)\n",
+		},
+		{
+			name:           "dry run shows policy",
+			dryRun:         true,
+			infos:          []*resource.Info{newInfo("foo", map[string]interface{}{"kubectl-chart/propagation-policy": "orphan"})},
+			expectedOutput: "thekind.group/foo deleted (propagation=orphan) (dry run)\n",
+		},
+		{
+			name:        "invalid annotation",
+			infos:       []*resource.Info{newInfo("foo", map[string]interface{}{"kubectl-chart/propagation-policy": "cascade"})},
+			expectedErr: `malformed annotation "kubectl-chart/propagation-policy": invalid propagation policy "cascade", must be one of background, foreground or orphan`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+			fakeClient.PrependReactor("*", "theresource", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, nil
+			})
+
+			recorder := &deleteOptionsRecorder{Interface: fakeClient}
+
+			d := &deleter{
+				IOStreams:     streams,
+				Options:       Options{PropagationPolicy: test.policy},
+				DynamicClient: recorder,
+				Printer:       printers.NewContextPrinter(false, test.dryRun).WithOperation("deleted"),
+				DryRun:        test.dryRun,
+			}
+
+			err := d.Delete(context.Background(), resource.InfoListVisitor(test.infos))
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedPolicies, recorder.policies)
+
+			assert.Equal(t, test.expectedOutput, out.String())
+		})
+	}
+}
+
+func TestDeleter_Delete_Retain(t *testing.T) {
+	retained := newUnstructured("group/version", "TheKind", "ns-foo", "name-foo")
+	retained.SetAnnotations(map[string]string{"kubectl-chart/deletion-policy": "retain"})
+
+	infos := []*resource.Info{
+		{
+			Mapping: &meta.RESTMapping{
+				Resource: schema.GroupVersionResource{Group: "group", Version: "version", Resource: "theresource"},
+			},
+			Name:      "name-foo",
+			Namespace: "ns-foo",
+			Object:    retained,
+		},
+		{
+			Mapping: &meta.RESTMapping{
+				Resource: schema.GroupVersionResource{Group: "group", Version: "version", Resource: "theresource"},
+			},
+			Name:      "name-bar",
+			Namespace: "ns-foo",
+			Object:    newUnstructured("group/version", "TheKind", "ns-foo", "name-bar"),
+		},
+	}
+
+	tests := []struct {
+		name            string
+		dryRun          bool
+		expectedOutput  string
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:           "detaches retained resources",
+			expectedOutput: "thekind.group/name-foo retained\nthekind.group/name-bar deleted\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				patchAction, ok := actions[0].(clienttesting.PatchAction)
+				if !ok || !actions[0].Matches("patch", "theresource") || patchAction.GetName() != "name-foo" {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				assert.Equal(t, `{"metadata":{"labels":{"kubectl-chart/chart-name":null}}}`, string(patchAction.GetPatch()))
+
+				if !actions[1].Matches("delete", "theresource") || actions[1].(clienttesting.DeleteAction).GetName() != "name-bar" {
+					t.Error(spew.Sdump(actions))
+				}
+			},
+		},
+		{
+			name:           "does not detach retained resources in dry run",
+			dryRun:         true,
+			expectedOutput: "thekind.group/name-foo retained (dry run)\nthekind.group/name-bar deleted (dry run)\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				if !actions[0].Matches("get", "theresource") || !actions[1].Matches("get", "theresource") {
+					t.Error(spew.Sdump(actions))
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+			fakeClient.PrependReactor("*", "theresource", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, nil
+			})
+
+			waiter := wait.NewFakeWaiter()
+
+			d := &deleter{
+				IOStreams:     streams,
+				DynamicClient: fakeClient,
+				Printer:       printers.NewContextPrinter(false, test.dryRun).WithOperation("deleted"),
+				Waiter:        waiter,
+				DryRun:        test.dryRun,
+			}
+
+			err := d.Delete(context.Background(), resource.InfoListVisitor(infos))
+
+			require.NoError(t, err)
+
+			test.validateActions(t, fakeClient.Actions())
+
+			assert.Equal(t, test.expectedOutput, out.String())
+
+			for _, req := range waiter.Requests {
+				req.Visitor.Visit(func(info *resource.Info, err error) error {
+					assert.NotEqual(t, "name-foo", info.Name, "retained resources must not be awaited")
+					return nil
+				})
+			}
+		})
+	}
+}
+
+func TestDeleter_Delete_DryRunFinalizers(t *testing.T) {
+	deletionTimestamp := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	terminating := newUnstructured("group/version", "TheKind", "ns-foo", "name-foo")
+	terminating.SetDeletionTimestamp(&deletionTimestamp)
+	terminating.SetFinalizers([]string{"example.com/foo"})
+
+	withFinalizers := newUnstructured("group/version", "TheKind", "ns-foo", "name-bar")
+	withFinalizers.SetFinalizers([]string{"example.com/foo", "example.com/bar"})
+
+	objs := map[string]*unstructured.Unstructured{
+		"name-foo": terminating,
+		"name-bar": withFinalizers,
+		"name-baz": newUnstructured("group/version", "TheKind", "ns-foo", "name-baz"),
+	}
+
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+	fakeClient.PrependReactor("get", "theresource", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, objs[action.(clienttesting.GetAction).GetName()], nil
+	})
+
+	streams, _, _, errOut := genericclioptions.NewTestIOStreams()
+
+	d := &deleter{
+		IOStreams:     streams,
+		DynamicClient: fakeClient,
+		Printer:       printers.NewDiscardingContextPrinter(),
+		DryRun:        true,
+	}
+
+	var infos []*resource.Info
+
+	for _, name := range []string{"name-foo", "name-bar", "name-baz"} {
+		infos = append(infos, &resource.Info{
+			Mapping: &meta.RESTMapping{
+				Resource: schema.GroupVersionResource{Group: "group", Version: "version", Resource: "theresource"},
+			},
+			Name:      name,
+			Namespace: "ns-foo",
+		})
+	}
+
+	require.NoError(t, d.Delete(context.Background(), resource.InfoListVisitor(infos)))
+
+	expected := `theresource/name-foo is blocked by finalizers example.com/foo (terminating for 1m0s)
+theresource/name-bar has finalizers example.com/foo, example.com/bar which may block its deletion
+`
+
+	assert.Equal(t, expected, errOut.String())
+}
+
+func TestOptions_waitTimeout(t *testing.T) {
+	assert.Equal(t, wait.DefaultWaitTimeout, Options{}.waitTimeout())
+	assert.Equal(t, time.Minute, Options{WaitTimeout: time.Minute}.waitTimeout())
+}
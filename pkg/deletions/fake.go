@@ -1,6 +1,7 @@
 package deletions
 
 import (
+	"context"
 	"sync"
 
 	"k8s.io/cli-runtime/pkg/resource"
@@ -20,7 +21,7 @@ func NewFakeDeleter() *FakeDeleter {
 	}
 }
 
-func (d *FakeDeleter) Delete(v resource.Visitor) error {
+func (d *FakeDeleter) Delete(ctx context.Context, v resource.Visitor) error {
 	d.Lock()
 	defer d.Unlock()
 
@@ -1,6 +1,7 @@
 package deletions
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -16,7 +17,7 @@ func TestFakeDeleter_Delete(t *testing.T) {
 		{Name: "foo"},
 	}
 
-	err := d.Delete(resource.InfoListVisitor(infos1))
+	err := d.Delete(context.Background(), resource.InfoListVisitor(infos1))
 
 	require.NoError(t, err)
 
@@ -24,7 +25,7 @@ func TestFakeDeleter_Delete(t *testing.T) {
 		{Name: "bar"},
 	}
 
-	err = d.Delete(resource.InfoListVisitor(infos2))
+	err = d.Delete(context.Background(), resource.InfoListVisitor(infos2))
 
 	require.NoError(t, err)
 
@@ -46,7 +47,7 @@ func (*errorVisitor) Visit(fn resource.VisitorFunc) error {
 func TestFakeDeleter_DeleteForwardVisitorErrors(t *testing.T) {
 	d := NewFakeDeleter()
 
-	err := d.Delete(&errorVisitor{})
+	err := d.Delete(context.Background(), &errorVisitor{})
 
 	require.Error(t, err)
 	assert.Equal(t, "whoops", err.Error())
@@ -0,0 +1,92 @@
+package deletions
+
+import (
+	"io"
+	"strings"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/pkg/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ParsePropagationPolicy parses s into a deletion propagation policy. Valid
+// values are "background", "foreground" and "orphan". Parsing is case
+// insensitive.
+func ParsePropagationPolicy(s string) (metav1.DeletionPropagation, error) {
+	switch strings.ToLower(s) {
+	case "background":
+		return metav1.DeletePropagationBackground, nil
+	case "foreground":
+		return metav1.DeletePropagationForeground, nil
+	case "orphan":
+		return metav1.DeletePropagationOrphan, nil
+	default:
+		return "", errors.Errorf("invalid propagation policy %q, must be one of background, foreground or orphan", s)
+	}
+}
+
+// PropagationPolicyFor returns the propagation policy from the
+// kubectl-chart/propagation-policy annotation of obj. If obj does not have
+// the annotation, defaultPolicy is returned.
+func PropagationPolicyFor(obj runtime.Object, defaultPolicy metav1.DeletionPropagation) (metav1.DeletionPropagation, error) {
+	if obj == nil {
+		return defaultPolicy, nil
+	}
+
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		return defaultPolicy, nil
+	}
+
+	value, ok := metadata.GetAnnotations()[meta.AnnotationPropagationPolicy]
+	if !ok {
+		return defaultPolicy, nil
+	}
+
+	policy, err := ParsePropagationPolicy(value)
+	if err != nil {
+		return "", errors.Wrapf(err, "malformed annotation %q", meta.AnnotationPropagationPolicy)
+	}
+
+	return policy, nil
+}
+
+// propagationPolicyContext returns the printer context for policy.
+func propagationPolicyContext(policy metav1.DeletionPropagation) string {
+	return "propagation=" + strings.ToLower(string(policy))
+}
+
+type policyPrinter struct {
+	operation string
+	toPrinter func(operation string, context ...string) printers.ResourcePrinter
+}
+
+// NewPolicyPrinter creates a printer for deleted objects which honors their
+// deletion policy annotations. Objects with the retain deletion policy are
+// printed using the printer for the "retained" operation. All other objects
+// are printed using the printer for operation. If they have the
+// kubectl-chart/propagation-policy annotation, the policy is added to the
+// printer context.
+func NewPolicyPrinter(operation string, toPrinter func(operation string, context ...string) printers.ResourcePrinter) printers.ResourcePrinter {
+	return &policyPrinter{
+		operation: operation,
+		toPrinter: toPrinter,
+	}
+}
+
+// PrintObj implements printers.ResourcePrinter.
+func (p *policyPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	if IsRetained(obj) {
+		return p.toPrinter("retained").PrintObj(obj, w)
+	}
+
+	policy, err := PropagationPolicyFor(obj, "")
+	if err != nil || policy == "" {
+		return p.toPrinter(p.operation).PrintObj(obj, w)
+	}
+
+	return p.toPrinter(p.operation, propagationPolicyContext(policy)).PrintObj(obj, w)
+}
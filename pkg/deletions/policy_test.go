@@ -0,0 +1,119 @@
+package deletions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newUnstructuredWithPropagationPolicy(name, policy string) *unstructured.Unstructured {
+	obj := newUnstructured("apps/v1", "Deployment", "ns-foo", name)
+	obj.SetAnnotations(map[string]string{"kubectl-chart/propagation-policy": policy})
+
+	return obj
+}
+
+func newUnstructuredWithDeletionPolicy(name, policy string) *unstructured.Unstructured {
+	obj := newUnstructured("apps/v1", "Deployment", "ns-foo", name)
+	obj.SetAnnotations(map[string]string{"kubectl-chart/deletion-policy": policy})
+
+	return obj
+}
+
+func TestParsePropagationPolicy(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    metav1.DeletionPropagation
+		expectedErr string
+	}{
+		{value: "background", expected: metav1.DeletePropagationBackground},
+		{value: "Foreground", expected: metav1.DeletePropagationForeground},
+		{value: "ORPHAN", expected: metav1.DeletePropagationOrphan},
+		{value: "", expectedErr: `invalid propagation policy "", must be one of background, foreground or orphan`},
+		{value: "true", expectedErr: `invalid propagation policy "true", must be one of background, foreground or orphan`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			policy, err := ParsePropagationPolicy(test.value)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, policy)
+			}
+		})
+	}
+}
+
+func TestPropagationPolicyFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		obj         runtime.Object
+		expected    metav1.DeletionPropagation
+		expectedErr string
+	}{
+		{
+			name:     "nil object",
+			expected: metav1.DeletePropagationForeground,
+		},
+		{
+			name:     "without annotation",
+			obj:      newUnstructured("apps/v1", "Deployment", "ns-foo", "foo"),
+			expected: metav1.DeletePropagationForeground,
+		},
+		{
+			name:     "with annotation",
+			obj:      newUnstructuredWithPropagationPolicy("foo", "orphan"),
+			expected: metav1.DeletePropagationOrphan,
+		},
+		{
+			name:        "with invalid annotation",
+			obj:         newUnstructuredWithPropagationPolicy("foo", "cascade"),
+			expectedErr: `malformed annotation "kubectl-chart/propagation-policy": invalid propagation policy "cascade", must be one of background, foreground or orphan`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := PropagationPolicyFor(test.obj, metav1.DeletePropagationForeground)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, policy)
+			}
+		})
+	}
+}
+
+func TestPolicyPrinter(t *testing.T) {
+	var buf bytes.Buffer
+
+	toPrinter := func(operation string, context ...string) printers.ResourcePrinter {
+		return printers.NewContextPrinter(false, true).WithOperation(operation).WithContext(context...)
+	}
+
+	p := NewPolicyPrinter("pruned", toPrinter)
+
+	retained := newUnstructuredWithDeletionPolicy("baz", "retain")
+
+	require.NoError(t, p.PrintObj(newUnstructured("apps/v1", "Deployment", "ns-foo", "foo"), &buf))
+	require.NoError(t, p.PrintObj(newUnstructuredWithPropagationPolicy("bar", "orphan"), &buf))
+	require.NoError(t, p.PrintObj(retained, &buf))
+
+	expected := `deployment.apps/foo pruned (dry run)
+deployment.apps/bar pruned (propagation=orphan) (dry run)
+deployment.apps/baz retained (dry run)
+`
+
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,32 @@
+package deletions
+
+import (
+	"fmt"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// detachPatch removes the chart label from a resource.
+var detachPatch = []byte(fmt.Sprintf(`{"metadata":{"labels":{%q:null}}}`, meta.LabelChartName))
+
+// IsRetained returns true if obj has the retain deletion policy and thus must
+// not be deleted.
+func IsRetained(obj runtime.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	return meta.HasDeletionPolicy(obj, meta.DeletionPolicyRetain)
+}
+
+// detach removes the chart label from the resource with name so that it is
+// not considered part of its chart anymore.
+func detach(client dynamic.ResourceInterface, name string, dryRun []string) error {
+	_, err := client.Patch(name, types.MergePatchType, detachPatch, metav1.PatchOptions{DryRun: dryRun})
+
+	return err
+}
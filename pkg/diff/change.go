@@ -0,0 +1,27 @@
+package diff
+
+import "io"
+
+// ChangeDetector is a Printer that records whether any of the printed
+// subjects contains changes. The subjects are passed on to the wrapped
+// Printer.
+type ChangeDetector struct {
+	Printer Printer
+	Changed bool
+}
+
+// NewChangeDetector creates a new *ChangeDetector which wraps p.
+func NewChangeDetector(p Printer) *ChangeDetector {
+	return &ChangeDetector{
+		Printer: p,
+	}
+}
+
+// Print implements Printer.
+func (d *ChangeDetector) Print(s Subject, w io.Writer) error {
+	if s.A != s.B {
+		d.Changed = true
+	}
+
+	return d.Printer.Print(s, w)
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeDetector_Print(t *testing.T) {
+	tests := []struct {
+		name     string
+		subjects []Subject
+		expected bool
+	}{
+		{
+			name: "no subjects",
+		},
+		{
+			name: "unchanged subjects",
+			subjects: []Subject{
+				{A: "foo", B: "foo"},
+				{A: "bar", B: "bar"},
+			},
+		},
+		{
+			name: "changed subject",
+			subjects: []Subject{
+				{A: "foo", B: "foo"},
+				{A: "bar", B: "baz"},
+			},
+			expected: true,
+		},
+		{
+			name:     "created subject",
+			subjects: []Subject{{B: "foo"}},
+			expected: true,
+		},
+		{
+			name:     "removed subject",
+			subjects: []Subject{{A: "foo"}},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewChangeDetector(NewUnifiedPrinter(Options{}))
+
+			var buf bytes.Buffer
+
+			for _, s := range test.subjects {
+				require.NoError(t, d.Print(s, &buf))
+			}
+
+			assert.Equal(t, test.expected, d.Changed)
+			assert.Equal(t, test.expected, buf.Len() > 0)
+		})
+	}
+}
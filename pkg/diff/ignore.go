@@ -0,0 +1,333 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// IgnoreRule describes a field that is removed from objects before they are
+// diffed.
+type IgnoreRule struct {
+	// Kind selects the objects the rule applies to. It has the form
+	// <kind>[.<version>][.<group>], e.g. "Deployment.apps" or
+	// "Deployment.v1.apps". A bare kind like "Deployment" matches objects of
+	// that kind in any group. If empty or "*", the rule applies to all
+	// objects.
+	Kind string
+
+	// Path is the path of the ignored field, e.g. "spec.replicas". See
+	// ParseIgnoreRule for the path syntax.
+	Path string
+
+	path []pathElement
+}
+
+// kindSelectorRegexp matches the kind selector of ignore rules.
+var kindSelectorRegexp = regexp.MustCompile(`^(\*|[A-Z][A-Za-z0-9]*(\.[a-z0-9][-a-z0-9]*)*)$`)
+
+// ParseIgnoreRule parses s into an IgnoreRule. The format of s is
+// [<kind>[.<version>][.<group>]:]<path>. The text before the first colon is
+// only treated as kind selector if it has this form, so paths may contain
+// colons as well. Fields in path are separated by dots, dots that are part
+// of a field name have to be escaped with a backslash. List elements can be
+// selected with [*] (all elements) or [<field>=<value>] (elements whose field
+// has given value), e.g.
+// "spec.template.spec.containers[name=istio-proxy]" or
+// "webhooks[*].clientConfig.caBundle".
+func ParseIgnoreRule(s string) (IgnoreRule, error) {
+	rule := IgnoreRule{Path: s}
+
+	if parts := strings.SplitN(s, ":", 2); len(parts) == 2 {
+		if kind := strings.TrimSpace(parts[0]); kindSelectorRegexp.MatchString(kind) {
+			rule.Kind, rule.Path = kind, parts[1]
+		}
+	}
+
+	var err error
+
+	rule.path, err = parsePath(strings.TrimSpace(rule.Path))
+	if err != nil {
+		return IgnoreRule{}, errors.Wrapf(err, "invalid ignore rule %q", s)
+	}
+
+	return rule, nil
+}
+
+// ParseIgnoreRules parses each element of rules using ParseIgnoreRule.
+func ParseIgnoreRules(rules []string) ([]IgnoreRule, error) {
+	result := make([]IgnoreRule, len(rules))
+
+	for i, s := range rules {
+		rule, err := ParseIgnoreRule(s)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = rule
+	}
+
+	return result, nil
+}
+
+// Matches returns true if the rule applies to objects of given
+// GroupVersionKind.
+func (r IgnoreRule) Matches(gvk schema.GroupVersionKind) bool {
+	if r.Kind == "" || r.Kind == "*" {
+		return true
+	}
+
+	if !strings.Contains(r.Kind, ".") {
+		return r.Kind == gvk.Kind
+	}
+
+	fullySpecified, gk := schema.ParseKindArg(r.Kind)
+	if fullySpecified != nil && *fullySpecified == gvk {
+		return true
+	}
+
+	return gk == gvk.GroupKind()
+}
+
+// IgnorePair returns copies of from and to with all fields removed that
+// match rules or that are listed in the kubectl-chart/diff-ignore-paths
+// annotation of either object. Either object may be nil.
+func IgnorePair(rules []IgnoreRule, from, to runtime.Object) (runtime.Object, runtime.Object, error) {
+	a, err := toUnstructured(from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := toUnstructured(to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths, err := ignoredPaths(rules, a, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(paths) == 0 {
+		return from, to, nil
+	}
+
+	a, b = a.DeepCopy(), b.DeepCopy()
+
+	for _, path := range paths {
+		removePath(objectMap(a), path)
+		removePath(objectMap(b), path)
+	}
+
+	return toObject(a), toObject(b), nil
+}
+
+// Ignore returns a copy of obj with all fields removed that match rules or
+// that are listed in its kubectl-chart/diff-ignore-paths annotation.
+func Ignore(rules []IgnoreRule, obj runtime.Object) (runtime.Object, error) {
+	result, _, err := IgnorePair(rules, obj, nil)
+
+	return result, err
+}
+
+// ignoredPaths returns the paths of all rules that match objs and the paths
+// listed in their annotations.
+func ignoredPaths(rules []IgnoreRule, objs ...*unstructured.Unstructured) ([][]pathElement, error) {
+	paths := make([][]pathElement, 0)
+
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+
+		gvk := obj.GroupVersionKind()
+
+		for _, rule := range rules {
+			if rule.Matches(gvk) {
+				paths = append(paths, rule.path)
+			}
+		}
+
+		value := obj.GetAnnotations()[meta.AnnotationDiffIgnorePaths]
+
+		for _, s := range strings.Split(value, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+
+			path, err := parsePath(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid path %q in annotation %q of %s %q", s, meta.AnnotationDiffIgnorePaths, obj.GetKind(), obj.GetName())
+			}
+
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// pathElement is either a field name or a selector for list elements.
+type pathElement struct {
+	Field string
+
+	List       bool
+	MatchField string
+	MatchValue string
+}
+
+// matches returns true if the list element v is selected by e.
+func (e pathElement) matches(v interface{}) bool {
+	if e.MatchField == "" {
+		return true
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	value, found := m[e.MatchField]
+
+	return found && fmt.Sprint(value) == e.MatchValue
+}
+
+// parsePath parses s into its path elements. A leading dot is optional.
+func parsePath(s string) ([]pathElement, error) {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return nil, errors.New("path must not be empty")
+	}
+
+	path := make([]pathElement, 0)
+
+	var field strings.Builder
+
+	addField := func() error {
+		if field.Len() == 0 {
+			return errors.New("path contains empty field name")
+		}
+
+		path = append(path, pathElement{Field: field.String()})
+		field.Reset()
+
+		return nil
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+
+			field.WriteByte(s[i])
+		case '.':
+			if len(path) > 0 && path[len(path)-1].List && field.Len() == 0 {
+				continue
+			}
+
+			if err := addField(); err != nil {
+				return nil, err
+			}
+		case '[':
+			if field.Len() > 0 {
+				if err := addField(); err != nil {
+					return nil, err
+				}
+			}
+
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, errors.New("path contains unterminated list selector")
+			}
+
+			elem, err := parseListSelector(s[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+
+			path = append(path, elem)
+			i += end
+		default:
+			field.WriteByte(c)
+		}
+	}
+
+	if field.Len() > 0 || len(path) == 0 || !path[len(path)-1].List {
+		if err := addField(); err != nil {
+			return nil, err
+		}
+	}
+
+	return path, nil
+}
+
+func parseListSelector(s string) (pathElement, error) {
+	if s == "*" {
+		return pathElement{List: true}, nil
+	}
+
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return pathElement{}, errors.Errorf("list selector must be [*] or [<field>=<value>], got [%s]", s)
+	}
+
+	return pathElement{List: true, MatchField: parts[0], MatchValue: parts[1]}, nil
+}
+
+// removePath removes the values selected by path from v and returns the
+// result. List elements selected by the last path element are removed from
+// the list.
+func removePath(v interface{}, path []pathElement) interface{} {
+	if len(path) == 0 || v == nil {
+		return v
+	}
+
+	elem, last := path[0], len(path) == 1
+
+	if elem.List {
+		list, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+
+		result := make([]interface{}, 0, len(list))
+
+		for _, item := range list {
+			switch {
+			case !elem.matches(item):
+				result = append(result, item)
+			case !last:
+				result = append(result, removePath(item, path[1:]))
+			}
+		}
+
+		return result
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	child, found := m[elem.Field]
+	if !found {
+		return v
+	}
+
+	if last {
+		delete(m, elem.Field)
+	} else {
+		m[elem.Field] = removePath(child, path[1:])
+	}
+
+	return m
+}
@@ -0,0 +1,306 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newDeployment(annotations map[string]interface{}, replicas int64, containers ...string) *unstructured.Unstructured {
+	items := make([]interface{}, len(containers))
+	for i, name := range containers {
+		items[i] = map[string]interface{}{"name": name, "image": name + ":v1"}
+	}
+
+	metadata := map[string]interface{}{"name": "foo"}
+	if annotations != nil {
+		metadata["annotations"] = annotations
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   metadata,
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": items,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         string
+		expectedKind string
+		expectedPath []pathElement
+		expectedErr  string
+	}{
+		{
+			name:         "path without kind",
+			rule:         "spec.replicas",
+			expectedPath: []pathElement{{Field: "spec"}, {Field: "replicas"}},
+		},
+		{
+			name:         "leading dot",
+			rule:         "Deployment.apps:.spec.replicas",
+			expectedKind: "Deployment.apps",
+			expectedPath: []pathElement{{Field: "spec"}, {Field: "replicas"}},
+		},
+		{
+			name:         "escaped dots",
+			rule:         `DaemonSet.apps:metadata.annotations.deprecated\.daemonset\.template\.generation`,
+			expectedKind: "DaemonSet.apps",
+			expectedPath: []pathElement{
+				{Field: "metadata"},
+				{Field: "annotations"},
+				{Field: "deprecated.daemonset.template.generation"},
+			},
+		},
+		{
+			name:         "list selectors",
+			rule:         "*:webhooks[*].clientConfig.caBundle",
+			expectedKind: "*",
+			expectedPath: []pathElement{
+				{Field: "webhooks"},
+				{List: true},
+				{Field: "clientConfig"},
+				{Field: "caBundle"},
+			},
+		},
+		{
+			name: "list element",
+			rule: "spec.containers[name=istio-proxy]",
+			expectedPath: []pathElement{
+				{Field: "spec"},
+				{Field: "containers"},
+				{List: true, MatchField: "name", MatchValue: "istio-proxy"},
+			},
+		},
+		{
+			name: "colon in list selector value",
+			rule: "spec.containers[image=nginx:1.17]",
+			expectedPath: []pathElement{
+				{Field: "spec"},
+				{Field: "containers"},
+				{List: true, MatchField: "image", MatchValue: "nginx:1.17"},
+			},
+		},
+		{
+			name:         "colon in path with kind",
+			rule:         `Deployment.v1.apps:metadata.annotations.example\.com/port:http`,
+			expectedKind: "Deployment.v1.apps",
+			expectedPath: []pathElement{
+				{Field: "metadata"},
+				{Field: "annotations"},
+				{Field: "example.com/port:http"},
+			},
+		},
+		{
+			name: "colon in path without kind",
+			rule: "metadata.annotations.foo:bar",
+			expectedPath: []pathElement{
+				{Field: "metadata"},
+				{Field: "annotations"},
+				{Field: "foo:bar"},
+			},
+		},
+		{
+			name:        "empty path",
+			rule:        "Deployment.apps:",
+			expectedErr: `invalid ignore rule "Deployment.apps:": path must not be empty`,
+		},
+		{
+			name:        "empty field",
+			rule:        "spec..replicas",
+			expectedErr: `invalid ignore rule "spec..replicas": path contains empty field name`,
+		},
+		{
+			name:        "unterminated list selector",
+			rule:        "spec.containers[name=foo",
+			expectedErr: `invalid ignore rule "spec.containers[name=foo": path contains unterminated list selector`,
+		},
+		{
+			name:        "invalid list selector",
+			rule:        "spec.containers[0]",
+			expectedErr: `invalid ignore rule "spec.containers[0]": list selector must be [*] or [<field>=<value>], got [0]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseIgnoreRule(test.rule)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedKind, rule.Kind)
+			assert.Equal(t, test.expectedPath, rule.path)
+		})
+	}
+}
+
+func TestIgnoreRule_Matches(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	tests := []struct {
+		kind     string
+		expected bool
+	}{
+		{kind: "", expected: true},
+		{kind: "*", expected: true},
+		{kind: "Deployment.apps", expected: true},
+		{kind: "Deployment.v1.apps", expected: true},
+		{kind: "Deployment.v1beta1.apps"},
+		{kind: "Deployment", expected: true},
+		{kind: "StatefulSet"},
+		{kind: "StatefulSet.apps"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			assert.Equal(t, test.expected, IgnoreRule{Kind: test.kind}.Matches(gvk))
+		})
+	}
+}
+
+func TestIgnorePair(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        []string
+		from, to     runtime.Object
+		expectedFrom runtime.Object
+		expectedTo   runtime.Object
+		expectedErr  string
+	}{
+		{
+			name:         "no rules",
+			from:         newDeployment(nil, 1, "app"),
+			to:           newDeployment(nil, 2, "app"),
+			expectedFrom: newDeployment(nil, 1, "app"),
+			expectedTo:   newDeployment(nil, 2, "app"),
+		},
+		{
+			name:         "rule for other kind",
+			rules:        []string{"StatefulSet.apps:spec.replicas"},
+			from:         newDeployment(nil, 1, "app"),
+			to:           newDeployment(nil, 2, "app"),
+			expectedFrom: newDeployment(nil, 1, "app"),
+			expectedTo:   newDeployment(nil, 2, "app"),
+		},
+		{
+			name:  "global rules",
+			rules: []string{"Deployment.apps:spec.replicas", "spec.template.spec.containers[name=sidecar]"},
+			from:  newDeployment(nil, 1, "app", "sidecar"),
+			to:    newDeployment(nil, 2, "app"),
+			expectedFrom: func() runtime.Object {
+				obj := newDeployment(nil, 0, "app")
+				unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+				return obj
+			}(),
+			expectedTo: func() runtime.Object {
+				obj := newDeployment(nil, 0, "app")
+				unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+				return obj
+			}(),
+		},
+		{
+			name:  "fields of all list elements",
+			rules: []string{"spec.template.spec.containers[*].image"},
+			from:  newDeployment(nil, 1, "app", "sidecar"),
+			expectedFrom: func() runtime.Object {
+				obj := newDeployment(nil, 1, "app", "sidecar")
+				containers := obj.Object["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+				for _, c := range containers {
+					delete(c.(map[string]interface{}), "image")
+				}
+				return obj
+			}(),
+		},
+		{
+			name: "annotation on one of the objects",
+			from: newDeployment(map[string]interface{}{
+				meta.AnnotationDiffIgnorePaths:             "spec.replicas",
+				"deprecated.daemonset.template.generation": "1",
+			}, 1, "app"),
+			to: newDeployment(nil, 2, "app"),
+			expectedFrom: func() runtime.Object {
+				obj := newDeployment(map[string]interface{}{
+					meta.AnnotationDiffIgnorePaths:             "spec.replicas",
+					"deprecated.daemonset.template.generation": "1",
+				}, 0, "app")
+				unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+				return obj
+			}(),
+			expectedTo: func() runtime.Object {
+				obj := newDeployment(nil, 0, "app")
+				unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+				return obj
+			}(),
+		},
+		{
+			name:  "escaped annotation key",
+			rules: []string{`metadata.annotations.deprecated\.daemonset\.template\.generation`},
+			from: newDeployment(map[string]interface{}{
+				"deprecated.daemonset.template.generation": "1",
+				"foo": "bar",
+			}, 1, "app"),
+			expectedFrom: newDeployment(map[string]interface{}{"foo": "bar"}, 1, "app"),
+		},
+		{
+			name: "invalid path in annotation",
+			from: newDeployment(map[string]interface{}{
+				meta.AnnotationDiffIgnorePaths: "spec..replicas",
+			}, 1, "app"),
+			expectedErr: `invalid path "spec..replicas" in annotation "kubectl-chart/diff-ignore-paths" of Deployment "foo": path contains empty field name`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := ParseIgnoreRules(test.rules)
+			require.NoError(t, err)
+
+			from, to, err := IgnorePair(rules, test.from, test.to)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFrom, from)
+			assert.Equal(t, test.expectedTo, to)
+		})
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	rules, err := ParseIgnoreRules([]string{"spec.replicas"})
+	require.NoError(t, err)
+
+	obj := newDeployment(nil, 1, "app")
+
+	result, err := Ignore(rules, obj)
+	require.NoError(t, err)
+
+	expected := newDeployment(nil, 1, "app")
+	unstructured.RemoveNestedField(expected.Object, "spec", "replicas")
+
+	assert.Equal(t, expected, result)
+	assert.Equal(t, newDeployment(nil, 1, "app"), obj)
+}
@@ -0,0 +1,243 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// RedactedValue replaces sensitive values in diffs.
+	RedactedValue = "(redacted)"
+
+	// ChangedValue replaces sensitive values in diffs that differ from the
+	// value they are compared to.
+	ChangedValue = "(changed)"
+
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+var secretGK = schema.GroupKind{Kind: "Secret"}
+
+// MaskPair returns copies of from and to with all sensitive values redacted.
+// Values in to that differ from the corresponding value in from are replaced
+// with ChangedValue, all others with RedactedValue, so that changed keys
+// remain visible in diffs. Sensitive are the data and stringData of Secrets
+// and all fields listed in the kubectl-chart/sensitive-paths annotation of
+// either object. Values embedded in the last applied configuration are
+// redacted as well. Either object may be nil. Objects without sensitive
+// values are returned as is.
+func MaskPair(from, to runtime.Object) (runtime.Object, runtime.Object, error) {
+	a, err := toUnstructured(from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := toUnstructured(to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := sensitivePaths(a, b)
+	if len(paths) == 0 {
+		return from, to, nil
+	}
+
+	a, b = a.DeepCopy(), b.DeepCopy()
+
+	maskPaths(objectMap(a), objectMap(b), paths)
+
+	err = maskLastApplied(a, b, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return toObject(a), toObject(b), nil
+}
+
+// Mask returns a copy of obj with all sensitive values replaced by
+// RedactedValue.
+func Mask(obj runtime.Object) (runtime.Object, error) {
+	masked, _, err := MaskPair(obj, nil)
+
+	return masked, err
+}
+
+// sensitivePaths returns the field paths of objs whose values must not
+// appear in diffs.
+func sensitivePaths(objs ...*unstructured.Unstructured) [][]string {
+	paths := make([][]string, 0)
+
+	var secret bool
+
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+
+		if obj.GroupVersionKind().GroupKind() == secretGK {
+			secret = true
+		}
+
+		value := obj.GetAnnotations()[meta.AnnotationSensitivePaths]
+
+		for _, path := range strings.Split(value, ",") {
+			path = strings.TrimSpace(path)
+			if path != "" {
+				paths = append(paths, strings.Split(path, "."))
+			}
+		}
+	}
+
+	if secret {
+		paths = append(paths, []string{"data"}, []string{"stringData"})
+	}
+
+	return paths
+}
+
+// maskPaths redacts the values at paths in a and b. If the values at a path
+// are maps, their values are redacted individually so that the keys remain
+// visible.
+func maskPaths(a, b map[string]interface{}, paths [][]string) {
+	for _, path := range paths {
+		va, foundA, _ := unstructured.NestedFieldNoCopy(a, path...)
+		vb, foundB, _ := unstructured.NestedFieldNoCopy(b, path...)
+
+		ma, isMapA := va.(map[string]interface{})
+		mb, isMapB := vb.(map[string]interface{})
+
+		if (isMapA || !foundA) && (isMapB || !foundB) {
+			for key, value := range mb {
+				original, found := ma[key]
+				mb[key] = maskedValue(found, original, value)
+			}
+
+			for key := range ma {
+				ma[key] = RedactedValue
+			}
+
+			continue
+		}
+
+		if foundB {
+			unstructured.SetNestedField(b, maskedValue(foundA, va, vb), path...)
+		}
+
+		if foundA {
+			unstructured.SetNestedField(a, RedactedValue, path...)
+		}
+	}
+}
+
+// maskedValue returns the replacement for value. It is ChangedValue if value
+// differs from an existing original value and RedactedValue otherwise.
+func maskedValue(hasOriginal bool, original, value interface{}) string {
+	if hasOriginal && !reflect.DeepEqual(original, value) {
+		return ChangedValue
+	}
+
+	return RedactedValue
+}
+
+// maskLastApplied redacts the values at paths in the last applied
+// configuration annotations of a and b as they contain the sensitive values
+// as well.
+func maskLastApplied(a, b *unstructured.Unstructured, paths [][]string) error {
+	configA, err := lastApplied(a)
+	if err != nil {
+		return err
+	}
+
+	configB, err := lastApplied(b)
+	if err != nil {
+		return err
+	}
+
+	maskPaths(configA, configB, paths)
+
+	err = setLastApplied(a, configA)
+	if err != nil {
+		return err
+	}
+
+	return setLastApplied(b, configB)
+}
+
+func lastApplied(obj *unstructured.Unstructured) (map[string]interface{}, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	value, found := obj.GetAnnotations()[lastAppliedAnnotation]
+	if !found {
+		return nil, nil
+	}
+
+	var config map[string]interface{}
+
+	err := json.Unmarshal([]byte(value), &config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while parsing annotation %q of %s %q", lastAppliedAnnotation, obj.GetKind(), obj.GetName())
+	}
+
+	return config, nil
+}
+
+func setLastApplied(obj *unstructured.Unstructured, config map[string]interface{}) error {
+	if config == nil {
+		return nil
+	}
+
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	annotations := obj.GetAnnotations()
+	annotations[lastAppliedAnnotation] = string(buf)
+	obj.SetAnnotations(annotations)
+
+	return nil
+}
+
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if obj == nil || reflect.ValueOf(obj).IsNil() {
+		return nil, nil
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unstructured.Unstructured{Object: m}, nil
+}
+
+func objectMap(obj *unstructured.Unstructured) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+
+	return obj.Object
+}
+
+// toObject converts obj to runtime.Object without turning a nil pointer into
+// a non-nil interface.
+func toObject(obj *unstructured.Unstructured) runtime.Object {
+	if obj == nil {
+		return nil
+	}
+
+	return obj
+}
@@ -0,0 +1,224 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newSecret(data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":      "foo",
+				"namespace": "bar",
+			},
+			"data": data,
+		},
+	}
+}
+
+func TestMaskPair(t *testing.T) {
+	tests := []struct {
+		name         string
+		from, to     runtime.Object
+		expectedFrom runtime.Object
+		expectedTo   runtime.Object
+		expectedErr  string
+	}{
+		{
+			name:         "nil objects",
+			expectedFrom: nil,
+			expectedTo:   nil,
+		},
+		{
+			name: "object without sensitive values",
+			from: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"data": map[string]interface{}{"foo": "bar"},
+			}},
+			expectedFrom: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"data": map[string]interface{}{"foo": "bar"},
+			}},
+		},
+		{
+			name: "changed, unchanged, added and removed secret keys",
+			from: newSecret(map[string]interface{}{"user": "YWRtaW4=", "pass": "c2VjcmV0", "old": "Zm9v"}),
+			to:   newSecret(map[string]interface{}{"user": "YWRtaW4=", "pass": "bmV3", "new": "YmFy"}),
+			expectedFrom: newSecret(map[string]interface{}{
+				"user": RedactedValue,
+				"pass": RedactedValue,
+				"old":  RedactedValue,
+			}),
+			expectedTo: newSecret(map[string]interface{}{
+				"user": RedactedValue,
+				"pass": ChangedValue,
+				"new":  RedactedValue,
+			}),
+		},
+		{
+			name: "created secret",
+			to:   newSecret(map[string]interface{}{"pass": "c2VjcmV0"}),
+			expectedTo: newSecret(map[string]interface{}{
+				"pass": RedactedValue,
+			}),
+		},
+		{
+			name: "typed secret",
+			from: &corev1.Secret{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+				StringData: map[string]string{"pass": "secret"},
+			},
+			expectedFrom: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata": map[string]interface{}{
+					"name":              "foo",
+					"namespace":         "bar",
+					"creationTimestamp": nil,
+				},
+				"stringData": map[string]interface{}{"pass": RedactedValue},
+			}},
+		},
+		{
+			name: "sensitive paths annotation",
+			from: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Database",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						meta.AnnotationSensitivePaths: "spec.password, spec.credentials",
+					},
+				},
+				"spec": map[string]interface{}{
+					"password":    "secret",
+					"credentials": map[string]interface{}{"token": "abc"},
+					"replicas":    int64(1),
+				},
+			}},
+			to: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Database",
+				"spec": map[string]interface{}{
+					"password":    "changed",
+					"credentials": map[string]interface{}{"token": "abc"},
+					"replicas":    int64(2),
+				},
+			}},
+			expectedFrom: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Database",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						meta.AnnotationSensitivePaths: "spec.password, spec.credentials",
+					},
+				},
+				"spec": map[string]interface{}{
+					"password":    RedactedValue,
+					"credentials": map[string]interface{}{"token": RedactedValue},
+					"replicas":    int64(1),
+				},
+			}},
+			expectedTo: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Database",
+				"spec": map[string]interface{}{
+					"password":    ChangedValue,
+					"credentials": map[string]interface{}{"token": RedactedValue},
+					"replicas":    int64(2),
+				},
+			}},
+		},
+		{
+			name: "last applied configuration",
+			from: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						lastAppliedAnnotation: `{"data":{"pass":"c2VjcmV0"},"kind":"Secret"}`,
+					},
+				},
+				"data": map[string]interface{}{"pass": "c2VjcmV0"},
+			}},
+			to: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						lastAppliedAnnotation: `{"data":{"pass":"bmV3"},"kind":"Secret"}`,
+					},
+				},
+				"data": map[string]interface{}{"pass": "bmV3"},
+			}},
+			expectedFrom: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						lastAppliedAnnotation: `{"data":{"pass":"(redacted)"},"kind":"Secret"}`,
+					},
+				},
+				"data": map[string]interface{}{"pass": RedactedValue},
+			}},
+			expectedTo: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						lastAppliedAnnotation: `{"data":{"pass":"(changed)"},"kind":"Secret"}`,
+					},
+				},
+				"data": map[string]interface{}{"pass": ChangedValue},
+			}},
+		},
+		{
+			name: "malformed last applied configuration",
+			from: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"name": "foo",
+					"annotations": map[string]interface{}{
+						lastAppliedAnnotation: `{`,
+					},
+				},
+			}},
+			expectedErr: `while parsing annotation "kubectl.kubernetes.io/last-applied-configuration" of Secret "foo": unexpected end of JSON input`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, err := MaskPair(test.from, test.to)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFrom, from)
+			assert.Equal(t, test.expectedTo, to)
+		})
+	}
+}
+
+func TestMaskPair_DoesNotModifyInput(t *testing.T) {
+	from := newSecret(map[string]interface{}{"pass": "c2VjcmV0"})
+	to := newSecret(map[string]interface{}{"pass": "bmV3"})
+
+	_, _, err := MaskPair(from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, newSecret(map[string]interface{}{"pass": "c2VjcmV0"}), from)
+	assert.Equal(t, newSecret(map[string]interface{}{"pass": "bmV3"}), to)
+}
+
+func TestMask(t *testing.T) {
+	obj, err := Mask(newSecret(map[string]interface{}{"pass": "c2VjcmV0"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, newSecret(map[string]interface{}{"pass": RedactedValue}), obj)
+}
@@ -0,0 +1,238 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Action describes what happens to a resource.
+type Action string
+
+// Actions that can be contained in a Record.
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionDelete    Action = "delete"
+	ActionUnchanged Action = "unchanged"
+)
+
+// Change describes the change of a single field. Old is omitted for added
+// fields, New is omitted for removed fields.
+type Change struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Record describes the changes of a single resource. Changes are only
+// populated for updated resources.
+type Record struct {
+	Group     string   `json:"group"`
+	Version   string   `json:"version"`
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Chart     string   `json:"chart,omitempty"`
+	Action    Action   `json:"action"`
+	Changes   []Change `json:"changes,omitempty"`
+}
+
+// fieldEscaper escapes the characters of field names that have a special
+// meaning in paths.
+var fieldEscaper = strings.NewReplacer(`\`, `\\`, `.`, `\.`, `[`, `\[`)
+
+// Flusher is implemented by printers that buffer their output until Flush
+// is called.
+type Flusher interface {
+	// Flush writes the buffered output to w.
+	Flush(w io.Writer) error
+}
+
+// StructuredPrinter collects a change record for each diff subject and
+// writes them as a list in JSON or YAML format once Flush is called.
+type StructuredPrinter struct {
+	Format  string
+	Records []Record
+}
+
+// NewStructuredPrinter creates a new *StructuredPrinter for format, which
+// must be either json or yaml.
+func NewStructuredPrinter(format string) *StructuredPrinter {
+	return &StructuredPrinter{
+		Format:  format,
+		Records: make([]Record, 0),
+	}
+}
+
+// Print implements Printer. It parses the YAML documents of subject s and
+// records the changes between them. Nothing is written to w.
+func (p *StructuredPrinter) Print(s Subject, w io.Writer) error {
+	a, err := parseSubject(s.A)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing %q", s.FromFile)
+	}
+
+	b, err := parseSubject(s.B)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing %q", s.ToFile)
+	}
+
+	var record Record
+
+	switch {
+	case a == nil && b == nil:
+		return nil
+	case a == nil:
+		record = newRecord(b, ActionCreate)
+	case b == nil:
+		record = newRecord(a, ActionDelete)
+	default:
+		record = newRecord(b, ActionUnchanged)
+		record.Changes = diffFields("", a.Object, b.Object, nil)
+
+		if len(record.Changes) > 0 {
+			record.Action = ActionUpdate
+		}
+	}
+
+	p.Records = append(p.Records, record)
+
+	return nil
+}
+
+// Flush implements Flusher. It writes all records collected since the last
+// flush to w.
+func (p *StructuredPrinter) Flush(w io.Writer) error {
+	var buf []byte
+	var err error
+
+	switch p.Format {
+	case "json":
+		buf, err = json.Marshal(p.Records)
+	case "yaml":
+		buf, err = yaml.Marshal(p.Records)
+	default:
+		err = errors.Errorf("unsupported diff output format %q", p.Format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p.Records = make([]Record, 0)
+
+	if !bytes.HasSuffix(buf, []byte("\n")) {
+		buf = append(buf, '\n')
+	}
+
+	_, err = w.Write(buf)
+
+	return err
+}
+
+// parseSubject parses s into an object. It returns nil if s does not contain
+// an object.
+func parseSubject(s string) (*unstructured.Unstructured, error) {
+	var obj map[string]interface{}
+
+	err := yaml.Unmarshal([]byte(s), &obj)
+	if err != nil || len(obj) == 0 {
+		return nil, err
+	}
+
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+func newRecord(obj *unstructured.Unstructured, action Action) Record {
+	gv, _ := schema.ParseGroupVersion(obj.GetAPIVersion())
+
+	return Record{
+		Group:     gv.Group,
+		Version:   gv.Version,
+		Kind:      obj.GetKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Chart:     obj.GetLabels()[meta.LabelChartName],
+		Action:    action,
+	}
+}
+
+// diffFields recursively compares a and b and appends a change for each
+// differing field to changes. Nested fields are separated by dots in the
+// path, list elements are addressed by their index. Dots in field names are
+// escaped with a backslash, like in the paths of ignore rules.
+func diffFields(path string, a, b interface{}, changes []Change) []Change {
+	if reflect.DeepEqual(a, b) {
+		return changes
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		for _, key := range unionKeys(a, b) {
+			fieldPath := fieldEscaper.Replace(key)
+			if path != "" {
+				fieldPath = path + "." + fieldPath
+			}
+
+			changes = diffFields(fieldPath, a[key], b[key], changes)
+		}
+
+		return changes
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(a) || i < len(b); i++ {
+			var x, y interface{}
+			if i < len(a) {
+				x = a[i]
+			}
+
+			if i < len(b) {
+				y = b[i]
+			}
+
+			changes = diffFields(fmt.Sprintf("%s[%d]", path, i), x, y, changes)
+		}
+
+		return changes
+	}
+
+	return append(changes, Change{Path: path, Old: a, New: b})
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+
+	for key := range a {
+		keys = append(keys, key)
+	}
+
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,228 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	liveDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  namespace: bar
+  labels:
+    kubectl-chart/chart-name: mychart
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:v1
+`
+
+	mergedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  namespace: bar
+  labels:
+    kubectl-chart/chart-name: mychart
+spec:
+  replicas: 2
+  paused: false
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:v2
+      - name: sidecar
+        image: sidecar:v1
+`
+
+	configMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: baz
+  namespace: bar
+`
+)
+
+func TestStructuredPrinter_Print(t *testing.T) {
+	tests := []struct {
+		name        string
+		subject     Subject
+		expected    []Record
+		expectedErr string
+	}{
+		{
+			name:     "empty subject",
+			subject:  Subject{},
+			expected: []Record{},
+		},
+		{
+			name:    "created",
+			subject: Subject{B: configMap, ToFile: "v1.ConfigMap.bar.baz"},
+			expected: []Record{
+				{Version: "v1", Kind: "ConfigMap", Namespace: "bar", Name: "baz", Action: ActionCreate},
+			},
+		},
+		{
+			name:    "deleted",
+			subject: Subject{A: configMap, FromFile: "v1.ConfigMap.bar.baz"},
+			expected: []Record{
+				{Version: "v1", Kind: "ConfigMap", Namespace: "bar", Name: "baz", Action: ActionDelete},
+			},
+		},
+		{
+			name:    "unchanged",
+			subject: Subject{A: configMap, B: configMap},
+			expected: []Record{
+				{Version: "v1", Kind: "ConfigMap", Namespace: "bar", Name: "baz", Action: ActionUnchanged},
+			},
+		},
+		{
+			name:    "updated",
+			subject: Subject{A: liveDeployment, B: mergedDeployment},
+			expected: []Record{
+				{
+					Group:     "apps",
+					Version:   "v1",
+					Kind:      "Deployment",
+					Namespace: "bar",
+					Name:      "foo",
+					Chart:     "mychart",
+					Action:    ActionUpdate,
+					Changes: []Change{
+						{Path: "spec.paused", New: false},
+						{Path: "spec.replicas", Old: float64(1), New: float64(2)},
+						{Path: "spec.template.spec.containers[0].image", Old: "app:v1", New: "app:v2"},
+						{
+							Path: "spec.template.spec.containers[1]",
+							New:  map[string]interface{}{"name": "sidecar", "image": "sidecar:v1"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "updated annotation with dots",
+			subject: Subject{
+				A: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: baz\n  annotations:\n    example.com/x: foo\n",
+				B: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: baz\n  annotations:\n    example.com/x: bar\n",
+			},
+			expected: []Record{
+				{
+					Version: "v1",
+					Kind:    "ConfigMap",
+					Name:    "baz",
+					Action:  ActionUpdate,
+					Changes: []Change{
+						{Path: `metadata.annotations.example\.com/x`, Old: "foo", New: "bar"},
+					},
+				},
+			},
+		},
+		{
+			name:        "malformed subject",
+			subject:     Subject{A: "foo: [", FromFile: "foo"},
+			expectedErr: `while parsing "foo": error converting YAML to JSON: yaml: line 1: did not find expected node content`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewStructuredPrinter("json")
+
+			var buf bytes.Buffer
+
+			err := p.Print(test.subject, &buf)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, p.Records)
+			assert.Empty(t, buf.String())
+		})
+	}
+}
+
+func TestStructuredPrinter_Flush(t *testing.T) {
+	tests := []struct {
+		format      string
+		expected    string
+		expectedErr string
+	}{
+		{
+			format: "json",
+			expected: `[{"group":"apps","version":"v1","kind":"Deployment","namespace":"bar","name":"foo","chart":"mychart","action":"update","changes":[{"path":"spec.replicas","old":1,"new":2}]}]
+`,
+		},
+		{
+			format: "yaml",
+			expected: `- action: update
+  changes:
+  - new: 2
+    old: 1
+    path: spec.replicas
+  chart: mychart
+  group: apps
+  kind: Deployment
+  name: foo
+  namespace: bar
+  version: v1
+`,
+		},
+		{
+			format:      "xml",
+			expectedErr: `unsupported diff output format "xml"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			p := NewStructuredPrinter(test.format)
+			p.Records = []Record{
+				{
+					Group:     "apps",
+					Version:   "v1",
+					Kind:      "Deployment",
+					Namespace: "bar",
+					Name:      "foo",
+					Chart:     "mychart",
+					Action:    ActionUpdate,
+					Changes:   []Change{{Path: "spec.replicas", Old: 1, New: 2}},
+				},
+			}
+
+			var buf bytes.Buffer
+
+			err := p.Flush(&buf)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, buf.String())
+			assert.Empty(t, p.Records)
+		})
+	}
+}
+
+func TestStructuredPrinter_Flush_Empty(t *testing.T) {
+	p := NewStructuredPrinter("json")
+
+	var buf bytes.Buffer
+
+	require.NoError(t, p.Flush(&buf))
+	assert.Equal(t, "[]\n", buf.String())
+}
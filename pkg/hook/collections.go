@@ -59,3 +59,16 @@ func (l List) EachItem(fn func(*Hook) error) error {
 
 	return nil
 }
+
+// Filter returns a new List which only contains the hooks for which fn returns
+// true.
+func (l List) Filter(fn func(*Hook) bool) List {
+	hooks := make(List, 0)
+	for _, h := range l {
+		if fn(h) {
+			hooks = append(hooks, h)
+		}
+	}
+
+	return hooks
+}
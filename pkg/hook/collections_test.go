@@ -93,3 +93,18 @@ func TestList_EachItem(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "whoops", err.Error())
 }
+
+func TestList_Filter(t *testing.T) {
+	l := List{
+		newTestHook("foo", TypePostApply),
+		newTestHook("bar", TypePostApply),
+		newTestHook("baz", TypePostApply),
+	}
+
+	filtered := l.Filter(func(h *Hook) bool {
+		return h.GetName() != "bar"
+	})
+
+	assert.Equal(t, List{newTestHook("foo", TypePostApply), newTestHook("baz", TypePostApply)}, filtered)
+	assert.Len(t, l, 3)
+}
@@ -43,9 +43,9 @@ func NewUnsupportedKindError(kind string) UnsupportedKindError {
 // Error implements the error interface.
 func (e UnsupportedKindError) Error() string {
 	return fmt.Sprintf(
-		"unsupported hook resource kind %q, only %q is allowed",
+		"unsupported hook resource kind %q, allowed values are: %v",
 		e.Kind,
-		jobGK.Kind,
+		SupportedKinds.List(),
 	)
 }
 
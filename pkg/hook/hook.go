@@ -13,10 +13,28 @@ const (
 	TypePostDelete = "post-delete"
 	TypePreApply   = "pre-apply"
 	TypePreDelete  = "pre-delete"
+
+	// TypeApplyFailed and TypeDeleteFailed are the types of hooks that are
+	// executed if the apply or delete operation or one of the pre-hooks
+	// failed.
+	TypeApplyFailed  = "apply-failed"
+	TypeDeleteFailed = "delete-failed"
+
+	// TypeTest is the type of hooks that are never executed during apply or
+	// delete. They are only run explicitly to test a deployed chart.
+	TypeTest = "test"
 )
 
 // SupportedTypes contains all supported hook types.
-var SupportedTypes = sets.NewString(TypePostApply, TypePostDelete, TypePreApply, TypePreDelete)
+var SupportedTypes = sets.NewString(
+	TypePostApply,
+	TypePostDelete,
+	TypePreApply,
+	TypePreDelete,
+	TypeApplyFailed,
+	TypeDeleteFailed,
+	TypeTest,
+)
 
 // Hook gets executed before or after apply/delete depending on its type..
 type Hook struct {
@@ -38,4 +56,22 @@ type Hook struct {
 	// WaitTimeout sets a custom hook wait timeout. If zero, a default wait
 	// timeout will be used. Must be zero if NoWait is set to true.
 	WaitTimeout time.Duration
+
+	// Retries is the number of times a failed hook is executed again before
+	// it is treated as failed. Must be zero if NoWait is set to true.
+	Retries int
+
+	// RetryDelay is the time to wait before retrying a failed hook.
+	RetryDelay time.Duration
+
+	// Command contains the command and its arguments for local hooks. It is
+	// always empty for Job hooks.
+	Command []string
+}
+
+// IsLocal returns true if h is a local hook which executes a command on the
+// machine kubectl-chart is running on instead of creating a Job in the
+// cluster.
+func (h *Hook) IsLocal() bool {
+	return len(h.Command) > 0
 }
@@ -10,10 +10,22 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
-// jobGK is the GroupKind that a hook resource must have.
-var jobGK = schema.GroupKind{Group: "batch", Kind: "Job"}
+var (
+	// jobGK is the GroupKind of hooks that are executed as Jobs inside the
+	// cluster.
+	jobGK = schema.GroupKind{Group: "batch", Kind: "Job"}
+
+	// LocalGroupVersionKind is the GroupVersionKind of local hooks. Local
+	// hooks are never sent to the cluster, instead the command in their
+	// .spec.command field is executed on the machine running kubectl-chart.
+	LocalGroupVersionKind = schema.GroupVersionKind{Group: "kubectl-chart", Version: "v1", Kind: "LocalHook"}
+
+	// SupportedKinds contains all resource kinds that can be used as hooks.
+	SupportedKinds = sets.NewString(jobGK.Kind, LocalGroupVersionKind.Kind)
+)
 
 // MustParse wraps Parse() and panics if parsing fails.
 func MustParse(obj runtime.Object) *Hook {
@@ -32,10 +44,21 @@ func Parse(obj runtime.Object) (*Hook, error) {
 		return nil, errors.Errorf("obj is of type %T, expected *unstructured.Unstructured", obj)
 	}
 
-	if u.GetKind() != jobGK.Kind {
+	if !SupportedKinds.Has(u.GetKind()) {
 		return nil, NewUnsupportedKindError(u.GetKind())
 	}
 
+	// Only match local hooks by their full GroupVersionKind, so that custom
+	// resources that are also named LocalHook are never mistaken for
+	// commands to execute.
+	local := u.GetKind() == LocalGroupVersionKind.Kind
+	if local && u.GroupVersionKind() != LocalGroupVersionKind {
+		return nil, errors.Errorf(
+			"unsupported apiVersion %q for hook resource kind %q, expected %q",
+			u.GetAPIVersion(), u.GetKind(), LocalGroupVersionKind.GroupVersion().String(),
+		)
+	}
+
 	annotations := u.GetAnnotations()
 
 	hookType := annotations[meta.AnnotationHookType]
@@ -59,9 +82,22 @@ func Parse(obj runtime.Object) (*Hook, error) {
 		return nil, NewIllegalAnnotationCombinationError(meta.AnnotationHookNoWait, meta.AnnotationHookWaitTimeout)
 	}
 
-	restartPolicy := parseRestartPolicy(u)
-	if restartPolicy != corev1.RestartPolicyNever {
-		return nil, NewUnsupportedRestartPolicyError(restartPolicy)
+	retries, err := parseRetries(annotations[meta.AnnotationHookRetries])
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed annotation %q", meta.AnnotationHookRetries)
+	}
+
+	if noWait && retries > 0 {
+		return nil, NewIllegalAnnotationCombinationError(meta.AnnotationHookNoWait, meta.AnnotationHookRetries)
+	}
+
+	retryDelay, err := parseDuration(annotations[meta.AnnotationHookRetryDelay])
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed annotation %q", meta.AnnotationHookRetryDelay)
+	}
+
+	if _, ok := annotations[meta.AnnotationHookRetryDelay]; ok && retries == 0 {
+		return nil, errors.Errorf("annotation %q requires annotation %q to be set", meta.AnnotationHookRetryDelay, meta.AnnotationHookRetries)
 	}
 
 	h := &Hook{
@@ -70,11 +106,40 @@ func Parse(obj runtime.Object) (*Hook, error) {
 		AllowFailure: allowFailure,
 		NoWait:       noWait,
 		WaitTimeout:  waitTimeout,
+		Retries:      retries,
+		RetryDelay:   retryDelay,
+	}
+
+	if local {
+		h.Command, err = parseCommand(u)
+		if err != nil {
+			return nil, err
+		}
+
+		return h, nil
+	}
+
+	restartPolicy := parseRestartPolicy(u)
+	if restartPolicy != corev1.RestartPolicyNever {
+		return nil, NewUnsupportedRestartPolicyError(restartPolicy)
 	}
 
 	return h, nil
 }
 
+func parseCommand(obj *unstructured.Unstructured) ([]string, error) {
+	command, _, err := unstructured.NestedStringSlice(obj.Object, "spec", "command")
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed local hook command")
+	}
+
+	if len(command) == 0 {
+		return nil, errors.New("local hook command must not be empty")
+	}
+
+	return command, nil
+}
+
 func parseRestartPolicy(obj *unstructured.Unstructured) corev1.RestartPolicy {
 	value, _, _ := unstructured.NestedString(obj.Object, "spec", "template", "spec", "restartPolicy")
 
@@ -89,6 +154,23 @@ func parseDuration(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
+func parseRetries(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	retries, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if retries < 0 {
+		return 0, errors.Errorf("retries must not be negative, got %d", retries)
+	}
+
+	return retries, nil
+}
+
 func parseBool(s string) bool {
 	b, err := strconv.ParseBool(s)
 	if err != nil {
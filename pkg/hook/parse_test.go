@@ -101,7 +101,7 @@ func TestParse(t *testing.T) {
 					},
 				},
 			},
-			expectedErr: `unsupported hook resource kind "ConfigMap", only "Job" is allowed`,
+			expectedErr: `unsupported hook resource kind "ConfigMap", allowed values are: [Job LocalHook]`,
 		},
 		{
 			name: "unsupported hook type",
@@ -127,7 +127,7 @@ func TestParse(t *testing.T) {
 					},
 				},
 			},
-			expectedErr: `unsupported hook type "foo", allowed values are: [post-apply post-delete pre-apply pre-delete]`,
+			expectedErr: `unsupported hook type "foo", allowed values are: [apply-failed delete-failed post-apply post-delete pre-apply pre-delete test]`,
 		},
 		{
 			name: "conflicting annotations",
@@ -179,7 +179,7 @@ func TestParse(t *testing.T) {
 					},
 				},
 			},
-			expectedErr: `malformed annotation "kubectl-chart/hook-wait-timeout": time: invalid duration foo`,
+			expectedErr: `malformed annotation "kubectl-chart/hook-wait-timeout": time: invalid duration "foo"`,
 		},
 		{
 			name: "conflicting wait annotations",
@@ -207,6 +207,198 @@ func TestParse(t *testing.T) {
 			},
 			expectedErr: `annotations cannot be set at the same time: [kubectl-chart/hook-no-wait kubectl-chart/hook-wait-timeout]`,
 		},
+		{
+			name: "a valid hook with retries",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "batch/v1",
+					"kind":       "Job",
+					"metadata": map[string]interface{}{
+						"name":      "somehook",
+						"namespace": "bar",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:       TypePreApply,
+							meta.AnnotationHookRetries:    "3",
+							meta.AnnotationHookRetryDelay: "10s",
+						},
+					},
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"restartPolicy": "Never",
+							},
+						},
+					},
+				},
+			},
+			validateHook: func(t *testing.T, h *Hook) {
+				assert.Equal(t, 3, h.Retries)
+				assert.Equal(t, 10*time.Second, h.RetryDelay)
+			},
+		},
+		{
+			name: "negative retries",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "batch/v1",
+					"kind":       "Job",
+					"metadata": map[string]interface{}{
+						"name":      "somehook",
+						"namespace": "bar",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:    TypePreApply,
+							meta.AnnotationHookRetries: "-1",
+						},
+					},
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"restartPolicy": "Never",
+							},
+						},
+					},
+				},
+			},
+			expectedErr: `malformed annotation "kubectl-chart/hook-retries": retries must not be negative, got -1`,
+		},
+		{
+			name: "invalid retries",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "batch/v1",
+					"kind":       "Job",
+					"metadata": map[string]interface{}{
+						"name":      "somehook",
+						"namespace": "bar",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:    TypePreApply,
+							meta.AnnotationHookRetries: "foo",
+						},
+					},
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"restartPolicy": "Never",
+							},
+						},
+					},
+				},
+			},
+			expectedErr: `malformed annotation "kubectl-chart/hook-retries": strconv.Atoi: parsing "foo": invalid syntax`,
+		},
+		{
+			name: "conflicting retry annotations",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "batch/v1",
+					"kind":       "Job",
+					"metadata": map[string]interface{}{
+						"name":      "somehook",
+						"namespace": "bar",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:    TypePreApply,
+							meta.AnnotationHookRetries: "1",
+							meta.AnnotationHookNoWait:  "true",
+						},
+					},
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"restartPolicy": "Never",
+							},
+						},
+					},
+				},
+			},
+			expectedErr: `annotations cannot be set at the same time: [kubectl-chart/hook-no-wait kubectl-chart/hook-retries]`,
+		},
+		{
+			name: "retry delay without retries",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "batch/v1",
+					"kind":       "Job",
+					"metadata": map[string]interface{}{
+						"name":      "somehook",
+						"namespace": "bar",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:       TypePreApply,
+							meta.AnnotationHookRetryDelay: "10s",
+						},
+					},
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"restartPolicy": "Never",
+							},
+						},
+					},
+				},
+			},
+			expectedErr: `annotation "kubectl-chart/hook-retry-delay" requires annotation "kubectl-chart/hook-retries" to be set`,
+		},
+		{
+			name: "a valid local hook",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "kubectl-chart/v1",
+					"kind":       "LocalHook",
+					"metadata": map[string]interface{}{
+						"name": "somehook",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType:         TypePreApply,
+							meta.AnnotationHookAllowFailure: "true",
+							meta.AnnotationHookWaitTimeout:  "5m",
+						},
+					},
+					"spec": map[string]interface{}{
+						"command": []interface{}{"echo", "foo"},
+					},
+				},
+			},
+			validateHook: func(t *testing.T, h *Hook) {
+				assert.True(t, h.IsLocal())
+				assert.Equal(t, []string{"echo", "foo"}, h.Command)
+				assert.Equal(t, 5*time.Minute, h.WaitTimeout)
+				assert.True(t, h.AllowFailure)
+				assert.Equal(t, TypePreApply, h.Type)
+			},
+		},
+		{
+			name: "local hook without command",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "kubectl-chart/v1",
+					"kind":       "LocalHook",
+					"metadata": map[string]interface{}{
+						"name": "somehook",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType: TypePreApply,
+						},
+					},
+				},
+			},
+			expectedErr: `local hook command must not be empty`,
+		},
+		{
+			name: "LocalHook kind with foreign apiVersion",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "example.com/v1",
+					"kind":       "LocalHook",
+					"metadata": map[string]interface{}{
+						"name": "somehook",
+						"annotations": map[string]interface{}{
+							meta.AnnotationHookType: TypePreApply,
+						},
+					},
+					"spec": map[string]interface{}{
+						"command": []interface{}{"echo", "foo"},
+					},
+				},
+			},
+			expectedErr: `unsupported apiVersion "example.com/v1" for hook resource kind "LocalHook", expected "kubectl-chart/v1"`,
+		},
 		{
 			name: "unsupported restartPolicy field value",
 			obj: &unstructured.Unstructured{
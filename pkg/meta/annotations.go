@@ -1,14 +1,16 @@
 package meta
 
 import (
+	"strings"
+
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
 	// AnnotationHookType contains the type of the hook. If this annotation is
-	// set on a Job it will be treated as a hook and not show up as regular
-	// resource anymore.
+	// set on a Job or LocalHook it will be treated as a hook and not show up
+	// as regular resource anymore.
 	AnnotationHookType = "kubectl-chart/hook-type"
 
 	// AnnotationHookAllowFailure controls the behaviour in the event where the
@@ -29,10 +31,69 @@ const (
 	// set, wait.DefaultWaitTimeout is used.
 	AnnotationHookWaitTimeout = "kubectl-chart/hook-wait-timeout"
 
+	// AnnotationHookRetries sets the number of times a failed hook Job is
+	// deleted and created again before it is treated as failed. Hooks with
+	// retries are awaited before the next hook is executed. This cannot be
+	// used together with AnnotationHookNoWait.
+	AnnotationHookRetries = "kubectl-chart/hook-retries"
+
+	// AnnotationHookRetryDelay sets the time to wait before a failed hook is
+	// retried. Requires AnnotationHookRetries to be set.
+	AnnotationHookRetryDelay = "kubectl-chart/hook-retry-delay"
+
+	// AnnotationHookFailureReason is set on apply-failed and delete-failed
+	// hooks. It contains the error that caused the hooks to be executed.
+	AnnotationHookFailureReason = "kubectl-chart/hook-failure-reason"
+
+	// AnnotationWaitFor defines a custom condition that has to be met for a
+	// resource to be considered ready. Supported formats are
+	// "condition=<type>[=<status>]" (e.g. "condition=Ready") and
+	// "jsonpath=<expression>=<value>" (e.g.
+	// "jsonpath={.status.phase}=Running"). It is honored when waiting for
+	// resources during apply and for hooks.
+	AnnotationWaitFor = "kubectl-chart/wait-for"
+
+	// AnnotationWaitTimeout sets a custom timeout for waiting on the
+	// condition defined by AnnotationWaitFor.
+	AnnotationWaitTimeout = "kubectl-chart/wait-timeout"
+
 	// AnnotationDeletionPolicy can be set on resources to specify non-default
-	// deletion behaviour. Currently this annotation is ignored on all
-	// resources except for StatefulSets.
+	// deletion behaviour. The "retain" policy is honored on all resources,
+	// "delete-pvcs" and "delete-pvcs-on-scaledown" only on StatefulSets.
+	// Multiple policies can be combined as a comma separated list.
 	AnnotationDeletionPolicy = "kubectl-chart/deletion-policy"
+
+	// AnnotationPropagationPolicy overrides the propagation policy that is
+	// used when a resource is deleted or pruned. Valid values are
+	// "background", "foreground" and "orphan".
+	AnnotationPropagationPolicy = "kubectl-chart/propagation-policy"
+
+	// AnnotationUpdateStrategy controls how a resource is updated if the
+	// rendered manifest changes immutable fields of the live resource. If set
+	// to "recreate", the resource is deleted and created again.
+	AnnotationUpdateStrategy = "kubectl-chart/update-strategy"
+
+	// AnnotationSnapshotBeforeDelete can be set on StatefulSets to request
+	// VolumeSnapshots of their PersistentVolumeClaims before these are
+	// pruned. The value is the name of the VolumeSnapshotClass to use.
+	AnnotationSnapshotBeforeDelete = "kubectl-chart/snapshot-before-delete"
+
+	// AnnotationClaimTracking controls how the PersistentVolumeClaims of a
+	// StatefulSet are identified. Valid values are "selector" (the default)
+	// and "template".
+	AnnotationClaimTracking = "kubectl-chart/pvc-tracking"
+
+	// AnnotationSensitivePaths contains a comma separated list of dot
+	// separated field paths (e.g. "spec.password,spec.credentials") whose
+	// values are redacted in diffs. The data and stringData of Secrets are
+	// always redacted.
+	AnnotationSensitivePaths = "kubectl-chart/sensitive-paths"
+
+	// AnnotationDiffIgnorePaths contains a comma separated list of field
+	// paths (e.g. "spec.replicas,spec.template.spec.containers[name=sidecar]")
+	// which are ignored when the resource is diffed. This is useful for
+	// fields that are rewritten by controllers or admission webhooks.
+	AnnotationDiffIgnorePaths = "kubectl-chart/diff-ignore-paths"
 )
 
 // HasAnnotation returns true if an annotation key exists and has given value.
@@ -48,3 +109,25 @@ func HasAnnotation(obj runtime.Object, key string, value ...string) bool {
 
 	return found
 }
+
+// HasDeletionPolicy returns true if the kubectl-chart/deletion-policy
+// annotation of obj contains policy.
+func HasDeletionPolicy(obj runtime.Object, policy DeletionPolicy) bool {
+	metadata, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	value, found := metadata.GetAnnotations()[AnnotationDeletionPolicy]
+	if !found {
+		return false
+	}
+
+	for _, p := range strings.Split(value, ",") {
+		if strings.TrimSpace(p) == policy.String() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -23,6 +23,11 @@ const (
 	// LabelOwnedByStatefulSet is set on PersistentVolumeClaims to identify
 	// them when a StatefulSet is deleted.
 	LabelOwnedByStatefulSet = "kubectl-chart/owned-by-statefulset"
+
+	// LabelSnapshotChartName is set on VolumeSnapshots that are created
+	// before PersistentVolumeClaims are pruned. It is different from
+	// LabelChartName because snapshots must outlive the chart resources.
+	LabelSnapshotChartName = "kubectl-chart/snapshot-chart-name"
 )
 
 // AddLabel adds a label on an object. If the label already exists it will be
@@ -31,6 +31,20 @@ func TestHasAnnotation(t *testing.T) {
 	assert.True(t, HasAnnotation(obj, "foo", "bar"))
 }
 
+func TestHasDeletionPolicy(t *testing.T) {
+	obj := newUnstructured(schema.GroupVersionKind{}, nil)
+
+	assert.False(t, HasDeletionPolicy(obj, DeletionPolicyRetain))
+
+	obj = newUnstructured(schema.GroupVersionKind{}, map[string]interface{}{
+		AnnotationDeletionPolicy: "delete-pvcs, delete-pvcs-on-scaledown",
+	})
+
+	assert.True(t, HasDeletionPolicy(obj, DeletionPolicyDeletePVCs))
+	assert.True(t, HasDeletionPolicy(obj, DeletionPolicyDeletePVCsOnScaleDown))
+	assert.False(t, HasDeletionPolicy(obj, DeletionPolicyRetain))
+}
+
 func TestAddLabel(t *testing.T) {
 	obj := newUnstructured(schema.GroupVersionKind{}, nil)
 
@@ -14,4 +14,56 @@ const (
 	// kubectl-chart delete all PersistentVolumeClaims created from the
 	// StatefulSet's VolumeClaimTemplates after the StatefulSet is deleted.
 	DeletionPolicyDeletePVCs DeletionPolicy = "delete-pvcs"
+
+	// DeletionPolicyRetain can be specified in the
+	// kubectl-chart/deletion-policy annotation on any resource to prevent it
+	// from being deleted or pruned. Instead, the resource is detached from
+	// its chart by removing the chart label.
+	DeletionPolicyRetain DeletionPolicy = "retain"
+
+	// DeletionPolicyDeletePVCsOnScaleDown can be specified in the
+	// kubectl-chart/deletion-policy annotation on StatefulSets to make
+	// kubectl-chart delete the PersistentVolumeClaims of replicas that were
+	// removed by scaling down the StatefulSet.
+	DeletionPolicyDeletePVCsOnScaleDown DeletionPolicy = "delete-pvcs-on-scaledown"
+)
+
+// UpdateStrategy controls behaviour on resource updates.
+type UpdateStrategy string
+
+// String implements fmt.Stringer.
+func (s UpdateStrategy) String() string {
+	return string(s)
+}
+
+const (
+	// UpdateStrategyRecreate can be specified in the
+	// kubectl-chart/update-strategy annotation on any resource to make
+	// kubectl-chart delete and create it again if changes to immutable
+	// fields prevent it from being updated.
+	UpdateStrategyRecreate UpdateStrategy = "recreate"
+)
+
+// ClaimTracking controls how PersistentVolumeClaims created from the
+// volumeClaimTemplates of a StatefulSet are identified.
+type ClaimTracking string
+
+// String implements fmt.Stringer.
+func (t ClaimTracking) String() string {
+	return string(t)
+}
+
+const (
+	// ClaimTrackingSelector is the default claim tracking mode. The
+	// kubectl-chart/owned-by-statefulset label is injected into the
+	// StatefulSet's selector, pod template and volumeClaimTemplates and
+	// claims are identified by that label.
+	ClaimTrackingSelector ClaimTracking = "selector"
+
+	// ClaimTrackingTemplate can be specified in the kubectl-chart/pvc-tracking
+	// annotation on StatefulSets to leave their selector and pod template
+	// untouched. The kubectl-chart/owned-by-statefulset label is only added
+	// to the volumeClaimTemplates and claims are identified by the
+	// <template>-<statefulset>-<ordinal> naming convention.
+	ClaimTrackingTemplate ClaimTracking = "template"
 )
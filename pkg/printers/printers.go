@@ -65,6 +65,8 @@ var colorMap = map[string]colorFunc{
 	"created":    color.GreenString,
 	"deleted":    color.RedString,
 	"pruned":     color.RedString,
+	"replaced":   color.MagentaString,
+	"retained":   color.BlueString,
 	"triggered":  color.CyanString,
 }
 
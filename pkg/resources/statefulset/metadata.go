@@ -2,12 +2,20 @@ package statefulset
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/resources"
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
 )
 
 var (
@@ -25,6 +33,10 @@ var (
 // while as of 1.15 labels from the volumeClaimTemplate's metadata are also
 // supported (and take precedence). So we just set them all to be sure that we
 // have a way to identify PVCs created from the volumeClaimTemplates later.
+//
+// If obj uses the template claim tracking mode, it is left untouched as the
+// selector and the volumeClaimTemplates are immutable. This allows adopting
+// StatefulSets that were deployed by other tools.
 func AddOwnerLabels(obj runtime.Object) error {
 	u, ok := obj.(*unstructured.Unstructured)
 	if !ok {
@@ -37,7 +49,16 @@ func AddOwnerLabels(obj runtime.Object) error {
 		return errors.Errorf("obj %q is of GroupKind %q, expected %q", name, u.GroupVersionKind().GroupKind(), statefulSetGK)
 	}
 
-	err := setNestedStringMapKey(u.Object, meta.LabelOwnedByStatefulSet, name, "spec", "selector", "matchLabels")
+	tracking, err := claimTracking(u)
+	if err != nil {
+		return err
+	}
+
+	if tracking == meta.ClaimTrackingTemplate {
+		return nil
+	}
+
+	err = setNestedStringMapKey(u.Object, meta.LabelOwnedByStatefulSet, name, "spec", "selector", "matchLabels")
 	if err != nil {
 		return errors.Wrapf(err, "while setting labels for StatefulSet %q", name)
 	}
@@ -72,6 +93,132 @@ func AddOwnerLabels(obj runtime.Object) error {
 	return nil
 }
 
+// NeedsClaimTrackingMigration returns true if the local StatefulSet uses the
+// template claim tracking mode while the selector of its live counterpart
+// still contains the kubectl-chart/owned-by-statefulset label. As the
+// selector is immutable, the StatefulSet has to be recreated to migrate it.
+func NeedsClaimTrackingMigration(local, live runtime.Object) bool {
+	if !meta.HasGroupKind(local, statefulSetGK) {
+		return false
+	}
+
+	tracking, err := claimTracking(local)
+	if err != nil || tracking != meta.ClaimTrackingTemplate {
+		return false
+	}
+
+	u, ok := live.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+
+	matchLabels, _, _ := unstructured.NestedStringMap(u.Object, "spec", "selector", "matchLabels")
+
+	_, found := matchLabels[meta.LabelOwnedByStatefulSet]
+
+	return found
+}
+
+// claimTracking returns the claim tracking mode of StatefulSet obj. It
+// returns an error if the kubectl-chart/pvc-tracking annotation contains an
+// invalid value.
+func claimTracking(obj runtime.Object) (meta.ClaimTracking, error) {
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+
+	value, found := metadata.GetAnnotations()[meta.AnnotationClaimTracking]
+	if !found {
+		return meta.ClaimTrackingSelector, nil
+	}
+
+	switch tracking := meta.ClaimTracking(value); tracking {
+	case meta.ClaimTrackingSelector, meta.ClaimTrackingTemplate:
+		return tracking, nil
+	default:
+		return "", errors.Errorf(
+			"invalid value %q for annotation %q on StatefulSet %q, must be one of %q, %q",
+			value,
+			meta.AnnotationClaimTracking,
+			metadata.GetName(),
+			meta.ClaimTrackingSelector,
+			meta.ClaimTrackingTemplate,
+		)
+	}
+}
+
+// listClaims returns the PersistentVolumeClaims created from the
+// volumeClaimTemplates of statefulSet in namespace. Depending on the claim
+// tracking mode of statefulSet, claims are either identified by the
+// kubectl-chart/owned-by-statefulset label or by the labels of the
+// volumeClaimTemplates and the <template>-<statefulset>-<ordinal> naming
+// convention.
+func listClaims(client dynamic.Interface, mapping *kmeta.RESTMapping, statefulSet *unstructured.Unstructured, namespace string) ([]unstructured.Unstructured, error) {
+	tracking, err := claimTracking(statefulSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if tracking == meta.ClaimTrackingSelector {
+		return listClaimsBySelector(client, mapping, namespace, persistentVolumeClaimSelector(statefulSet.GetName()))
+	}
+
+	vcts, err := volumeClaimTemplates(statefulSet)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(vcts))
+	for name := range vcts {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	claims := make([]unstructured.Unstructured, 0)
+
+	for _, name := range names {
+		// The StatefulSet controller copies the labels of the
+		// volumeClaimTemplate to the claims created from it.
+		templateLabels, _, err := unstructured.NestedStringMap(vcts[name], "metadata", "labels")
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading labels of volumeClaimTemplate %q", name)
+		}
+
+		items, err := listClaimsBySelector(client, mapping, namespace, labels.SelectorFromSet(templateLabels).String())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, claim := range items {
+			if _, ok := templateClaimOrdinal(claim.GetName(), name, statefulSet.GetName()); ok {
+				claims = append(claims, claim)
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+// listClaimsBySelector returns the PersistentVolumeClaims in namespace that
+// match selector.
+func listClaimsBySelector(client dynamic.Interface, mapping *kmeta.RESTMapping, namespace, selector string) ([]unstructured.Unstructured, error) {
+	list, err := client.
+		Resource(mapping.Resource).
+		Namespace(namespace).
+		List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if list == nil {
+		return nil, nil
+	}
+
+	return list.Items, nil
+}
+
 // setNestedStringMapKey sets the key in the nested map identified by fields to
 // given string value. If the map does not exist it will be created.
 func setNestedStringMapKey(obj map[string]interface{}, key, value string, fields ...string) error {
@@ -94,3 +241,13 @@ func setNestedStringMapKey(obj map[string]interface{}, key, value string, fields
 func persistentVolumeClaimSelector(statefulSetName string) string {
 	return fmt.Sprintf("%s=%s", meta.LabelOwnedByStatefulSet, statefulSetName)
 }
+
+// toInfoList converts claims into a list of infos.
+func toInfoList(claims []unstructured.Unstructured, mapper kmeta.RESTMapper) ([]*resource.Info, error) {
+	objs := make([]runtime.Object, len(claims))
+	for i := range claims {
+		objs[i] = &claims[i]
+	}
+
+	return resources.ToInfoList(objs, mapper)
+}
@@ -7,8 +7,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 func TestAddOwnerLabels(t *testing.T) {
@@ -417,6 +421,94 @@ func TestAddOwnerLabels(t *testing.T) {
 			expectedErr: "obj is of type *v1.StatefulSet, expected *unstructured.Unstructured",
 			obj:         &appsv1.StatefulSet{},
 		},
+		{
+			name: "template claim tracking leaves StatefulSet untouched",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "StatefulSet",
+					"metadata": map[string]interface{}{
+						"name":      "foobar",
+						"namespace": "foo",
+						"annotations": map[string]interface{}{
+							meta.AnnotationClaimTracking: "template",
+						},
+					},
+					"spec": map[string]interface{}{
+						"selector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{
+								"foo": "bar",
+							},
+						},
+						"template": map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"labels": map[string]interface{}{
+									"foo": "bar",
+								},
+							},
+						},
+						"volumeClaimTemplates": []interface{}{
+							map[string]interface{}{
+								"metadata": map[string]interface{}{
+									"name": "baz",
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "StatefulSet",
+					"metadata": map[string]interface{}{
+						"name":      "foobar",
+						"namespace": "foo",
+						"annotations": map[string]interface{}{
+							meta.AnnotationClaimTracking: "template",
+						},
+					},
+					"spec": map[string]interface{}{
+						"selector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{
+								"foo": "bar",
+							},
+						},
+						"template": map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"labels": map[string]interface{}{
+									"foo": "bar",
+								},
+							},
+						},
+						"volumeClaimTemplates": []interface{}{
+							map[string]interface{}{
+								"metadata": map[string]interface{}{
+									"name": "baz",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:        "invalid claim tracking mode",
+			expectedErr: `invalid value "labels" for annotation "kubectl-chart/pvc-tracking" on StatefulSet "foobar", must be one of "selector", "template"`,
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "StatefulSet",
+					"metadata": map[string]interface{}{
+						"name":      "foobar",
+						"namespace": "foo",
+						"annotations": map[string]interface{}{
+							meta.AnnotationClaimTracking: "labels",
+						},
+					},
+				},
+			},
+		},
 		{
 			name:        "wrong GroupKind",
 			expectedErr: `obj "foobar" is of GroupKind "Job.batch", expected "StatefulSet.apps"`,
@@ -446,3 +538,168 @@ func TestAddOwnerLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestNeedsClaimTrackingMigration(t *testing.T) {
+	newStatefulSet := func(tracking string, selectorLabels map[string]interface{}) *unstructured.Unstructured {
+		obj := newUnstructured("apps/v1", "StatefulSet", "foo", "db")
+		if tracking != "" {
+			obj.SetAnnotations(map[string]string{meta.AnnotationClaimTracking: tracking})
+		}
+
+		obj.Object["spec"] = map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": selectorLabels,
+			},
+		}
+
+		return obj
+	}
+
+	ownerLabels := map[string]interface{}{"app": "db", meta.LabelOwnedByStatefulSet: "db"}
+	appLabels := map[string]interface{}{"app": "db"}
+
+	tests := []struct {
+		name     string
+		local    runtime.Object
+		live     runtime.Object
+		expected bool
+	}{
+		{
+			name:  "selector tracking",
+			local: newStatefulSet("", ownerLabels),
+			live:  newStatefulSet("", ownerLabels),
+		},
+		{
+			name:     "template tracking with owner label in live selector",
+			local:    newStatefulSet("template", appLabels),
+			live:     newStatefulSet("", ownerLabels),
+			expected: true,
+		},
+		{
+			name:  "template tracking already migrated",
+			local: newStatefulSet("template", appLabels),
+			live:  newStatefulSet("template", appLabels),
+		},
+		{
+			name:  "not a StatefulSet",
+			local: newUnstructured("apps/v1", "Deployment", "foo", "db"),
+			live:  newStatefulSet("", ownerLabels),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, NeedsClaimTrackingMigration(test.local, test.live))
+		})
+	}
+}
+
+func TestListClaims(t *testing.T) {
+	claims := []*unstructured.Unstructured{
+		newClaimWithStorage("data-db-0", "db", "1Gi"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-db-1"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-other-0"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-db-canary-0"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-db-2"),
+	}
+
+	claims[4].SetLabels(map[string]string{"app": "db"})
+
+	tests := []struct {
+		name             string
+		tracking         string
+		templateLabels   map[string]interface{}
+		expectedSelector string
+		expected         []string
+	}{
+		{
+			name:             "selector tracking",
+			expectedSelector: "kubectl-chart/owned-by-statefulset=db",
+			expected:         []string{"data-db-0"},
+		},
+		{
+			name:     "template tracking",
+			tracking: "template",
+			expected: []string{"data-db-0", "data-db-1", "data-db-2"},
+		},
+		{
+			name:             "template tracking with template labels",
+			tracking:         "template",
+			templateLabels:   map[string]interface{}{"app": "db"},
+			expectedSelector: "app=db",
+			expected:         []string{"data-db-2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme, claims[0], claims[1], claims[2], claims[3], claims[4])
+
+			obj := newStatefulSetWithStorage("db", "1Gi")
+			if test.tracking != "" {
+				obj.SetAnnotations(map[string]string{meta.AnnotationClaimTracking: test.tracking})
+			}
+
+			if test.templateLabels != nil {
+				vct := obj.Object["spec"].(map[string]interface{})["volumeClaimTemplates"].([]interface{})[0]
+				unstructured.SetNestedField(vct.(map[string]interface{}), test.templateLabels, "metadata", "labels")
+			}
+
+			mapping, err := testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme).RESTMapping(persistentVolumeClaimGK)
+			require.NoError(t, err)
+
+			items, err := listClaims(fakeClient, mapping, obj, "foo")
+			require.NoError(t, err)
+
+			names := make([]string, len(items))
+			for i, item := range items {
+				names[i] = item.GetName()
+			}
+
+			assert.ElementsMatch(t, test.expected, names)
+
+			actions := fakeClient.Actions()
+			require.Len(t, actions, 1)
+			assert.Equal(t, test.expectedSelector, actions[0].(clienttesting.ListAction).GetListRestrictions().Labels.String())
+		})
+	}
+}
+
+func TestAddOwnerLabels_AdoptTemplateTracking(t *testing.T) {
+	claims := []runtime.Object{
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-db-0"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-db-1"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-db-backup"),
+		newUnstructured("v1", "PersistentVolumeClaim", "foo", "data-web-0"),
+	}
+
+	for _, claim := range claims {
+		claim.(*unstructured.Unstructured).SetLabels(map[string]string{"app": "db"})
+	}
+
+	obj := newStatefulSetWithStorage("db", "1Gi")
+	obj.SetAnnotations(map[string]string{meta.AnnotationClaimTracking: "template"})
+
+	vct := obj.Object["spec"].(map[string]interface{})["volumeClaimTemplates"].([]interface{})[0]
+	unstructured.SetNestedField(vct.(map[string]interface{}), map[string]interface{}{"app": "db"}, "metadata", "labels")
+
+	expected := obj.DeepCopy()
+
+	require.NoError(t, AddOwnerLabels(obj))
+	assert.Equal(t, expected, obj)
+
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme, claims...)
+
+	mapping, err := testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme).RESTMapping(persistentVolumeClaimGK)
+	require.NoError(t, err)
+
+	items, err := listClaims(fakeClient, mapping, obj, "foo")
+	require.NoError(t, err)
+
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.GetName()
+	}
+
+	assert.ElementsMatch(t, []string{"data-db-0", "data-db-1"}, names)
+}
@@ -1,23 +1,36 @@
 package statefulset
 
 import (
+	"context"
+
 	"github.com/martinohmann/kubectl-chart/pkg/deletions"
 	"github.com/martinohmann/kubectl-chart/pkg/meta"
-	"github.com/martinohmann/kubectl-chart/pkg/resources"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/pkg/errors"
 	kmeta "k8s.io/apimachinery/pkg/api/meta"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
 )
 
 // PersistentVolumeClaimPruner prunes PersistentVolumeClaims of deleted
-// StatefulSets.
+// StatefulSets. If the StatefulSet has the kubectl-chart/snapshot-before-delete
+// annotation, its claims are snapshotted before they are deleted.
 type PersistentVolumeClaimPruner struct {
 	Deleter       deletions.Deleter
 	DynamicClient dynamic.Interface
 	Mapper        kmeta.RESTMapper
+
+	// Waiter is used to wait for the pods of removed replicas to be gone
+	// before their claims are pruned on scale-down. Waiting is skipped if
+	// nil.
+	Waiter wait.Waiter
+
+	// Snapshotter is used to create VolumeSnapshots of claims before they are
+	// deleted. Pruning claims of StatefulSets that request snapshots fails if
+	// nil.
+	Snapshotter *VolumeSnapshotter
 }
 
 // NewPersistentVolumeClaimPruner creates a new PersistentVolumeClaimPruner value.
@@ -33,8 +46,53 @@ func NewPersistentVolumeClaimPruner(client dynamic.Interface, deleter deletions.
 // a deletion policy that requests the deletion of all PersistentVolumeClaims
 // associated with the StatefulSet once it is deleted and prunes them. It is
 // required that the object slice only contains objects of type
-// *unstructured.Unstructured.
-func (p *PersistentVolumeClaimPruner) PruneClaims(objs []runtime.Object) error {
+// *unstructured.Unstructured. Pruning stops once ctx is cancelled.
+func (p *PersistentVolumeClaimPruner) PruneClaims(ctx context.Context, objs []runtime.Object) error {
+	return p.visitClaims(ctx, objs, func(obj runtime.Object, infos []*resource.Info) error {
+		return p.deleteClaims(ctx, obj, infos)
+	})
+}
+
+// FindClaims returns the PersistentVolumeClaims that PruneClaims would delete
+// for the slice of runtime objects without deleting them.
+func (p *PersistentVolumeClaimPruner) FindClaims(objs []runtime.Object) ([]*resource.Info, error) {
+	claims := make([]*resource.Info, 0)
+
+	err := p.visitClaims(context.Background(), objs, func(_ runtime.Object, infos []*resource.Info) error {
+		claims = append(claims, infos...)
+		return nil
+	})
+
+	return claims, err
+}
+
+// deleteClaims deletes the claims of StatefulSet obj. If obj requests it,
+// the claims are snapshotted first and only deleted once all snapshots are
+// ready to use.
+func (p *PersistentVolumeClaimPruner) deleteClaims(ctx context.Context, obj runtime.Object, claims []*resource.Info) error {
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	className, ok := metadata.GetAnnotations()[meta.AnnotationSnapshotBeforeDelete]
+	if ok && len(claims) > 0 {
+		if p.Snapshotter == nil {
+			return errors.Errorf("cannot snapshot PersistentVolumeClaims of StatefulSet %q: no snapshotter configured", metadata.GetName())
+		}
+
+		err = p.Snapshotter.Snapshot(ctx, obj, claims, className)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.Deleter.Delete(ctx, resource.InfoListVisitor(claims))
+}
+
+// visitClaims calls fn with each StatefulSet in objs that requests the
+// deletion of its PersistentVolumeClaims and the claims.
+func (p *PersistentVolumeClaimPruner) visitClaims(ctx context.Context, objs []runtime.Object, fn func(runtime.Object, []*resource.Info) error) error {
 	if len(objs) == 0 {
 		return nil
 	}
@@ -45,11 +103,24 @@ func (p *PersistentVolumeClaimPruner) PruneClaims(objs []runtime.Object) error {
 	}
 
 	for _, obj := range objs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if !meta.HasGroupKind(obj, statefulSetGK) {
 			continue
 		}
 
-		err := p.pruneClaims(obj, mapping)
+		if !meta.HasDeletionPolicy(obj, meta.DeletionPolicyDeletePVCs) {
+			continue
+		}
+
+		infos, err := p.findClaims(obj, mapping)
+		if err != nil {
+			return err
+		}
+
+		err = fn(obj, infos)
 		if err != nil {
 			return err
 		}
@@ -58,27 +129,16 @@ func (p *PersistentVolumeClaimPruner) PruneClaims(objs []runtime.Object) error {
 	return nil
 }
 
-func (p *PersistentVolumeClaimPruner) pruneClaims(obj runtime.Object, mapping *kmeta.RESTMapping) error {
-	if !meta.HasAnnotation(obj, meta.AnnotationDeletionPolicy, meta.DeletionPolicyDeletePVCs.String()) {
-		return nil
+func (p *PersistentVolumeClaimPruner) findClaims(obj runtime.Object, mapping *kmeta.RESTMapping) ([]*resource.Info, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, errors.Errorf("obj is of type %T, expected *unstructured.Unstructured", obj)
 	}
 
-	metadata, _ := kmeta.Accessor(obj)
-
-	objs, err := p.DynamicClient.
-		Resource(mapping.Resource).
-		Namespace(metav1.NamespaceAll).
-		List(metav1.ListOptions{
-			LabelSelector: persistentVolumeClaimSelector(metadata.GetName()),
-		})
-	if err != nil && !apierrors.IsNotFound(err) {
-		return err
-	}
-
-	infos, err := resources.ToInfoList(objs, p.Mapper)
+	claims, err := listClaims(p.DynamicClient, mapping, u, u.GetNamespace())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return p.Deleter.Delete(resource.InfoListVisitor(infos))
+	return toInfoList(claims, p.Mapper)
 }
@@ -1,12 +1,15 @@
 package statefulset
 
 import (
+	"context"
 	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/martinohmann/kubectl-chart/pkg/deletions"
 	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -186,7 +189,7 @@ func TestPersistentVolumeClaimPruner_PruneClaims(t *testing.T) {
 				testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
 			)
 
-			err := pruner.PruneClaims(test.objs)
+			err := pruner.PruneClaims(context.Background(), test.objs)
 			switch {
 			case err == nil && len(test.expectedErr) == 0:
 			case err != nil && len(test.expectedErr) == 0:
@@ -207,3 +210,53 @@ func TestPersistentVolumeClaimPruner_PruneClaims(t *testing.T) {
 		})
 	}
 }
+
+func TestPersistentVolumeClaimPruner_FindClaims(t *testing.T) {
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+	fakeClient.PrependReactor("list", "persistentvolumeclaims", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+		pvc := newUnstructured("v1", "PersistentVolumeClaim", "ns-foo", "name-foo")
+		pvc.SetLabels(map[string]string{meta.LabelOwnedByStatefulSet: "foo"})
+
+		return true, newUnstructuredList(pvc), nil
+	})
+
+	deleter := deletions.NewFakeDeleter()
+
+	pruner := NewPersistentVolumeClaimPruner(
+		fakeClient,
+		deleter,
+		testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+	)
+
+	objs := []runtime.Object{
+		&unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						meta.AnnotationDeletionPolicy: meta.DeletionPolicyDeletePVCs.String(),
+					},
+					"name": "foo",
+				},
+			},
+		},
+		&unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+				"metadata": map[string]interface{}{
+					"name": "baz",
+				},
+			},
+		},
+	}
+
+	claims, err := pruner.FindClaims(objs)
+
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	assert.Equal(t, "name-foo", claims[0].Name)
+	assert.Equal(t, "ns-foo", claims[0].Namespace)
+	assert.Equal(t, 0, deleter.Called)
+}
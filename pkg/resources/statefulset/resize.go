@@ -0,0 +1,497 @@
+package statefulset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	kresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+)
+
+var storageClassGVR = schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}
+
+// RecreateWaitTimeout is the maximum time to wait for a StatefulSet that is
+// recreated because of resized volumeClaimTemplates to be deleted.
+var RecreateWaitTimeout = 5 * time.Minute
+
+// TemplateResize describes the change of the storage request of a
+// volumeClaimTemplate.
+type TemplateResize struct {
+	Name     string
+	From, To resource.Quantity
+}
+
+// ClaimResize describes the change of the storage request of a live
+// PersistentVolumeClaim.
+type ClaimResize struct {
+	Claim    *unstructured.Unstructured
+	From, To resource.Quantity
+}
+
+// Resized returns a copy of the claim with the new storage request.
+func (r *ClaimResize) Resized() *unstructured.Unstructured {
+	obj := r.Claim.DeepCopy()
+
+	setStorageRequest(obj.Object, r.To)
+
+	return obj
+}
+
+// Resize describes the changes that are necessary to apply a StatefulSet
+// whose volumeClaimTemplates request more storage than those of the live
+// StatefulSet.
+type Resize struct {
+	StatefulSet *unstructured.Unstructured
+	Templates   []TemplateResize
+	Claims      []ClaimResize
+}
+
+// Matches returns true if namespace and name identify the StatefulSet
+// described by r.
+func (r *Resize) Matches(namespace, name string) bool {
+	return r.StatefulSet.GetNamespace() == namespace && r.StatefulSet.GetName() == name
+}
+
+// Revert sets the storage requests of the resized volumeClaimTemplates of
+// StatefulSet obj back to the values of the live StatefulSet.
+func (r *Resize) Revert(obj *unstructured.Unstructured) error {
+	return r.setTemplateSizes(obj, func(t TemplateResize) resource.Quantity { return t.From })
+}
+
+// Apply sets the storage requests of the resized volumeClaimTemplates of
+// StatefulSet obj to the new values.
+func (r *Resize) Apply(obj *unstructured.Unstructured) error {
+	return r.setTemplateSizes(obj, func(t TemplateResize) resource.Quantity { return t.To })
+}
+
+func (r *Resize) setTemplateSizes(obj *unstructured.Unstructured, sizeFn func(TemplateResize) resource.Quantity) error {
+	vcts, err := volumeClaimTemplates(obj)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range r.Templates {
+		vct, ok := vcts[t.Name]
+		if !ok {
+			continue
+		}
+
+		setStorageRequest(vct, sizeFn(t))
+	}
+
+	return nil
+}
+
+// PersistentVolumeClaimResizer grows the PersistentVolumeClaims of
+// StatefulSets whose volumeClaimTemplates request more storage than before.
+// As volumeClaimTemplates are immutable, the StatefulSet is deleted with
+// orphan propagation afterwards so that it can be recreated with the new
+// template without affecting its pods.
+type PersistentVolumeClaimResizer struct {
+	genericclioptions.IOStreams
+	DynamicClient dynamic.Interface
+	Mapper        kmeta.RESTMapper
+	Printer       printers.ContextPrinter
+	Waiter        wait.Waiter
+
+	// DryRun if enabled, resizing is only simulated and printed.
+	DryRun bool
+}
+
+// NewPersistentVolumeClaimResizer creates a new PersistentVolumeClaimResizer
+// value.
+func NewPersistentVolumeClaimResizer(streams genericclioptions.IOStreams, client dynamic.Interface, mapper kmeta.RESTMapper, printer printers.ContextPrinter, dryRun bool) *PersistentVolumeClaimResizer {
+	return &PersistentVolumeClaimResizer{
+		IOStreams:     streams,
+		DynamicClient: client,
+		Mapper:        mapper,
+		Printer:       printer,
+		Waiter:        wait.NewSilentWaiter(streams),
+		DryRun:        dryRun,
+	}
+}
+
+// FindResizes compares the StatefulSets contained in objs with their live
+// counterparts and returns the resizes that are required to apply them.
+// Objects without namespace are looked up in namespace. Only growing
+// volumeClaimTemplates are considered as PersistentVolumeClaims cannot
+// shrink.
+func (r *PersistentVolumeClaimResizer) FindResizes(objs []runtime.Object, namespace string) ([]*Resize, error) {
+	resizes := make([]*Resize, 0)
+
+	for _, obj := range objs {
+		if !meta.HasGroupKind(obj, statefulSetGK) {
+			continue
+		}
+
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return nil, errors.Errorf("obj is of type %T, expected *unstructured.Unstructured", obj)
+		}
+
+		resize, err := r.findResize(u, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		if resize != nil {
+			resizes = append(resizes, resize)
+		}
+	}
+
+	return resizes, nil
+}
+
+func (r *PersistentVolumeClaimResizer) findResize(obj *unstructured.Unstructured, namespace string) (*Resize, error) {
+	if obj.GetNamespace() != "" {
+		namespace = obj.GetNamespace()
+	}
+
+	mapping, err := r.Mapper.RESTMapping(statefulSetGK)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := r.DynamicClient.
+		Resource(mapping.Resource).
+		Namespace(namespace).
+		Get(obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	templates, err := templateResizes(obj, live)
+	if err != nil || len(templates) == 0 {
+		return nil, err
+	}
+
+	claims, err := r.claimResizes(live, templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Resize{
+		StatefulSet: live,
+		Templates:   templates,
+		Claims:      claims,
+	}, nil
+}
+
+func (r *PersistentVolumeClaimResizer) claimResizes(statefulSet *unstructured.Unstructured, templates []TemplateResize) ([]ClaimResize, error) {
+	mapping, err := r.Mapper.RESTMapping(persistentVolumeClaimGK)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := listClaims(r.DynamicClient, mapping, statefulSet, statefulSet.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]ClaimResize, 0)
+
+	for _, t := range templates {
+		for i := range items {
+			claim := &items[i]
+
+			// Claims of other StatefulSets may share the prefix, e.g.
+			// data-web-canary-0 for the data template of web.
+			if _, ok := templateClaimOrdinal(claim.GetName(), t.Name, statefulSet.GetName()); !ok {
+				continue
+			}
+
+			size, found, err := storageRequest(claim.Object)
+			if err != nil {
+				return nil, errors.Wrapf(err, "while reading storage request of PersistentVolumeClaim %q", claim.GetName())
+			}
+
+			if found && size.Cmp(t.To) >= 0 {
+				continue
+			}
+
+			claims = append(claims, ClaimResize{Claim: claim, From: size, To: t.To})
+		}
+	}
+
+	return claims, nil
+}
+
+// Resize grows the PersistentVolumeClaims of all resizes and deletes the
+// affected StatefulSets with orphan propagation so that they can be
+// recreated. It returns an error before changing anything if the
+// StorageClass of any PersistentVolumeClaim does not allow volume expansion.
+func (r *PersistentVolumeClaimResizer) Resize(ctx context.Context, resizes []*Resize) error {
+	for _, resize := range resizes {
+		for _, c := range resize.Claims {
+			err := r.checkExpansion(c.Claim)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, resize := range resizes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := r.resize(ctx, resize)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PersistentVolumeClaimResizer) resize(ctx context.Context, resize *Resize) error {
+	mapping, err := r.Mapper.RESTMapping(persistentVolumeClaimGK)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range resize.Claims {
+		if !r.DryRun {
+			patch := fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q}}}}`, c.To.String())
+
+			_, err := r.DynamicClient.
+				Resource(mapping.Resource).
+				Namespace(c.Claim.GetNamespace()).
+				Patch(c.Claim.GetName(), types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+			if err != nil {
+				return errors.Wrapf(err, "while resizing PersistentVolumeClaim %q", c.Claim.GetName())
+			}
+		}
+
+		p := r.Printer.WithOperation("resized").WithContext(fmt.Sprintf("%s -> %s", c.From.String(), c.To.String()))
+
+		err := p.PrintObj(c.Claim, r.Out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.orphan(ctx, resize.StatefulSet)
+}
+
+// orphan deletes obj with orphan propagation and waits until it is gone.
+func (r *PersistentVolumeClaimResizer) orphan(ctx context.Context, obj *unstructured.Unstructured) error {
+	mapping, err := r.Mapper.RESTMapping(statefulSetGK)
+	if err != nil {
+		return err
+	}
+
+	if !r.DryRun {
+		policy := metav1.DeletePropagationOrphan
+
+		err = r.DynamicClient.
+			Resource(mapping.Resource).
+			Namespace(obj.GetNamespace()).
+			Delete(obj.GetName(), &metav1.DeleteOptions{PropagationPolicy: &policy})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "while deleting StatefulSet %q for recreation", obj.GetName())
+		}
+	}
+
+	p := r.Printer.WithOperation("deleted").WithContext("propagation=orphan")
+
+	err = p.PrintObj(obj, r.Out)
+	if err != nil || r.DryRun || r.Waiter == nil {
+		return err
+	}
+
+	info := &kresource.Info{
+		Mapping:   mapping,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Object:    obj,
+	}
+
+	uidMap := wait.UIDMap{
+		wait.ResourceLocation{
+			GroupResource: mapping.Resource.GroupResource(),
+			Namespace:     info.Namespace,
+			Name:          info.Name,
+		}: obj.GetUID(),
+	}
+
+	return r.Waiter.Wait(ctx, &wait.Request{
+		ConditionFn: wait.NewDeletedConditionFunc(r.DynamicClient, r.ErrOut, uidMap, 0),
+		Options:     &wait.Options{Timeout: RecreateWaitTimeout},
+		Visitor:     kresource.InfoListVisitor([]*kresource.Info{info}),
+		Timeout:     RecreateWaitTimeout,
+	})
+}
+
+// Restore creates the StatefulSets of resizes again if they do not exist
+// anymore. It is used to recover from a failed apply after Resize deleted
+// them, so that their orphaned pods are adopted again. The resized
+// PersistentVolumeClaims are left as they are since they cannot shrink. This
+// is a no-op in dry run mode.
+func (r *PersistentVolumeClaimResizer) Restore(resizes []*Resize) error {
+	if r.DryRun || len(resizes) == 0 {
+		return nil
+	}
+
+	mapping, err := r.Mapper.RESTMapping(statefulSetGK)
+	if err != nil {
+		return err
+	}
+
+	for _, resize := range resizes {
+		obj := resize.StatefulSet.DeepCopy()
+		obj.SetResourceVersion("")
+		obj.SetUID("")
+		obj.SetSelfLink("")
+		obj.SetGeneration(0)
+		obj.SetCreationTimestamp(metav1.Time{})
+		obj.SetDeletionTimestamp(nil)
+		unstructured.RemoveNestedField(obj.Object, "status")
+
+		_, err := r.DynamicClient.
+			Resource(mapping.Resource).
+			Namespace(obj.GetNamespace()).
+			Create(obj, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "while restoring StatefulSet %q", obj.GetName())
+		}
+
+		err = r.Printer.WithOperation("restored").PrintObj(obj, r.Out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkExpansion returns an error if the StorageClass of claim does not allow
+// volume expansion.
+func (r *PersistentVolumeClaimResizer) checkExpansion(claim *unstructured.Unstructured) error {
+	className, _, _ := unstructured.NestedString(claim.Object, "spec", "storageClassName")
+	if className == "" {
+		return errors.Errorf("cannot resize PersistentVolumeClaim %q: it does not have a StorageClass", claim.GetName())
+	}
+
+	storageClass, err := r.DynamicClient.Resource(storageClassGVR).Get(className, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "cannot resize PersistentVolumeClaim %q", claim.GetName())
+	}
+
+	allowed, _, _ := unstructured.NestedBool(storageClass.Object, "allowVolumeExpansion")
+	if !allowed {
+		return errors.Errorf("cannot resize PersistentVolumeClaim %q: StorageClass %q does not allow volume expansion", claim.GetName(), className)
+	}
+
+	return nil
+}
+
+// templateResizes returns the volumeClaimTemplates of StatefulSet obj that
+// request more storage than the ones of the live StatefulSet.
+func templateResizes(obj, live *unstructured.Unstructured) ([]TemplateResize, error) {
+	vcts, err := volumeClaimTemplates(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	liveVCTs, err := volumeClaimTemplates(live)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]TemplateResize, 0)
+
+	for name, vct := range vcts {
+		liveVCT, ok := liveVCTs[name]
+		if !ok {
+			continue
+		}
+
+		to, found, err := storageRequest(vct)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading storage request of volumeClaimTemplate %q", name)
+		}
+
+		from, liveFound, err := storageRequest(liveVCT)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading storage request of live volumeClaimTemplate %q", name)
+		}
+
+		if found && liveFound && to.Cmp(from) > 0 {
+			templates = append(templates, TemplateResize{Name: name, From: from, To: to})
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+// volumeClaimTemplates returns the volumeClaimTemplates of StatefulSet obj
+// keyed by name.
+func volumeClaimTemplates(obj *unstructured.Unstructured) (map[string]map[string]interface{}, error) {
+	vcts := make(map[string]map[string]interface{})
+
+	val, found, err := unstructured.NestedFieldNoCopy(obj.Object, "spec", "volumeClaimTemplates")
+	if err != nil || !found {
+		return vcts, err
+	}
+
+	items, ok := val.([]interface{})
+	if !ok {
+		return nil, errors.Errorf(".spec.volumeClaimTemplates of StatefulSet %q is of type %T, expected []interface{}", obj.GetName(), val)
+	}
+
+	for i, item := range items {
+		vct, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf(".spec.volumeClaimTemplates[%d] of StatefulSet %q is of type %T, expected map[string]interface{}", i, obj.GetName(), item)
+		}
+
+		name, _, _ := unstructured.NestedString(vct, "metadata", "name")
+
+		vcts[name] = vct
+	}
+
+	return vcts, nil
+}
+
+// storageRequest returns the value of .spec.resources.requests.storage of
+// obj, which may either be a PersistentVolumeClaim or a volumeClaimTemplate.
+func storageRequest(obj map[string]interface{}) (resource.Quantity, bool, error) {
+	s, found, err := unstructured.NestedString(obj, "spec", "resources", "requests", "storage")
+	if err != nil || !found {
+		return resource.Quantity{}, found, err
+	}
+
+	q, err := resource.ParseQuantity(s)
+
+	return q, true, err
+}
+
+func setStorageRequest(obj map[string]interface{}, q resource.Quantity) {
+	unstructured.SetNestedField(obj, q.String(), "spec", "resources", "requests", "storage")
+}
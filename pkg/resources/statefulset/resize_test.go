@@ -0,0 +1,386 @@
+package statefulset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newStatefulSetWithStorage(name, storage string) *unstructured.Unstructured {
+	obj := newUnstructured("apps/v1", "StatefulSet", "foo", name)
+	obj.Object["spec"] = map[string]interface{}{
+		"volumeClaimTemplates": []interface{}{
+			map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name": "data",
+				},
+				"spec": map[string]interface{}{
+					"resources": map[string]interface{}{
+						"requests": map[string]interface{}{
+							"storage": storage,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return obj
+}
+
+func newClaimWithStorage(name, statefulSetName, storage string) *unstructured.Unstructured {
+	obj := newUnstructured("v1", "PersistentVolumeClaim", "foo", name)
+	obj.SetLabels(map[string]string{meta.LabelOwnedByStatefulSet: statefulSetName})
+	obj.Object["spec"] = map[string]interface{}{
+		"storageClassName": "standard",
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{
+				"storage": storage,
+			},
+		},
+	}
+
+	return obj
+}
+
+func newStorageClass(allowVolumeExpansion bool) *unstructured.Unstructured {
+	obj := newUnstructured("storage.k8s.io/v1", "StorageClass", "", "standard")
+	obj.Object["allowVolumeExpansion"] = allowVolumeExpansion
+
+	return obj
+}
+
+func newResizeTestClient(statefulSet *unstructured.Unstructured, claims ...*unstructured.Unstructured) *dynamicfakeclient.FakeDynamicClient {
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+	fakeClient.PrependReactor("get", "statefulsets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if statefulSet == nil {
+			return false, nil, nil
+		}
+
+		return true, statefulSet, nil
+	})
+	fakeClient.PrependReactor("list", "persistentvolumeclaims", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, newUnstructuredList(claims...), nil
+	})
+
+	return fakeClient
+}
+
+func TestPersistentVolumeClaimResizer_FindResizes(t *testing.T) {
+	tests := []struct {
+		name        string
+		live        *unstructured.Unstructured
+		claims      []*unstructured.Unstructured
+		objs        []runtime.Object
+		expected    []*Resize
+		expectedErr string
+	}{
+		{
+			name: "no StatefulSet",
+			objs: []runtime.Object{newUnstructured("v1", "Service", "foo", "bar")},
+		},
+		{
+			name: "StatefulSet does not exist yet",
+			objs: []runtime.Object{newStatefulSetWithStorage("db", "2Gi")},
+		},
+		{
+			name: "storage request unchanged",
+			live: newStatefulSetWithStorage("db", "2Gi"),
+			objs: []runtime.Object{newStatefulSetWithStorage("db", "2Gi")},
+		},
+		{
+			name: "storage request shrunk",
+			live: newStatefulSetWithStorage("db", "2Gi"),
+			objs: []runtime.Object{newStatefulSetWithStorage("db", "1Gi")},
+		},
+		{
+			name: "storage request grown",
+			live: newStatefulSetWithStorage("db", "1Gi"),
+			claims: []*unstructured.Unstructured{
+				newClaimWithStorage("data-db-0", "db", "1Gi"),
+				newClaimWithStorage("data-db-1", "db", "2Gi"),
+				newClaimWithStorage("logs-db-0", "db", "1Gi"),
+				newClaimWithStorage("data-db-canary-0", "db", "1Gi"),
+			},
+			objs: []runtime.Object{newStatefulSetWithStorage("db", "2Gi")},
+			expected: []*Resize{
+				{
+					StatefulSet: newStatefulSetWithStorage("db", "1Gi"),
+					Templates: []TemplateResize{
+						{Name: "data", From: resource.MustParse("1Gi"), To: resource.MustParse("2Gi")},
+					},
+					Claims: []ClaimResize{
+						{
+							Claim: newClaimWithStorage("data-db-0", "db", "1Gi"),
+							From:  resource.MustParse("1Gi"),
+							To:    resource.MustParse("2Gi"),
+						},
+					},
+				},
+			},
+		},
+		{
+			name:        "malformed storage request",
+			live:        newStatefulSetWithStorage("db", "1Gi"),
+			objs:        []runtime.Object{newStatefulSetWithStorage("db", "lots")},
+			expectedErr: `while reading storage request of volumeClaimTemplate "data": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := newResizeTestClient(test.live, test.claims...)
+
+			resizer := NewPersistentVolumeClaimResizer(
+				genericclioptions.NewTestIOStreamsDiscard(),
+				fakeClient,
+				testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				printers.NewDiscardingContextPrinter(),
+				false,
+			)
+
+			resizes, err := resizer.FindResizes(test.objs, "foo")
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+
+			if test.expected == nil {
+				assert.Empty(t, resizes)
+				return
+			}
+
+			assert.Equal(t, test.expected, resizes)
+		})
+	}
+}
+
+func TestPersistentVolumeClaimResizer_Resize(t *testing.T) {
+	newResize := func() *Resize {
+		statefulSet := newStatefulSetWithStorage("db", "1Gi")
+		statefulSet.SetUID("some-UID-value")
+
+		return &Resize{
+			StatefulSet: statefulSet,
+			Templates: []TemplateResize{
+				{Name: "data", From: resource.MustParse("1Gi"), To: resource.MustParse("2Gi")},
+			},
+			Claims: []ClaimResize{
+				{
+					Claim: newClaimWithStorage("data-db-0", "db", "1Gi"),
+					From:  resource.MustParse("1Gi"),
+					To:    resource.MustParse("2Gi"),
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name                 string
+		dryRun               bool
+		allowVolumeExpansion bool
+		expectedErr          string
+		expectedOutput       string
+		validateActions      func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:        "StorageClass does not allow volume expansion",
+			expectedErr: `cannot resize PersistentVolumeClaim "data-db-0": StorageClass "standard" does not allow volume expansion`,
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 || !actions[0].Matches("get", "storageclasses") {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+		},
+		{
+			name:                 "resizes claims and orphans StatefulSet",
+			allowVolumeExpansion: true,
+			expectedOutput:       "persistentvolumeclaim/data-db-0 resized (1Gi -> 2Gi)\nstatefulset.apps/db deleted (propagation=orphan)\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 3 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				patch, ok := actions[1].(clienttesting.PatchAction)
+				if !ok || !actions[1].Matches("patch", "persistentvolumeclaims") || patch.GetName() != "data-db-0" {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				assert.Equal(t, `{"spec":{"resources":{"requests":{"storage":"2Gi"}}}}`, string(patch.GetPatch()))
+
+				if !actions[2].Matches("delete", "statefulsets") || actions[2].(clienttesting.DeleteAction).GetName() != "db" {
+					t.Error(spew.Sdump(actions))
+				}
+			},
+		},
+		{
+			name:                 "dry run",
+			dryRun:               true,
+			allowVolumeExpansion: true,
+			expectedOutput:       "persistentvolumeclaim/data-db-0 resized (1Gi -> 2Gi) (dry run)\nstatefulset.apps/db deleted (propagation=orphan) (dry run)\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 1 || !actions[0].Matches("get", "storageclasses") {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+			fakeClient.PrependReactor("get", "storageclasses", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, newStorageClass(test.allowVolumeExpansion), nil
+			})
+			fakeClient.PrependReactor("patch", "persistentvolumeclaims", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, newClaimWithStorage("data-db-0", "db", "2Gi"), nil
+			})
+			fakeClient.PrependReactor("delete", "statefulsets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			waiter := wait.NewFakeWaiter()
+
+			resizer := NewPersistentVolumeClaimResizer(
+				streams,
+				fakeClient,
+				testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				printers.NewContextPrinter(false, test.dryRun),
+				test.dryRun,
+			)
+			resizer.Waiter = waiter
+
+			err := resizer.Resize(context.Background(), []*Resize{newResize()})
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			test.validateActions(t, fakeClient.Actions())
+			assert.Equal(t, test.expectedOutput, out.String())
+
+			if test.expectedErr == "" && !test.dryRun {
+				assert.Len(t, waiter.Requests, 1)
+			} else {
+				assert.Empty(t, waiter.Requests)
+			}
+		})
+	}
+}
+
+func TestPersistentVolumeClaimResizer_Restore(t *testing.T) {
+	tests := []struct {
+		name           string
+		dryRun         bool
+		exists         bool
+		expectedOutput string
+		expectedCreate bool
+	}{
+		{
+			name:           "recreates deleted StatefulSet",
+			expectedOutput: "statefulset.apps/db restored\n",
+			expectedCreate: true,
+		},
+		{
+			name:           "StatefulSet already recreated",
+			exists:         true,
+			expectedCreate: true,
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+			fakeClient.PrependReactor("create", "statefulsets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if test.exists {
+					return true, nil, apierrors.NewAlreadyExists(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, "db")
+				}
+
+				return true, action.(clienttesting.CreateAction).GetObject(), nil
+			})
+
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+
+			resizer := NewPersistentVolumeClaimResizer(
+				streams,
+				fakeClient,
+				testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+				printers.NewContextPrinter(false, test.dryRun),
+				test.dryRun,
+			)
+
+			statefulSet := newStatefulSetWithStorage("db", "1Gi")
+			statefulSet.SetUID("some-UID-value")
+			statefulSet.SetResourceVersion("42")
+
+			require.NoError(t, resizer.Restore([]*Resize{{StatefulSet: statefulSet}}))
+
+			actions := fakeClient.Actions()
+
+			if !test.expectedCreate {
+				assert.Empty(t, actions)
+			} else {
+				if len(actions) != 1 || !actions[0].Matches("create", "statefulsets") {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				obj := actions[0].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+
+				assert.Empty(t, obj.GetUID())
+				assert.Empty(t, obj.GetResourceVersion())
+			}
+
+			assert.Equal(t, test.expectedOutput, out.String())
+		})
+	}
+}
+
+func TestResize_RevertApply(t *testing.T) {
+	resize := &Resize{
+		Templates: []TemplateResize{
+			{Name: "data", From: resource.MustParse("1Gi"), To: resource.MustParse("2Gi")},
+		},
+	}
+
+	obj := newStatefulSetWithStorage("db", "2Gi")
+
+	require.NoError(t, resize.Revert(obj))
+	assert.Equal(t, newStatefulSetWithStorage("db", "1Gi"), obj)
+
+	require.NoError(t, resize.Apply(obj))
+	assert.Equal(t, newStatefulSetWithStorage("db", "2Gi"), obj)
+
+	claim := ClaimResize{
+		Claim: newClaimWithStorage("data-db-0", "db", "1Gi"),
+		To:    resource.MustParse("2Gi"),
+	}
+
+	assert.Equal(t, newClaimWithStorage("data-db-0", "db", "2Gi"), claim.Resized())
+	assert.Equal(t, newClaimWithStorage("data-db-0", "db", "1Gi"), claim.Claim)
+}
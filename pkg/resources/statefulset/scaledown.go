@@ -0,0 +1,238 @@
+package statefulset
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+var podGK = schema.GroupKind{Kind: "Pod"}
+
+// ScaleDownWaitTimeout is the maximum time to wait for the pods of removed
+// StatefulSet replicas to terminate before their PersistentVolumeClaims are
+// pruned.
+var ScaleDownWaitTimeout = 5 * time.Minute
+
+// PruneScaledDownClaims searches the slice of runtime objects for StatefulSets
+// with the delete-pvcs-on-scaledown deletion policy and prunes the
+// PersistentVolumeClaims of replicas that do not exist anymore because the
+// StatefulSet was scaled down. If p.Waiter is set, it waits for the pods of
+// the removed replicas to be gone before their claims are pruned. Objects
+// without namespace are looked up in namespace.
+func (p *PersistentVolumeClaimPruner) PruneScaledDownClaims(ctx context.Context, objs []runtime.Object, namespace string) error {
+	for _, obj := range objs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		claims, err := p.findScaledDownClaims(obj, namespace)
+		if err != nil {
+			return err
+		}
+
+		if len(claims) == 0 {
+			continue
+		}
+
+		err = p.waitForPods(ctx, obj, claims)
+		if err != nil {
+			return err
+		}
+
+		err = p.deleteClaims(ctx, obj, claims)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindScaledDownClaims returns the PersistentVolumeClaims that
+// PruneScaledDownClaims would delete for the slice of runtime objects without
+// deleting them.
+func (p *PersistentVolumeClaimPruner) FindScaledDownClaims(objs []runtime.Object, namespace string) ([]*resource.Info, error) {
+	claims := make([]*resource.Info, 0)
+
+	for _, obj := range objs {
+		infos, err := p.findScaledDownClaims(obj, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		claims = append(claims, infos...)
+	}
+
+	return claims, nil
+}
+
+func (p *PersistentVolumeClaimPruner) findScaledDownClaims(obj runtime.Object, namespace string) ([]*resource.Info, error) {
+	if !meta.HasGroupKind(obj, statefulSetGK) || !meta.HasDeletionPolicy(obj, meta.DeletionPolicyDeletePVCsOnScaleDown) {
+		return nil, nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, errors.Errorf("obj is of type %T, expected *unstructured.Unstructured", obj)
+	}
+
+	if u.GetNamespace() != "" {
+		namespace = u.GetNamespace()
+	}
+
+	replicas, found, err := p.replicas(u, namespace)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	vcts, err := volumeClaimTemplates(u)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := p.Mapper.RESTMapping(persistentVolumeClaimGK)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := listClaims(p.DynamicClient, mapping, u, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]unstructured.Unstructured, 0)
+
+	for _, claim := range items {
+		ordinal, ok := claimOrdinal(claim.GetName(), u.GetName(), vcts)
+		if ok && ordinal >= replicas {
+			claims = append(claims, claim)
+		}
+	}
+
+	return toInfoList(claims, p.Mapper)
+}
+
+// replicas returns the desired number of replicas of StatefulSet obj. If obj
+// does not specify replicas, the value is looked up from the live object as
+// it is not changed by apply in this case. The second return value is false
+// if the StatefulSet does not exist.
+func (p *PersistentVolumeClaimPruner) replicas(obj *unstructured.Unstructured, namespace string) (int64, bool, error) {
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil || found {
+		return replicas, found, err
+	}
+
+	mapping, err := p.Mapper.RESTMapping(statefulSetGK)
+	if err != nil {
+		return 0, false, err
+	}
+
+	live, err := p.DynamicClient.
+		Resource(mapping.Resource).
+		Namespace(namespace).
+		Get(obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	replicas, found, err = unstructured.NestedInt64(live.Object, "spec", "replicas")
+	if err != nil || !found {
+		return 1, true, err
+	}
+
+	return replicas, true, nil
+}
+
+// waitForPods waits until the pods that used claims are gone.
+func (p *PersistentVolumeClaimPruner) waitForPods(ctx context.Context, obj runtime.Object, claims []*resource.Info) error {
+	if p.Waiter == nil {
+		return nil
+	}
+
+	mapping, err := p.Mapper.RESTMapping(podGK)
+	if err != nil {
+		return err
+	}
+
+	u := obj.(*unstructured.Unstructured)
+
+	vcts, err := volumeClaimTemplates(u)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	pods := make([]*resource.Info, 0, len(claims))
+
+	for _, claim := range claims {
+		ordinal, _ := claimOrdinal(claim.Name, u.GetName(), vcts)
+
+		name := fmt.Sprintf("%s-%d", u.GetName(), ordinal)
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		pods = append(pods, &resource.Info{
+			Mapping:   mapping,
+			Namespace: claim.Namespace,
+			Name:      name,
+		})
+	}
+
+	return p.Waiter.Wait(ctx, &wait.Request{
+		ConditionFn: wait.NewDeletedConditionFunc(p.DynamicClient, ioutil.Discard, wait.UIDMap{}, 0),
+		Options:     &wait.Options{Timeout: ScaleDownWaitTimeout},
+		Visitor:     resource.InfoListVisitor(pods),
+		Timeout:     ScaleDownWaitTimeout,
+	})
+}
+
+// claimOrdinal extracts the ordinal of the StatefulSet replica from the name
+// of a PersistentVolumeClaim created from one of the volumeClaimTemplates.
+// The second return value is false if the claim name does not match any of
+// the volumeClaimTemplates.
+func claimOrdinal(claimName, statefulSetName string, vcts map[string]map[string]interface{}) (int64, bool) {
+	for vctName := range vcts {
+		if ordinal, ok := templateClaimOrdinal(claimName, vctName, statefulSetName); ok {
+			return ordinal, true
+		}
+	}
+
+	return 0, false
+}
+
+// templateClaimOrdinal extracts the ordinal of the StatefulSet replica from
+// the name of a PersistentVolumeClaim created from the volumeClaimTemplate
+// with given name. The second return value is false if the claim name is not
+// of the form <template>-<statefulset>-<ordinal>.
+func templateClaimOrdinal(claimName, templateName, statefulSetName string) (int64, bool) {
+	prefix := fmt.Sprintf("%s-%s-", templateName, statefulSetName)
+
+	if !strings.HasPrefix(claimName, prefix) {
+		return 0, false
+	}
+
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(claimName, prefix), 10, 64)
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+
+	return ordinal, true
+}
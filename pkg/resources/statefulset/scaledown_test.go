@@ -0,0 +1,179 @@
+package statefulset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/martinohmann/kubectl-chart/pkg/deletions"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func newScaledStatefulSet(name string, replicas int64, policy string) *unstructured.Unstructured {
+	obj := newStatefulSetWithStorage(name, "1Gi")
+	if replicas >= 0 {
+		obj.Object["spec"].(map[string]interface{})["replicas"] = replicas
+	}
+
+	if policy != "" {
+		obj.SetAnnotations(map[string]string{meta.AnnotationDeletionPolicy: policy})
+	}
+
+	return obj
+}
+
+func infoNames(infos []*resource.Info) []string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+
+	return names
+}
+
+func TestPersistentVolumeClaimPruner_FindScaledDownClaims(t *testing.T) {
+	claims := []*unstructured.Unstructured{
+		newClaimWithStorage("data-db-0", "db", "1Gi"),
+		newClaimWithStorage("data-db-1", "db", "1Gi"),
+		newClaimWithStorage("data-db-2", "db", "1Gi"),
+		newClaimWithStorage("data-db-foo", "db", "1Gi"),
+		newClaimWithStorage("logs-db-2", "db", "1Gi"),
+	}
+
+	tests := []struct {
+		name     string
+		live     *unstructured.Unstructured
+		objs     []runtime.Object
+		expected []string
+	}{
+		{
+			name: "StatefulSet without deletion policy",
+			objs: []runtime.Object{newScaledStatefulSet("db", 1, "")},
+		},
+		{
+			name: "StatefulSet with other deletion policy",
+			objs: []runtime.Object{newScaledStatefulSet("db", 1, "delete-pvcs")},
+		},
+		{
+			name:     "replicas from local object",
+			objs:     []runtime.Object{newScaledStatefulSet("db", 1, "delete-pvcs-on-scaledown")},
+			expected: []string{"data-db-1", "data-db-2"},
+		},
+		{
+			name:     "combined deletion policies",
+			objs:     []runtime.Object{newScaledStatefulSet("db", 2, "delete-pvcs, delete-pvcs-on-scaledown")},
+			expected: []string{"data-db-2"},
+		},
+		{
+			name:     "replicas from live object",
+			live:     newScaledStatefulSet("db", 2, ""),
+			objs:     []runtime.Object{newScaledStatefulSet("db", -1, "delete-pvcs-on-scaledown")},
+			expected: []string{"data-db-2"},
+		},
+		{
+			name: "StatefulSet without replicas does not exist yet",
+			objs: []runtime.Object{newScaledStatefulSet("db", -1, "delete-pvcs-on-scaledown")},
+		},
+		{
+			name: "template claim tracking",
+			objs: []runtime.Object{func() runtime.Object {
+				obj := newScaledStatefulSet("db", 1, "delete-pvcs-on-scaledown")
+				obj.SetAnnotations(map[string]string{
+					meta.AnnotationDeletionPolicy: "delete-pvcs-on-scaledown",
+					meta.AnnotationClaimTracking:  "template",
+				})
+				return obj
+			}()},
+			expected: []string{"data-db-1", "data-db-2"},
+		},
+		{
+			name:     "scaled to zero",
+			objs:     []runtime.Object{newScaledStatefulSet("db", 0, "delete-pvcs-on-scaledown")},
+			expected: []string{"data-db-0", "data-db-1", "data-db-2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pruner := NewPersistentVolumeClaimPruner(
+				newResizeTestClient(test.live, claims...),
+				nil,
+				testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+			)
+
+			infos, err := pruner.FindScaledDownClaims(test.objs, "foo")
+			require.NoError(t, err)
+
+			if test.expected == nil {
+				assert.Empty(t, infos)
+				return
+			}
+
+			assert.Equal(t, test.expected, infoNames(infos))
+		})
+	}
+}
+
+func TestPersistentVolumeClaimPruner_PruneScaledDownClaims(t *testing.T) {
+	claims := []*unstructured.Unstructured{
+		newClaimWithStorage("data-db-0", "db", "1Gi"),
+		newClaimWithStorage("data-db-1", "db", "1Gi"),
+		newClaimWithStorage("data-db-2", "db", "1Gi"),
+	}
+
+	objs := []runtime.Object{newScaledStatefulSet("db", 1, "delete-pvcs-on-scaledown")}
+
+	t.Run("waits for pods before pruning", func(t *testing.T) {
+		deleter := deletions.NewFakeDeleter()
+		waiter := wait.NewFakeWaiter()
+
+		pruner := NewPersistentVolumeClaimPruner(
+			newResizeTestClient(nil, claims...),
+			deleter,
+			testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		)
+		pruner.Waiter = waiter
+
+		require.NoError(t, pruner.PruneScaledDownClaims(context.Background(), objs, "foo"))
+
+		require.Len(t, waiter.Requests, 1)
+
+		var pods []*resource.Info
+		require.NoError(t, waiter.Requests[0].Visitor.Visit(func(info *resource.Info, err error) error {
+			pods = append(pods, info)
+			return err
+		}))
+
+		assert.Equal(t, []string{"db-1", "db-2"}, infoNames(pods))
+		assert.Equal(t, "Pod", pods[0].Mapping.GroupVersionKind.Kind)
+		assert.Equal(t, "foo", pods[0].Namespace)
+
+		assert.Equal(t, 1, deleter.Called)
+		assert.Equal(t, []string{"data-db-1", "data-db-2"}, infoNames(deleter.Infos))
+	})
+
+	t.Run("does not prune if pods are not gone", func(t *testing.T) {
+		deleter := deletions.NewFakeDeleter()
+		waiter := wait.NewFakeWaiter()
+		waiter.Err = assert.AnError
+
+		pruner := NewPersistentVolumeClaimPruner(
+			newResizeTestClient(nil, claims...),
+			deleter,
+			testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		)
+		pruner.Waiter = waiter
+
+		err := pruner.PruneScaledDownClaims(context.Background(), objs, "foo")
+		require.Equal(t, assert.AnError, err)
+
+		assert.Equal(t, 0, deleter.Called)
+	})
+}
@@ -0,0 +1,136 @@
+package statefulset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/pkg/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+)
+
+var volumeSnapshotGK = schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: "VolumeSnapshot"}
+
+// SnapshotWaitTimeout is the maximum time to wait for VolumeSnapshots to
+// become ready to use.
+var SnapshotWaitTimeout = 30 * time.Minute
+
+// VolumeSnapshotter creates VolumeSnapshots of PersistentVolumeClaims before
+// they are pruned.
+type VolumeSnapshotter struct {
+	genericclioptions.IOStreams
+	DynamicClient dynamic.Interface
+	Mapper        kmeta.RESTMapper
+	Printer       printers.ContextPrinter
+	Waiter        wait.Waiter
+
+	// DryRun if enabled, snapshots are only simulated and printed.
+	DryRun bool
+}
+
+// NewVolumeSnapshotter creates a new VolumeSnapshotter value.
+func NewVolumeSnapshotter(streams genericclioptions.IOStreams, client dynamic.Interface, mapper kmeta.RESTMapper, printer printers.ContextPrinter, dryRun bool) *VolumeSnapshotter {
+	return &VolumeSnapshotter{
+		IOStreams:     streams,
+		DynamicClient: client,
+		Mapper:        mapper,
+		Printer:       printer,
+		Waiter:        wait.NewWaiter(streams, printer.WithOperation("ready")),
+		DryRun:        dryRun,
+	}
+}
+
+// Snapshot creates a VolumeSnapshot of class className for each of the claims
+// of statefulSet and waits until all of them are ready to use. The snapshots
+// are labeled with the names of the chart and the StatefulSet.
+func (s *VolumeSnapshotter) Snapshot(ctx context.Context, statefulSet runtime.Object, claims []*resource.Info, className string) error {
+	metadata, err := kmeta.Accessor(statefulSet)
+	if err != nil {
+		return err
+	}
+
+	if className == "" {
+		return errors.Errorf("annotation %q on StatefulSet %q must contain the name of a VolumeSnapshotClass", meta.AnnotationSnapshotBeforeDelete, metadata.GetName())
+	}
+
+	mapping, err := s.Mapper.RESTMapping(volumeSnapshotGK)
+	if err != nil {
+		return errors.Wrapf(err, "cannot snapshot PersistentVolumeClaims of StatefulSet %q", metadata.GetName())
+	}
+
+	suffix := time.Now().Unix()
+	snapshots := make([]*resource.Info, 0, len(claims))
+
+	for _, claim := range claims {
+		obj := newVolumeSnapshot(mapping, claim, className, suffix)
+
+		obj.SetLabels(map[string]string{
+			meta.LabelSnapshotChartName:  metadata.GetLabels()[meta.LabelChartName],
+			meta.LabelOwnedByStatefulSet: metadata.GetName(),
+		})
+
+		if !s.DryRun {
+			obj, err = s.DynamicClient.
+				Resource(mapping.Resource).
+				Namespace(claim.Namespace).
+				Create(obj, metav1.CreateOptions{})
+			if err != nil {
+				return errors.Wrapf(err, "while creating VolumeSnapshot of PersistentVolumeClaim %q", claim.Name)
+			}
+		}
+
+		p := s.Printer.WithOperation("created").WithContext(fmt.Sprintf("source=%s", claim.Name))
+
+		err = p.PrintObj(obj, s.Out)
+		if err != nil {
+			return err
+		}
+
+		snapshots = append(snapshots, &resource.Info{
+			Mapping:   mapping,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Object:    obj,
+		})
+	}
+
+	if s.DryRun || s.Waiter == nil {
+		return nil
+	}
+
+	return s.Waiter.Wait(ctx, &wait.Request{
+		ConditionFn: wait.NewReadinessConditionFunc(s.DynamicClient, s.ErrOut),
+		Options:     &wait.Options{Timeout: SnapshotWaitTimeout},
+		Visitor:     resource.InfoListVisitor(snapshots),
+		Timeout:     SnapshotWaitTimeout,
+	})
+}
+
+func newVolumeSnapshot(mapping *kmeta.RESTMapping, claim *resource.Info, className string, suffix int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": mapping.GroupVersionKind.GroupVersion().String(),
+			"kind":       mapping.GroupVersionKind.Kind,
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("%s-%d", claim.Name, suffix),
+				"namespace": claim.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"volumeSnapshotClassName": className,
+				"source": map[string]interface{}{
+					"persistentVolumeClaimName": claim.Name,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,238 @@
+package statefulset
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/martinohmann/kubectl-chart/pkg/deletions"
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/martinohmann/kubectl-chart/pkg/printers"
+	"github.com/martinohmann/kubectl-chart/pkg/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newSnapshotTestMapper() kmeta.RESTMapper {
+	gv := schema.GroupVersion{Group: "snapshot.storage.k8s.io", Version: "v1beta1"}
+
+	mapper := kmeta.NewDefaultRESTMapper([]schema.GroupVersion{gv})
+	mapper.Add(gv.WithKind("VolumeSnapshot"), kmeta.RESTScopeNamespace)
+
+	return kmeta.MultiRESTMapper{
+		testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme),
+		mapper,
+	}
+}
+
+func newSnapshotTestClient() *dynamicfakeclient.FakeDynamicClient {
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(scheme.Scheme)
+	fakeClient.PrependReactor("create", "volumesnapshots", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, action.(clienttesting.CreateAction).GetObject(), nil
+	})
+
+	return fakeClient
+}
+
+func newSnapshotStatefulSet(className string) *unstructured.Unstructured {
+	obj := newUnstructured("apps/v1", "StatefulSet", "foo", "db")
+	obj.SetLabels(map[string]string{meta.LabelChartName: "mychart"})
+	obj.SetAnnotations(map[string]string{
+		meta.AnnotationDeletionPolicy:       "delete-pvcs",
+		meta.AnnotationSnapshotBeforeDelete: className,
+	})
+
+	return obj
+}
+
+func newClaimInfos(names ...string) []*resource.Info {
+	infos := make([]*resource.Info, len(names))
+	for i, name := range names {
+		infos[i] = &resource.Info{Namespace: "foo", Name: name}
+	}
+
+	return infos
+}
+
+func TestVolumeSnapshotter_Snapshot(t *testing.T) {
+	tests := []struct {
+		name            string
+		className       string
+		dryRun          bool
+		expectedErr     string
+		expectedOutput  string
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:        "missing VolumeSnapshotClass",
+			expectedErr: `annotation "kubectl-chart/snapshot-before-delete" on StatefulSet "db" must contain the name of a VolumeSnapshotClass`,
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+		},
+		{
+			name:           "creates labeled snapshots",
+			className:      "csi-snapclass",
+			expectedOutput: "volumesnapshot.snapshot.storage.k8s.io/data-db-0-* created (source=data-db-0)\nvolumesnapshot.snapshot.storage.k8s.io/data-db-1-* created (source=data-db-1)\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 2 {
+					t.Fatal(spew.Sdump(actions))
+				}
+
+				obj := actions[0].(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+
+				assert.Equal(t, "snapshot.storage.k8s.io/v1beta1", obj.GetAPIVersion())
+				assert.Equal(t, "foo", obj.GetNamespace())
+				assert.Equal(t, map[string]string{
+					meta.LabelSnapshotChartName:  "mychart",
+					meta.LabelOwnedByStatefulSet: "db",
+				}, obj.GetLabels())
+				assert.Equal(t, map[string]interface{}{
+					"volumeSnapshotClassName": "csi-snapclass",
+					"source": map[string]interface{}{
+						"persistentVolumeClaimName": "data-db-0",
+					},
+				}, obj.Object["spec"])
+			},
+		},
+		{
+			name:           "dry run",
+			className:      "csi-snapclass",
+			dryRun:         true,
+			expectedOutput: "volumesnapshot.snapshot.storage.k8s.io/data-db-0-* created (source=data-db-0) (dry run)\nvolumesnapshot.snapshot.storage.k8s.io/data-db-1-* created (source=data-db-1) (dry run)\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Fatal(spew.Sdump(actions))
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := newSnapshotTestClient()
+			streams, _, out, _ := genericclioptions.NewTestIOStreams()
+			waiter := wait.NewFakeWaiter()
+
+			snapshotter := NewVolumeSnapshotter(
+				streams,
+				fakeClient,
+				newSnapshotTestMapper(),
+				printers.NewContextPrinter(false, test.dryRun),
+				test.dryRun,
+			)
+			snapshotter.Waiter = waiter
+
+			err := snapshotter.Snapshot(
+				context.Background(),
+				newSnapshotStatefulSet(test.className),
+				newClaimInfos("data-db-0", "data-db-1"),
+				test.className,
+			)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			test.validateActions(t, fakeClient.Actions())
+			assert.Equal(t, test.expectedOutput, maskSnapshotSuffix(out.String()))
+
+			if test.expectedErr == "" && !test.dryRun {
+				assert.Len(t, waiter.Requests, 1)
+			} else {
+				assert.Empty(t, waiter.Requests)
+			}
+		})
+	}
+}
+
+func TestPersistentVolumeClaimPruner_PruneClaims_Snapshot(t *testing.T) {
+	claims := []*unstructured.Unstructured{newClaimWithStorage("data-db-0", "db", "1Gi")}
+
+	t.Run("deletes claims after snapshots are ready", func(t *testing.T) {
+		deleter := deletions.NewFakeDeleter()
+		waiter := wait.NewFakeWaiter()
+
+		pruner := NewPersistentVolumeClaimPruner(newResizeTestClient(nil, claims...), deleter, newSnapshotTestMapper())
+		pruner.Snapshotter = NewVolumeSnapshotter(
+			genericclioptions.NewTestIOStreamsDiscard(),
+			newSnapshotTestClient(),
+			newSnapshotTestMapper(),
+			printers.NewDiscardingContextPrinter(),
+			false,
+		)
+		pruner.Snapshotter.Waiter = waiter
+
+		err := pruner.PruneClaims(context.Background(), []runtime.Object{newSnapshotStatefulSet("csi-snapclass")})
+		require.NoError(t, err)
+
+		assert.Len(t, waiter.Requests, 1)
+		assert.Equal(t, []string{"data-db-0"}, infoNames(deleter.Infos))
+	})
+
+	t.Run("does not delete claims if snapshots fail", func(t *testing.T) {
+		deleter := deletions.NewFakeDeleter()
+		waiter := wait.NewFakeWaiter()
+		waiter.Err = assert.AnError
+
+		pruner := NewPersistentVolumeClaimPruner(newResizeTestClient(nil, claims...), deleter, newSnapshotTestMapper())
+		pruner.Snapshotter = NewVolumeSnapshotter(
+			genericclioptions.NewTestIOStreamsDiscard(),
+			newSnapshotTestClient(),
+			newSnapshotTestMapper(),
+			printers.NewDiscardingContextPrinter(),
+			false,
+		)
+		pruner.Snapshotter.Waiter = waiter
+
+		err := pruner.PruneClaims(context.Background(), []runtime.Object{newSnapshotStatefulSet("csi-snapclass")})
+		require.Equal(t, assert.AnError, err)
+
+		assert.Equal(t, 0, deleter.Called)
+	})
+
+	t.Run("snapshots not supported", func(t *testing.T) {
+		deleter := deletions.NewFakeDeleter()
+
+		pruner := NewPersistentVolumeClaimPruner(newResizeTestClient(nil, claims...), deleter, newSnapshotTestMapper())
+
+		err := pruner.PruneClaims(context.Background(), []runtime.Object{newSnapshotStatefulSet("csi-snapclass")})
+		require.Error(t, err)
+		assert.Equal(t, `cannot snapshot PersistentVolumeClaims of StatefulSet "db": no snapshotter configured`, err.Error())
+
+		assert.Equal(t, 0, deleter.Called)
+	})
+}
+
+// maskSnapshotSuffix replaces the timestamp suffix of snapshot names in s with
+// an asterisk.
+func maskSnapshotSuffix(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if j := strings.LastIndex(parts[0], "-"); j >= 0 {
+			lines[i] = parts[0][:j+1] + "* " + parts[1]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -2,22 +2,14 @@ package wait
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"strings"
-	"time"
 
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
-	watchtools "k8s.io/client-go/tools/watch"
 )
 
 var (
@@ -40,105 +32,12 @@ func NewCompletionConditionFunc(client dynamic.Interface, errOut io.Writer) Cond
 
 // ConditionFunc waits on a job to complete. It will also watch the failed
 // status of the job and stops waiting with an error if the job failed.
-func (w CompletionWait) ConditionFunc(info *resource.Info, o Options) (runtime.Object, bool, error) {
+func (w CompletionWait) ConditionFunc(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 	if info.Mapping.GroupVersionKind != jobGVK {
 		return info.Object, false, &WaitSkippedError{Name: info.Name, GroupVersionKind: info.Mapping.GroupVersionKind}
 	}
 
-	endTime := time.Now().Add(o.Timeout)
-
-	for {
-		if len(info.Name) == 0 {
-			return info.Object, false, fmt.Errorf("resource name must be provided")
-		}
-
-		nameSelector := fields.OneTermEqualSelector("metadata.name", info.Name).String()
-
-		var obj *unstructured.Unstructured
-		// List with a name field selector to get the current resourceVersion
-		// to watch from (not the object's resourceVersion)
-		objList, err := w.DynamicClient.
-			Resource(info.Mapping.Resource).
-			Namespace(info.Namespace).
-			List(metav1.ListOptions{FieldSelector: nameSelector})
-
-		var resourceVersion string
-
-		switch {
-		case err != nil:
-			return info.Object, false, err
-		case len(objList.Items) != 1:
-			resourceVersion = objList.GetResourceVersion()
-		default:
-			obj = &objList.Items[0]
-			complete, err := hasStatusComplete(obj)
-			if complete {
-				return obj, true, nil
-			}
-			if err != nil {
-				return obj, false, err
-			}
-			resourceVersion = objList.GetResourceVersion()
-		}
-
-		watchOptions := metav1.ListOptions{
-			FieldSelector:   nameSelector,
-			ResourceVersion: resourceVersion,
-		}
-
-		objWatch, err := w.DynamicClient.
-			Resource(info.Mapping.Resource).
-			Namespace(info.Namespace).
-			Watch(watchOptions)
-		if err != nil {
-			return obj, false, err
-		}
-
-		errWaitTimeout := waitTimeoutError(wait.ErrWaitTimeout, info)
-		if endTime.Sub(time.Now()) < 0 {
-			return obj, false, errWaitTimeout
-		}
-
-		ctx, cancel := watchtools.ContextWithOptionalTimeout(context.Background(), o.Timeout)
-
-		watchEvent, err := watchtools.UntilWithoutRetry(ctx, objWatch, w.isComplete)
-
-		cancel()
-
-		switch {
-		case err == nil:
-			return watchEvent.Object, true, nil
-		case err == watchtools.ErrWatchClosed:
-			continue
-		case err == wait.ErrWaitTimeout:
-			if watchEvent != nil {
-				return watchEvent.Object, false, errWaitTimeout
-			}
-
-			return obj, false, errWaitTimeout
-		default:
-			return obj, false, err
-		}
-	}
-}
-
-func (w CompletionWait) isComplete(event watch.Event) (bool, error) {
-	if event.Type == watch.Error {
-		// keep waiting in the event we see an error - we expect the watch to be closed by
-		// the server
-		err := apierrors.FromObject(event.Object)
-		fmt.Fprintf(w.ErrOut, "error: An error occurred while waiting for the condition to be satisfied: %v", err)
-		return false, nil
-	}
-
-	if event.Type == watch.Deleted {
-		// this will chain back out, result in another get and an return false back up the chain
-		return false, nil
-	}
-
-	obj := event.Object.(*unstructured.Unstructured)
-
-	return hasStatusComplete(obj)
+	return watchUntil(ctx, w.DynamicClient, w.ErrOut, info, o, hasStatusComplete)
 }
 
 func hasStatusComplete(obj *unstructured.Unstructured) (bool, error) {
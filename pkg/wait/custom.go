@@ -0,0 +1,185 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/pkg/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Condition is a custom wait condition parsed from the value of the
+// kubectl-chart/wait-for annotation.
+type Condition struct {
+	// Type is the type of the status condition to wait for, e.g. Ready. It
+	// is empty for JSONPath conditions.
+	Type string
+
+	// JSONPath is the parsed JSONPath expression. It is nil for status
+	// conditions.
+	JSONPath *jsonpath.JSONPath
+
+	// Value is the expected status of the status condition or the expected
+	// result of the JSONPath expression.
+	Value string
+}
+
+// ParseCondition parses s into a *Condition. Supported formats are
+// "condition=<type>[=<status>]" and "jsonpath=<expression>=<value>".
+func ParseCondition(s string) (*Condition, error) {
+	switch {
+	case strings.HasPrefix(s, "condition="):
+		parts := strings.SplitN(strings.TrimPrefix(s, "condition="), "=", 2)
+		if parts[0] == "" {
+			return nil, errors.Errorf("condition type must not be empty in %q", s)
+		}
+
+		c := &Condition{Type: parts[0], Value: "True"}
+		if len(parts) == 2 {
+			c.Value = parts[1]
+		}
+
+		return c, nil
+	case strings.HasPrefix(s, "jsonpath="):
+		expr := strings.TrimPrefix(s, "jsonpath=")
+
+		i := strings.LastIndex(expr, "}=")
+		if i < 0 {
+			return nil, errors.Errorf("jsonpath condition must be of the form jsonpath={<expression>}=<value>, got %q", s)
+		}
+
+		j := jsonpath.New("wait-for").AllowMissingKeys(true)
+
+		err := j.Parse(expr[:i+1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid jsonpath expression in %q", s)
+		}
+
+		return &Condition{JSONPath: j, Value: expr[i+2:]}, nil
+	default:
+		return nil, errors.Errorf("unsupported wait condition %q, must start with condition= or jsonpath=", s)
+	}
+}
+
+// IsMet returns true if obj satisfies the condition.
+func (c *Condition) IsMet(obj *unstructured.Unstructured) (bool, error) {
+	if c.JSONPath == nil {
+		return hasConditionStatus(obj, strings.ToLower(c.Type), strings.ToLower(c.Value))
+	}
+
+	results, err := c.JSONPath.FindResults(obj.Object)
+	if err != nil {
+		return false, err
+	}
+
+	for _, result := range results {
+		for _, value := range result {
+			if fmt.Sprint(value.Interface()) == c.Value {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+type CustomWait struct {
+	DynamicClient dynamic.Interface
+	ErrOut        io.Writer
+
+	// Fallback is used for resources that do not have the
+	// kubectl-chart/wait-for annotation. If nil, waiting on these resources is
+	// skipped.
+	Fallback ConditionFunc
+}
+
+func NewCustomConditionFunc(client dynamic.Interface, errOut io.Writer, fallback ConditionFunc) ConditionFunc {
+	w := CustomWait{
+		DynamicClient: client,
+		ErrOut:        errOut,
+		Fallback:      fallback,
+	}
+
+	return w.ConditionFunc
+}
+
+// HasCustomCondition returns true if obj has the kubectl-chart/wait-for
+// annotation.
+func HasCustomCondition(obj runtime.Object) bool {
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	_, ok := metadata.GetAnnotations()[meta.AnnotationWaitFor]
+
+	return ok
+}
+
+// CustomTimeout returns the timeout defined in the kubectl-chart/wait-timeout
+// annotation of obj. The second return value is false if obj does not have
+// the annotation.
+func CustomTimeout(obj runtime.Object) (time.Duration, bool, error) {
+	metadata, err := kmeta.Accessor(obj)
+	if err != nil {
+		return 0, false, err
+	}
+
+	value, ok := metadata.GetAnnotations()[meta.AnnotationWaitTimeout]
+	if !ok {
+		return 0, false, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return timeout, true, nil
+}
+
+// ConditionFunc waits for the condition defined in the kubectl-chart/wait-for
+// annotation of a resource. If the kubectl-chart/wait-timeout annotation is
+// present, it overrides the timeout from o.
+func (w CustomWait) ConditionFunc(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+	metadata, err := kmeta.Accessor(info.Object)
+	if err != nil {
+		return info.Object, false, err
+	}
+
+	annotations := metadata.GetAnnotations()
+
+	value, ok := annotations[meta.AnnotationWaitFor]
+	if !ok {
+		if w.Fallback != nil {
+			return w.Fallback(ctx, info, o)
+		}
+
+		return info.Object, false, &WaitSkippedError{Name: info.Name, GroupVersionKind: info.Mapping.GroupVersionKind}
+	}
+
+	condition, err := ParseCondition(value)
+	if err != nil {
+		return info.Object, false, errors.Wrapf(err, "malformed annotation %q on %s/%s", meta.AnnotationWaitFor, info.Mapping.Resource.Resource, info.Name)
+	}
+
+	timeout, ok, err := CustomTimeout(info.Object)
+	if err != nil {
+		return info.Object, false, errors.Wrapf(err, "malformed annotation %q on %s/%s", meta.AnnotationWaitTimeout, info.Mapping.Resource.Resource, info.Name)
+	}
+
+	if ok {
+		o.Timeout = timeout
+	}
+
+	return watchUntil(ctx, w.DynamicClient, w.ErrOut, info, o, condition.IsMet)
+}
@@ -0,0 +1,232 @@
+package wait
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/martinohmann/kubectl-chart/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		obj         *unstructured.Unstructured
+		expectedErr string
+		expected    bool
+	}{
+		{
+			name:     "status condition met",
+			value:    "condition=Ready",
+			obj:      addCondition(newUnstructured("v1", "Foo", "ns-foo", "name-foo"), "Ready", "True"),
+			expected: true,
+		},
+		{
+			name:  "status condition not met",
+			value: "condition=Ready",
+			obj:   addCondition(newUnstructured("v1", "Foo", "ns-foo", "name-foo"), "Ready", "False"),
+		},
+		{
+			name:     "status condition with custom status",
+			value:    "condition=Degraded=false",
+			obj:      addCondition(newUnstructured("v1", "Foo", "ns-foo", "name-foo"), "Degraded", "False"),
+			expected: true,
+		},
+		{
+			name:  "missing status condition",
+			value: "condition=Ready",
+			obj:   newUnstructured("v1", "Foo", "ns-foo", "name-foo"),
+		},
+		{
+			name:        "empty status condition",
+			value:       "condition=",
+			expectedErr: `condition type must not be empty in "condition="`,
+		},
+		{
+			name:  "jsonpath met",
+			value: "jsonpath={.status.phase}=Running",
+			obj: func() *unstructured.Unstructured {
+				obj := newUnstructured("v1", "Foo", "ns-foo", "name-foo")
+				unstructured.SetNestedField(obj.Object, "Running", "status", "phase")
+				return obj
+			}(),
+			expected: true,
+		},
+		{
+			name:  "jsonpath with non-string value",
+			value: "jsonpath={.status.readyReplicas}=3",
+			obj: func() *unstructured.Unstructured {
+				obj := newUnstructured("v1", "Foo", "ns-foo", "name-foo")
+				unstructured.SetNestedField(obj.Object, int64(3), "status", "readyReplicas")
+				return obj
+			}(),
+			expected: true,
+		},
+		{
+			name:  "jsonpath missing field",
+			value: "jsonpath={.status.phase}=Running",
+			obj:   newUnstructured("v1", "Foo", "ns-foo", "name-foo"),
+		},
+		{
+			name:        "jsonpath without value",
+			value:       "jsonpath={.status.phase}",
+			expectedErr: `jsonpath condition must be of the form jsonpath={<expression>}=<value>, got "jsonpath={.status.phase}"`,
+		},
+		{
+			name:        "unsupported condition",
+			value:       "delete",
+			expectedErr: `unsupported wait condition "delete", must start with condition= or jsonpath=`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := ParseCondition(test.value)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+
+			met, err := c.IsMet(test.obj)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, met)
+		})
+	}
+}
+
+func newCustomWaitInfo(annotations map[string]string) *resource.Info {
+	obj := newUnstructured("example.com/v1", "Certificate", "ns-foo", "name-foo")
+	obj.SetAnnotations(annotations)
+
+	return &resource.Info{
+		Mapping: &kmeta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "certificates"},
+			GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Certificate"},
+		},
+		Name:      "name-foo",
+		Namespace: "ns-foo",
+		Object:    obj,
+	}
+}
+
+func TestCustomWait_ConditionFunc(t *testing.T) {
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+	fakeClient.PrependReactor("list", "certificates", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, newUnstructuredList(addCondition(
+			newUnstructured("example.com/v1", "Certificate", "ns-foo", "name-foo"),
+			"Ready", "True",
+		)), nil
+	})
+
+	fn := NewCustomConditionFunc(fakeClient, nil, nil)
+
+	info := newCustomWaitInfo(map[string]string{
+		meta.AnnotationWaitFor:     "condition=Ready",
+		meta.AnnotationWaitTimeout: "1m",
+	})
+
+	_, ok, err := fn(context.Background(), info, Options{Timeout: 10 * time.Second})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, fakeClient.Actions(), 1)
+}
+
+func TestCustomWait_ConditionFunc_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expectedErr string
+	}{
+		{
+			name:        "malformed condition",
+			annotations: map[string]string{meta.AnnotationWaitFor: "foo"},
+			expectedErr: `malformed annotation "kubectl-chart/wait-for" on certificates/name-foo: unsupported wait condition "foo", must start with condition= or jsonpath=`,
+		},
+		{
+			name: "malformed timeout",
+			annotations: map[string]string{
+				meta.AnnotationWaitFor:     "condition=Ready",
+				meta.AnnotationWaitTimeout: "foo",
+			},
+			expectedErr: `malformed annotation "kubectl-chart/wait-timeout" on certificates/name-foo`,
+		},
+		{
+			name:        "skipped without annotation and fallback",
+			expectedErr: `skipped waiting for example.com/v1, Kind=Certificate "name-foo"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fn := NewCustomConditionFunc(dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme()), nil, nil)
+
+			_, ok, err := fn(context.Background(), newCustomWaitInfo(test.annotations), Options{Timeout: 10 * time.Second})
+
+			require.Error(t, err)
+			assert.False(t, ok)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestCustomWait_ConditionFunc_Fallback(t *testing.T) {
+	called := false
+
+	fallback := func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+		called = true
+		return info.Object, true, nil
+	}
+
+	fn := NewCustomConditionFunc(dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme()), nil, fallback)
+
+	_, ok, err := fn(context.Background(), newCustomWaitInfo(nil), Options{Timeout: 10 * time.Second})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, called)
+}
+
+func TestHasCustomCondition(t *testing.T) {
+	obj := newUnstructured("v1", "Foo", "ns-foo", "name-foo")
+
+	assert.False(t, HasCustomCondition(obj))
+
+	obj.SetAnnotations(map[string]string{meta.AnnotationWaitFor: "condition=Ready"})
+
+	assert.True(t, HasCustomCondition(obj))
+}
+
+func TestCustomTimeout(t *testing.T) {
+	obj := newUnstructured("v1", "Foo", "ns-foo", "name-foo")
+
+	_, ok, err := CustomTimeout(obj)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	obj.SetAnnotations(map[string]string{meta.AnnotationWaitTimeout: "5m"})
+
+	timeout, ok, err := CustomTimeout(obj)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, timeout)
+
+	obj.SetAnnotations(map[string]string{meta.AnnotationWaitTimeout: "foo"})
+
+	_, _, err = CustomTimeout(obj)
+	require.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -24,21 +25,55 @@ type DeletionWait struct {
 	// UID is a map of resource locations to UIDs which can help in identifying
 	// objects while waiting.
 	UIDMap UIDMap
+
+	// ForceFinalizeAfter if greater than zero, the finalizers of objects that
+	// are terminating for longer than this are removed so that their
+	// deletion can complete. Only metadata.finalizers are removed.
+	ForceFinalizeAfter time.Duration
 }
 
-func NewDeletedConditionFunc(client dynamic.Interface, errOut io.Writer, uidMap UIDMap) ConditionFunc {
+// NewDeletedConditionFunc creates a ConditionFunc that waits for resources to
+// be deleted. If forceFinalizeAfter is greater than zero, the finalizers of
+// resources that are stuck in deletion for longer than that are removed.
+func NewDeletedConditionFunc(client dynamic.Interface, errOut io.Writer, uidMap UIDMap, forceFinalizeAfter time.Duration) ConditionFunc {
 	w := DeletionWait{
-		DynamicClient: client,
-		ErrOut:        errOut,
-		UIDMap:        uidMap,
+		DynamicClient:      client,
+		ErrOut:             errOut,
+		UIDMap:             uidMap,
+		ForceFinalizeAfter: forceFinalizeAfter,
 	}
 
 	return w.ConditionFunc
 }
 
-// ConditionFunc waits for something to be deleted.
-func (w DeletionWait) ConditionFunc(info *resource.Info, o Options) (runtime.Object, bool, error) {
+// ConditionFunc waits for something to be deleted. If list or watch are not
+// possible or watches are repeatedly closed prematurely, it falls back to
+// polling. Objects that are blocked by finalizers are reported and their
+// finalizers are removed if ForceFinalizeAfter is set.
+func (w DeletionWait) ConditionFunc(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 	endTime := time.Now().Add(o.Timeout)
+	w.ErrOut = o.errOut(w.ErrOut)
+
+	var closes watchCloses
+	var state finalizerState
+
+	poll := func() (runtime.Object, bool, error) {
+		obj, done, err := pollUntil(ctx, w.DynamicClient, info, o, endTime, func(obj *unstructured.Unstructured) (bool, error) {
+			if w.isGone(info, obj) {
+				return true, nil
+			}
+
+			_, err := w.checkFinalizers(info, obj, &state)
+
+			return false, err
+		})
+		if _, ok := err.(*WaitTimeoutError); ok {
+			u, _ := obj.(*unstructured.Unstructured)
+			err = finalizerTimeoutError(err, u)
+		}
+
+		return obj, done, err
+	}
 
 	for {
 		if len(info.Name) == 0 {
@@ -57,6 +92,10 @@ func (w DeletionWait) ConditionFunc(info *resource.Info, o Options) (runtime.Obj
 			return info.Object, true, nil
 		}
 
+		if isWatchUnsupported(err) {
+			return poll()
+		}
+
 		if err != nil {
 			return info.Object, false, err
 		}
@@ -66,16 +105,16 @@ func (w DeletionWait) ConditionFunc(info *resource.Info, o Options) (runtime.Obj
 		}
 
 		obj := &objList.Items[0]
-		resourceLocation := ResourceLocation{
-			GroupResource: info.Mapping.Resource.GroupResource(),
-			Namespace:     obj.GetNamespace(),
-			Name:          obj.GetName(),
+
+		o.observe(obj)
+
+		if w.isGone(info, obj) {
+			return obj, true, nil
 		}
 
-		if uid, ok := w.UIDMap[resourceLocation]; ok {
-			if obj.GetUID() != uid {
-				return obj, true, nil
-			}
+		recheck, err := w.checkFinalizers(info, obj, &state)
+		if err != nil {
+			return obj, false, err
 		}
 
 		watchOptions := metav1.ListOptions{
@@ -87,38 +126,97 @@ func (w DeletionWait) ConditionFunc(info *resource.Info, o Options) (runtime.Obj
 			Resource(info.Mapping.Resource).
 			Namespace(info.Namespace).
 			Watch(watchOptions)
+		if isWatchUnsupported(err) {
+			return poll()
+		}
+
 		if err != nil {
 			return obj, false, err
 		}
 
 		errWaitTimeout := waitTimeoutError(wait.ErrWaitTimeout, info)
-		if endTime.Sub(time.Now()) < 0 {
-			return obj, false, errWaitTimeout
+
+		remaining := endTime.Sub(time.Now())
+		if remaining < 0 {
+			return obj, false, finalizerTimeoutError(errWaitTimeout, obj)
 		}
 
-		ctx, cancel := watchtools.ContextWithOptionalTimeout(context.Background(), o.Timeout)
+		// Wake up early if the finalizers of the object need to be checked
+		// again.
+		watchTimeout := remaining
+		if recheck > 0 && recheck < watchTimeout {
+			watchTimeout = recheck
+		}
+
+		watchCtx, cancel := watchtools.ContextWithOptionalTimeout(ctx, watchTimeout)
+
+		watchStart := time.Now()
 
-		watchEvent, err := watchtools.UntilWithoutRetry(ctx, objWatch, w.isDeleted)
+		watchEvent, err := watchtools.UntilWithoutRetry(watchCtx, objWatch, func(event watch.Event) (bool, error) {
+			if event.Type == watch.Modified {
+				o.observe(event.Object)
+
+				// The object started terminating after we listed it, so
+				// its finalizers have not been checked yet.
+				if recheck == 0 && w.needsFinalizerCheck(event.Object, &state) {
+					return false, errFinalizerCheck
+				}
+			}
+
+			return w.isDeleted(event)
+		})
 
 		cancel()
 
 		switch {
+		case ctx.Err() != nil:
+			return obj, false, ctx.Err()
 		case err == nil:
 			return watchEvent.Object, true, nil
 		case err == watchtools.ErrWatchClosed:
+			if closes.closed(watchStart) {
+				return poll()
+			}
+
+			continue
+		case err == errFinalizerCheck:
 			continue
 		case err == wait.ErrWaitTimeout:
+			if time.Now().Before(endTime) {
+				// We woke up early to check the finalizers again.
+				continue
+			}
+
 			if watchEvent != nil {
-				return watchEvent.Object, false, errWaitTimeout
+				u, _ := watchEvent.Object.(*unstructured.Unstructured)
+				return watchEvent.Object, false, finalizerTimeoutError(errWaitTimeout, u)
 			}
 
-			return obj, false, errWaitTimeout
+			return obj, false, finalizerTimeoutError(errWaitTimeout, obj)
 		default:
 			return obj, false, err
 		}
 	}
 }
 
+// isGone returns true if obj is nil or if it was replaced by an object with a
+// different UID.
+func (w DeletionWait) isGone(info *resource.Info, obj *unstructured.Unstructured) bool {
+	if obj == nil {
+		return true
+	}
+
+	resourceLocation := ResourceLocation{
+		GroupResource: info.Mapping.Resource.GroupResource(),
+		Namespace:     obj.GetNamespace(),
+		Name:          obj.GetName(),
+	}
+
+	uid, ok := w.UIDMap[resourceLocation]
+
+	return ok && obj.GetUID() != uid
+}
+
 func (w DeletionWait) isDeleted(event watch.Event) (bool, error) {
 	switch event.Type {
 	case watch.Error:
@@ -2,6 +2,8 @@ package wait
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -42,3 +44,16 @@ func (e WaitTimeoutError) Error() string {
 func waitTimeoutError(err error, info *resource.Info) error {
 	return &WaitTimeoutError{err, info.Mapping.Resource.Resource, info.Name}
 }
+
+// BlockedByFinalizersError is returned if waiting for the deletion of an
+// object timed out while the object still had pending finalizers.
+type BlockedByFinalizersError struct {
+	Err         error
+	Finalizers  []string
+	Terminating time.Duration
+}
+
+// Error implements error.
+func (e BlockedByFinalizersError) Error() string {
+	return fmt.Sprintf("%s: blocked by finalizers %s for %s", e.Err.Error(), strings.Join(e.Finalizers, ", "), e.Terminating.Truncate(time.Second))
+}
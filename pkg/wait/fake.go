@@ -1,12 +1,18 @@
 package wait
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 var _ Waiter = &FakeWaiter{}
 
 type FakeWaiter struct {
 	sync.Mutex
 	Requests []*Request
+
+	// Err is returned by Wait if set.
+	Err error
 }
 
 func NewFakeWaiter() *FakeWaiter {
@@ -15,11 +21,11 @@ func NewFakeWaiter() *FakeWaiter {
 	}
 }
 
-func (d *FakeWaiter) Wait(r *Request) error {
+func (d *FakeWaiter) Wait(ctx context.Context, r *Request) error {
 	d.Lock()
 	defer d.Unlock()
 
 	d.Requests = append(d.Requests, r)
 
-	return nil
+	return d.Err
 }
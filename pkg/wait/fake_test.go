@@ -1,6 +1,7 @@
 package wait
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -15,13 +16,13 @@ func TestFakeWaiter_Wait(t *testing.T) {
 		Options: &Options{Timeout: 1 * time.Second},
 	}
 
-	err := w.Wait(r1)
+	err := w.Wait(context.Background(), r1)
 
 	require.NoError(t, err)
 
 	r2 := &Request{}
 
-	err = w.Wait(r2)
+	err = w.Wait(context.Background(), r2)
 
 	require.NoError(t, err)
 
@@ -0,0 +1,126 @@
+package wait
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// FinalizerReportDelay is the time an object has to be terminating with
+// pending finalizers before it is reported as blocked.
+var FinalizerReportDelay = 10 * time.Second
+
+// removeFinalizersPatch removes all finalizers from an object.
+var removeFinalizersPatch = []byte(`{"metadata":{"finalizers":null}}`)
+
+// errFinalizerCheck is used to abort a watch if the finalizers of the watched
+// object need to be checked.
+var errFinalizerCheck = errors.New("finalizers need to be checked")
+
+// BlockingFinalizers returns the pending finalizers of obj and the duration
+// it has been terminating for. The last return value is false if obj is not
+// terminating or does not have any finalizers.
+func BlockingFinalizers(obj *unstructured.Unstructured) ([]string, time.Duration, bool) {
+	if obj == nil || obj.GetDeletionTimestamp() == nil || len(obj.GetFinalizers()) == 0 {
+		return nil, 0, false
+	}
+
+	terminating := time.Since(obj.GetDeletionTimestamp().Time)
+	if terminating < 0 {
+		terminating = 0
+	}
+
+	return obj.GetFinalizers(), terminating, true
+}
+
+// finalizerState keeps track of the finalizer handling of a single object.
+type finalizerState struct {
+	reported bool
+}
+
+// checkFinalizers reports obj if it is blocked by finalizers for longer than
+// FinalizerReportDelay. If ForceFinalizeAfter is set, the finalizers of obj
+// are removed once it is blocked for longer than that. It returns the
+// duration after which obj needs to be checked again, which is zero if no
+// further check is necessary.
+func (w DeletionWait) checkFinalizers(info *resource.Info, obj *unstructured.Unstructured, state *finalizerState) (time.Duration, error) {
+	finalizers, terminating, blocked := BlockingFinalizers(obj)
+	if !blocked {
+		return 0, nil
+	}
+
+	var recheck time.Duration
+
+	if !state.reported {
+		if terminating < FinalizerReportDelay {
+			recheck = FinalizerReportDelay - terminating
+		} else {
+			fmt.Fprintf(w.ErrOut, "%s/%s is blocked by finalizers %s (terminating for %s)\n",
+				info.Mapping.Resource.Resource, info.Name, strings.Join(finalizers, ", "), terminating.Truncate(time.Second))
+			state.reported = true
+		}
+	}
+
+	if w.ForceFinalizeAfter <= 0 {
+		return recheck, nil
+	}
+
+	if remaining := w.ForceFinalizeAfter - terminating; remaining > 0 {
+		if recheck == 0 || remaining < recheck {
+			recheck = remaining
+		}
+
+		return recheck, nil
+	}
+
+	_, err := w.DynamicClient.
+		Resource(info.Mapping.Resource).
+		Namespace(info.Namespace).
+		Patch(info.Name, types.MergePatchType, removeFinalizersPatch, metav1.PatchOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Fprintf(w.ErrOut, "removed finalizers %s from %s/%s\n",
+		strings.Join(finalizers, ", "), info.Mapping.Resource.Resource, info.Name)
+
+	return 0, nil
+}
+
+// needsFinalizerCheck returns true if obj is blocked by finalizers and still
+// needs to be reported or force finalized.
+func (w DeletionWait) needsFinalizerCheck(obj runtime.Object, state *finalizerState) bool {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+
+	if _, _, blocked := BlockingFinalizers(u); !blocked {
+		return false
+	}
+
+	return !state.reported || w.ForceFinalizeAfter > 0
+}
+
+// finalizerTimeoutError wraps err with the finalizers that are blocking the
+// deletion of obj. If obj is not blocked by finalizers, err is returned
+// unchanged.
+func finalizerTimeoutError(err error, obj *unstructured.Unstructured) error {
+	finalizers, terminating, blocked := BlockingFinalizers(obj)
+	if !blocked {
+		return err
+	}
+
+	return &BlockedByFinalizersError{
+		Err:         err,
+		Finalizers:  finalizers,
+		Terminating: terminating,
+	}
+}
@@ -0,0 +1,180 @@
+package wait
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newTerminatingJob(terminating time.Duration, finalizers ...string) *unstructured.Unstructured {
+	obj := newIncompleteJob()
+
+	deletionTimestamp := metav1.NewTime(time.Now().Add(-terminating))
+
+	obj.SetDeletionTimestamp(&deletionTimestamp)
+	obj.SetFinalizers(finalizers)
+
+	return obj
+}
+
+func TestBlockingFinalizers(t *testing.T) {
+	finalizers, terminating, blocked := BlockingFinalizers(newTerminatingJob(time.Minute, "example.com/foo"))
+
+	assert.True(t, blocked)
+	assert.Equal(t, []string{"example.com/foo"}, finalizers)
+	assert.Equal(t, time.Minute, terminating.Truncate(time.Second))
+
+	_, _, blocked = BlockingFinalizers(newTerminatingJob(time.Minute))
+	assert.False(t, blocked)
+
+	obj := newIncompleteJob()
+	obj.SetFinalizers([]string{"example.com/foo"})
+
+	_, _, blocked = BlockingFinalizers(obj)
+	assert.False(t, blocked)
+
+	_, _, blocked = BlockingFinalizers(nil)
+	assert.False(t, blocked)
+}
+
+func TestDeletionWait_Finalizers(t *testing.T) {
+	defer setFastPolling()()
+
+	tests := []struct {
+		name               string
+		fakeClient         func() *dynamicfakeclient.FakeDynamicClient
+		forceFinalizeAfter time.Duration
+		timeout            time.Duration
+		expectedErr        string
+		expectedOutput     string
+		expectedVerbs      []string
+	}{
+		{
+			name: "reports blocking finalizers on timeout",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newTerminatingJob(time.Minute, "example.com/foo")), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					return true, watch.NewRaceFreeFake(), nil
+				})
+				return fakeClient
+			},
+			timeout:        50 * time.Millisecond,
+			expectedErr:    "timed out waiting for the condition on jobs/name-foo: blocked by finalizers example.com/foo for 1m0s",
+			expectedOutput: "jobs/name-foo is blocked by finalizers example.com/foo (terminating for 1m0s)\n",
+			expectedVerbs:  []string{"list", "watch"},
+		},
+		{
+			name: "removes finalizers after grace period",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newTerminatingJob(time.Minute, "example.com/foo", "example.com/bar")), nil
+				})
+				fakeClient.PrependReactor("patch", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					assert.Equal(t, `{"metadata":{"finalizers":null}}`, string(action.(clienttesting.PatchAction).GetPatch()))
+					return true, newTerminatingJob(time.Minute), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					fakeWatch := watch.NewRaceFreeFake()
+					fakeWatch.Delete(newIncompleteJob())
+					return true, fakeWatch, nil
+				})
+				return fakeClient
+			},
+			forceFinalizeAfter: 30 * time.Second,
+			timeout:            10 * time.Second,
+			expectedOutput: `jobs/name-foo is blocked by finalizers example.com/foo, example.com/bar (terminating for 1m0s)
+removed finalizers example.com/foo, example.com/bar from jobs/name-foo
+`,
+			expectedVerbs: []string{"list", "patch", "watch"},
+		},
+		{
+			name: "waits for grace period before removing finalizers",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				job := newTerminatingJob(0, "example.com/foo")
+
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(job), nil
+				})
+				fakeClient.PrependReactor("patch", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newTerminatingJob(0), nil
+				})
+				count := 0
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					fakeWatch := watch.NewRaceFreeFake()
+					if count > 0 {
+						fakeWatch.Delete(newIncompleteJob())
+					}
+					count++
+					return true, fakeWatch, nil
+				})
+				return fakeClient
+			},
+			// Deletion timestamps have a resolution of one second, so the
+			// grace period must be longer than that.
+			forceFinalizeAfter: 1500 * time.Millisecond,
+			timeout:            10 * time.Second,
+			expectedOutput:     "removed finalizers example.com/foo from jobs/name-foo\n",
+			expectedVerbs:      []string{"list", "watch", "list", "patch", "watch"},
+		},
+		{
+			name: "removes finalizers while polling",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("patch", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newTerminatingJob(time.Minute), nil
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(newTerminatingJob(time.Minute, "example.com/foo"), nil))
+				return fakeClient
+			},
+			forceFinalizeAfter: 30 * time.Second,
+			timeout:            10 * time.Second,
+			expectedOutput: `jobs/name-foo is blocked by finalizers example.com/foo (terminating for 1m0s)
+removed finalizers example.com/foo from jobs/name-foo
+`,
+			expectedVerbs: []string{"list", "get", "patch", "get"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			fakeClient := test.fakeClient()
+
+			fn := NewDeletedConditionFunc(fakeClient, &buf, nil, test.forceFinalizeAfter)
+
+			_, ok, err := fn(context.Background(), newJobInfo(), Options{Timeout: test.timeout})
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				assert.False(t, ok)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, ok)
+			}
+
+			assert.Equal(t, test.expectedOutput, buf.String())
+			assert.Equal(t, test.expectedVerbs, verbs(fakeClient.Actions()), spew.Sdump(fakeClient.Actions()))
+		})
+	}
+}
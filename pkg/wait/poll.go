@@ -0,0 +1,123 @@
+package wait
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+var (
+	// pollInitialInterval is the interval before the second GET request when
+	// falling back to polling. It is doubled after every request.
+	pollInitialInterval = time.Second
+
+	// pollMaxInterval is the upper bound for the polling interval.
+	pollMaxInterval = 30 * time.Second
+
+	// minWatchDuration is the minimum time a watch has to stay open to not be
+	// considered closed prematurely.
+	minWatchDuration = time.Second
+
+	// maxPrematureWatchCloses is the number of consecutive premature watch
+	// closes after which waiting falls back to polling.
+	maxPrematureWatchCloses = 3
+)
+
+// isWatchUnsupported returns true if err indicates that list or watch
+// requests are not possible for a resource, e.g. because of missing RBAC
+// permissions or API proxies that do not support watches.
+func isWatchUnsupported(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// watchCloses counts consecutive watches that were closed prematurely.
+type watchCloses struct {
+	count int
+}
+
+// closed records a closed watch that was started at start. Returns true if
+// the maximum number of consecutive premature closes is reached.
+func (c *watchCloses) closed(start time.Time) bool {
+	if time.Since(start) >= minWatchDuration {
+		c.count = 0
+		return false
+	}
+
+	c.count++
+
+	return c.count >= maxPrematureWatchCloses
+}
+
+// pollUntil periodically retrieves the object described by info via GET
+// requests until statusFn reports true, statusFn returns an error or endTime
+// is reached. The polling interval is increased exponentially. If the object
+// does not exist, statusFn is called with nil. Polling stops with the context
+// error once ctx is cancelled.
+func pollUntil(
+	ctx context.Context,
+	client dynamic.Interface,
+	info *resource.Info,
+	o Options,
+	endTime time.Time,
+	statusFn func(obj *unstructured.Unstructured) (bool, error),
+) (runtime.Object, bool, error) {
+	klog.V(1).Infof("falling back to polling for %s/%s", info.Mapping.Resource.Resource, info.Name)
+
+	var lastObj runtime.Object = info.Object
+
+	interval := pollInitialInterval
+
+	for {
+		obj, err := client.
+			Resource(info.Mapping.Resource).
+			Namespace(info.Namespace).
+			Get(info.Name, metav1.GetOptions{})
+
+		switch {
+		case apierrors.IsNotFound(err):
+			obj = nil
+		case err != nil:
+			return lastObj, false, err
+		default:
+			lastObj = obj
+			o.observe(obj)
+		}
+
+		done, err := statusFn(obj)
+		if done {
+			return lastObj, true, nil
+		}
+
+		if err != nil {
+			return lastObj, false, err
+		}
+
+		remaining := endTime.Sub(time.Now())
+		if remaining <= 0 {
+			return lastObj, false, waitTimeoutError(wait.ErrWaitTimeout, info)
+		}
+
+		if interval > remaining {
+			interval = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastObj, false, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > pollMaxInterval {
+			interval = pollMaxInterval
+		}
+	}
+}
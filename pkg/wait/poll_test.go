@@ -0,0 +1,318 @@
+package wait
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var jobsGR = schema.GroupResource{Group: "batch", Resource: "jobs"}
+
+func newJobInfo() *resource.Info {
+	return &resource.Info{
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+			GroupVersionKind: jobGVK,
+		},
+		Name:      "name-foo",
+		Namespace: "ns-foo",
+	}
+}
+
+func newIncompleteJob() *unstructured.Unstructured {
+	return newUnstructured("batch/v1", "Job", "ns-foo", "name-foo")
+}
+
+func newCompleteJob() *unstructured.Unstructured {
+	return addCondition(newIncompleteJob(), "Complete", "True")
+}
+
+// getSequence returns a reactor that returns the objects from objs in order
+// for subsequent get requests. The last object is returned repeatedly. A nil
+// object results in a not found error.
+func getSequence(objs ...*unstructured.Unstructured) clienttesting.ReactionFunc {
+	count := 0
+
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := objs[count]
+		if count < len(objs)-1 {
+			count++
+		}
+
+		if obj == nil {
+			return true, nil, apierrors.NewNotFound(jobsGR, "name-foo")
+		}
+
+		return true, obj, nil
+	}
+}
+
+func setFastPolling() func() {
+	initial, max := pollInitialInterval, pollMaxInterval
+
+	pollInitialInterval = time.Millisecond
+	pollMaxInterval = 5 * time.Millisecond
+
+	return func() {
+		pollInitialInterval, pollMaxInterval = initial, max
+	}
+}
+
+func verbs(actions []clienttesting.Action) []string {
+	result := make([]string, len(actions))
+	for i, action := range actions {
+		result[i] = action.GetVerb()
+	}
+
+	return result
+}
+
+func TestCompletionWait_PollingFallback(t *testing.T) {
+	defer setFastPolling()()
+
+	tests := []struct {
+		name          string
+		fakeClient    func() *dynamicfakeclient.FakeDynamicClient
+		timeout       time.Duration
+		expectedErr   string
+		expectedVerbs []string
+	}{
+		{
+			name: "watch forbidden",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newIncompleteJob()), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(newIncompleteJob(), newCompleteJob()))
+				return fakeClient
+			},
+			timeout:       10 * time.Second,
+			expectedVerbs: []string{"list", "watch", "get", "get"},
+		},
+		{
+			name: "list forbidden",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(nil, newCompleteJob()))
+				return fakeClient
+			},
+			timeout:       10 * time.Second,
+			expectedVerbs: []string{"list", "get", "get"},
+		},
+		{
+			name: "watch method not supported",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newIncompleteJob()), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					return true, nil, apierrors.NewMethodNotSupported(jobsGR, "watch")
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(newCompleteJob()))
+				return fakeClient
+			},
+			timeout:       10 * time.Second,
+			expectedVerbs: []string{"list", "watch", "get"},
+		},
+		{
+			name: "repeatedly closed watches",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newIncompleteJob()), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					fakeWatch := watch.NewRaceFreeFake()
+					fakeWatch.Stop()
+					return true, fakeWatch, nil
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(newCompleteJob()))
+				return fakeClient
+			},
+			timeout:       10 * time.Second,
+			expectedVerbs: []string{"list", "watch", "list", "watch", "list", "watch", "get"},
+		},
+		{
+			name: "polling times out",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(newIncompleteJob()))
+				return fakeClient
+			},
+			timeout:     20 * time.Millisecond,
+			expectedErr: "timed out waiting for the condition on jobs/name-foo",
+		},
+		{
+			name: "polling stops on status failed",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(addCondition(newIncompleteJob(), "Failed", "True")))
+				return fakeClient
+			},
+			timeout:       10 * time.Second,
+			expectedErr:   `batch/v1, Kind=Job "name-foo" is in status failed`,
+			expectedVerbs: []string{"list", "get"},
+		},
+		{
+			name: "get forbidden",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("*", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				return fakeClient
+			},
+			timeout:       10 * time.Second,
+			expectedErr:   `jobs.batch is forbidden`,
+			expectedVerbs: []string{"list", "get"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := test.fakeClient()
+
+			fn := NewCompletionConditionFunc(fakeClient, nil)
+
+			_, ok, err := fn(context.Background(), newJobInfo(), Options{Timeout: test.timeout})
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.False(t, ok)
+				assert.Contains(t, err.Error(), test.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, ok)
+			}
+
+			if test.expectedVerbs != nil {
+				assert.Equal(t, test.expectedVerbs, verbs(fakeClient.Actions()), spew.Sdump(fakeClient.Actions()))
+			}
+		})
+	}
+}
+
+func TestDeletionWait_PollingFallback(t *testing.T) {
+	defer setFastPolling()()
+
+	tests := []struct {
+		name          string
+		fakeClient    func() *dynamicfakeclient.FakeDynamicClient
+		uidMap        UIDMap
+		expectedVerbs []string
+	}{
+		{
+			name: "watch forbidden",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newIncompleteJob()), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(newIncompleteJob(), nil))
+				return fakeClient
+			},
+			expectedVerbs: []string{"list", "watch", "get", "get"},
+		},
+		{
+			name: "list method not supported",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewMethodNotSupported(jobsGR, "list")
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(nil))
+				return fakeClient
+			},
+			expectedVerbs: []string{"list", "get"},
+		},
+		{
+			name: "object recreated with different UID",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(jobsGR, "", nil)
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(
+					newUnstructuredWithUID("batch/v1", "Job", "ns-foo", "name-foo", "the-uid"),
+					newUnstructuredWithUID("batch/v1", "Job", "ns-foo", "name-foo", "other-uid"),
+				))
+				return fakeClient
+			},
+			uidMap: UIDMap{
+				ResourceLocation{GroupResource: jobsGR, Namespace: "ns-foo", Name: "name-foo"}: "the-uid",
+			},
+			expectedVerbs: []string{"list", "get", "get"},
+		},
+		{
+			name: "repeatedly closed watches",
+			fakeClient: func() *dynamicfakeclient.FakeDynamicClient {
+				fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+				fakeClient.PrependReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, newUnstructuredList(newIncompleteJob()), nil
+				})
+				fakeClient.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+					fakeWatch := watch.NewRaceFreeFake()
+					fakeWatch.Stop()
+					return true, fakeWatch, nil
+				})
+				fakeClient.PrependReactor("get", "jobs", getSequence(nil))
+				return fakeClient
+			},
+			expectedVerbs: []string{"list", "watch", "list", "watch", "list", "watch", "get"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := test.fakeClient()
+
+			fn := NewDeletedConditionFunc(fakeClient, nil, test.uidMap, 0)
+
+			_, ok, err := fn(context.Background(), newJobInfo(), Options{Timeout: 10 * time.Second})
+
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, test.expectedVerbs, verbs(fakeClient.Actions()), spew.Sdump(fakeClient.Actions()))
+		})
+	}
+}
+
+func TestWatchCloses(t *testing.T) {
+	var closes watchCloses
+
+	assert.False(t, closes.closed(time.Now()))
+	assert.False(t, closes.closed(time.Now()))
+	assert.False(t, closes.closed(time.Now().Add(-time.Minute)))
+	assert.False(t, closes.closed(time.Now()))
+	assert.False(t, closes.closed(time.Now()))
+	assert.True(t, closes.closed(time.Now()))
+}
@@ -0,0 +1,335 @@
+package wait
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/kubectl/pkg/util/term"
+)
+
+var (
+	// DefaultRefreshInterval is the interval in which the live progress
+	// display is redrawn on terminals.
+	DefaultRefreshInterval = time.Second
+
+	// DefaultHeartbeatInterval is the interval in which heartbeat lines for
+	// pending resources are printed if the output is not a terminal.
+	DefaultHeartbeatInterval = 30 * time.Second
+)
+
+// Progress tracks resources that are waited for and renders their progress.
+type Progress interface {
+	// Start starts rendering the progress.
+	Start()
+
+	// Add adds a pending resource.
+	Add(info *resource.Info)
+
+	// Observe records the latest observed state of a pending resource.
+	Observe(info *resource.Info, obj runtime.Object)
+
+	// Done removes a resource from the pending resources. fn is called while
+	// no progress is displayed, so it can safely write to the output.
+	Done(info *resource.Info, fn func())
+
+	// Print calls fn while no progress is displayed, so it can safely write
+	// to the output.
+	Print(fn func())
+
+	// Stop stops rendering and clears the progress display.
+	Stop()
+}
+
+// NewProgress creates a new Progress which writes to w. If w is a terminal,
+// a live-updating list of pending resources is displayed. Otherwise
+// heartbeat lines are printed periodically.
+func NewProgress(w io.Writer) Progress {
+	switch {
+	case w == nil:
+		return NewNoopProgress()
+	case term.IsTerminal(w):
+		return newLiveProgress(w, DefaultRefreshInterval)
+	default:
+		return newHeartbeatProgress(w, DefaultHeartbeatInterval)
+	}
+}
+
+type noopProgress struct{}
+
+// NewNoopProgress creates a new Progress which does not render anything.
+func NewNoopProgress() Progress {
+	return noopProgress{}
+}
+
+func (noopProgress) Start()                                 {}
+func (noopProgress) Add(*resource.Info)                     {}
+func (noopProgress) Observe(*resource.Info, runtime.Object) {}
+func (noopProgress) Done(_ *resource.Info, fn func())       { fn() }
+func (noopProgress) Print(fn func())                        { fn() }
+func (noopProgress) Stop()                                  {}
+
+// pendingResource holds the state of a resource that is waited for.
+type pendingResource struct {
+	info    *resource.Info
+	started time.Time
+	status  string
+}
+
+// String implements fmt.Stringer.
+func (r *pendingResource) String() string {
+	name := r.info.Name
+	if r.info.Mapping != nil {
+		name = r.info.Mapping.Resource.Resource + "/" + name
+	}
+
+	s := fmt.Sprintf("%s (%s)", name, time.Since(r.started).Round(time.Second))
+	if r.status == "" {
+		return s
+	}
+
+	return fmt.Sprintf("%s: %s", s, r.status)
+}
+
+// progressTracker keeps track of pending resources in the order they were
+// added and periodically calls renderFn until stopped.
+type progressTracker struct {
+	sync.Mutex
+	pending  []*pendingResource
+	interval time.Duration
+	renderFn func()
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+func (t *progressTracker) Start() {
+	t.stopCh = make(chan struct{})
+	t.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(t.doneCh)
+
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				t.Lock()
+				t.renderFn()
+				t.Unlock()
+			case <-t.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (t *progressTracker) stop() {
+	if t.stopCh == nil {
+		return
+	}
+
+	close(t.stopCh)
+	<-t.doneCh
+	t.stopCh = nil
+}
+
+func (t *progressTracker) Add(info *resource.Info) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.pending = append(t.pending, &pendingResource{info: info, started: time.Now()})
+}
+
+func (t *progressTracker) Observe(info *resource.Info, obj runtime.Object) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	status := describeStatus(u)
+
+	t.Lock()
+	defer t.Unlock()
+
+	for _, r := range t.pending {
+		if r.info == info {
+			r.status = status
+			return
+		}
+	}
+}
+
+// remove removes info from the pending resources. Must be called with the
+// lock held.
+func (t *progressTracker) remove(info *resource.Info) {
+	for i, r := range t.pending {
+		if r.info == info {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// liveProgress renders a live-updating list of pending resources. It is
+// meant to be used on terminals only as it relies on ANSI escape sequences
+// to redraw the list.
+type liveProgress struct {
+	progressTracker
+	w     io.Writer
+	lines int
+}
+
+func newLiveProgress(w io.Writer, interval time.Duration) *liveProgress {
+	p := &liveProgress{w: w}
+	p.interval = interval
+	p.renderFn = p.render
+
+	return p
+}
+
+func (p *liveProgress) Done(info *resource.Info, fn func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.clear()
+	p.remove(info)
+	fn()
+	p.draw()
+}
+
+func (p *liveProgress) Print(fn func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.clear()
+	fn()
+	p.draw()
+}
+
+func (p *liveProgress) Stop() {
+	p.stop()
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.clear()
+
+	// Condition funcs may still print after waiting was aborted, which must
+	// not bring back the progress display.
+	p.pending = nil
+}
+
+func (p *liveProgress) render() {
+	p.clear()
+	p.draw()
+}
+
+// clear erases the previously drawn lines. Must be called with the lock
+// held.
+func (p *liveProgress) clear() {
+	fmt.Fprint(p.w, strings.Repeat("\x1b[1A\x1b[2K", p.lines))
+	p.lines = 0
+}
+
+// draw prints a line for each pending resource. Must be called with the lock
+// held.
+func (p *liveProgress) draw() {
+	for _, r := range p.pending {
+		fmt.Fprintf(p.w, "waiting for %s\n", r)
+	}
+
+	p.lines = len(p.pending)
+}
+
+// heartbeatProgress periodically prints a line for each pending resource.
+type heartbeatProgress struct {
+	progressTracker
+	w io.Writer
+}
+
+func newHeartbeatProgress(w io.Writer, interval time.Duration) *heartbeatProgress {
+	p := &heartbeatProgress{w: w}
+	p.interval = interval
+	p.renderFn = p.render
+
+	return p
+}
+
+func (p *heartbeatProgress) Done(info *resource.Info, fn func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.remove(info)
+	fn()
+}
+
+func (p *heartbeatProgress) Print(fn func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	fn()
+}
+
+func (p *heartbeatProgress) Stop() {
+	p.stop()
+}
+
+// progressWriter is an io.Writer which writes to w without breaking the
+// progress display.
+type progressWriter struct {
+	progress Progress
+	w        io.Writer
+}
+
+// Write implements io.Writer.
+func (w *progressWriter) Write(p []byte) (n int, err error) {
+	w.progress.Print(func() {
+		n, err = w.w.Write(p)
+	})
+
+	return n, err
+}
+
+func (p *heartbeatProgress) render() {
+	for _, r := range p.pending {
+		fmt.Fprintf(p.w, "still waiting for %s\n", r)
+	}
+}
+
+// describeStatus returns a short human readable description of the status of
+// obj, e.g. the number of ready replicas of a Deployment.
+func describeStatus(obj *unstructured.Unstructured) string {
+	if obj.GetDeletionTimestamp() != nil {
+		return "terminating"
+	}
+
+	switch obj.GroupVersionKind().GroupKind().String() {
+	case "Deployment.apps", "StatefulSet.apps", "ReplicaSet.apps":
+		replicas := nestedInt64(obj, 1, "spec", "replicas")
+		ready := nestedInt64(obj, 0, "status", "readyReplicas")
+
+		return fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	case "DaemonSet.apps":
+		desired := nestedInt64(obj, 0, "status", "desiredNumberScheduled")
+		ready := nestedInt64(obj, 0, "status", "numberReady")
+
+		return fmt.Sprintf("%d/%d pods ready", ready, desired)
+	case "Job.batch":
+		active := nestedInt64(obj, 0, "status", "active")
+		succeeded := nestedInt64(obj, 0, "status", "succeeded")
+		failed := nestedInt64(obj, 0, "status", "failed")
+
+		return fmt.Sprintf("%d active, %d succeeded, %d failed", active, succeeded, failed)
+	}
+
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+
+	return strings.ToLower(phase)
+}
@@ -0,0 +1,173 @@
+package wait
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func newProgressInfo(resourceName, name string) *resource.Info {
+	return &resource.Info{
+		Mapping: &meta.RESTMapping{
+			Resource: schema.GroupVersionResource{Resource: resourceName},
+		},
+		Name: name,
+	}
+}
+
+func TestLiveProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := newLiveProgress(&buf, time.Hour)
+
+	foo := newProgressInfo("jobs", "foo")
+	bar := newProgressInfo("deployments", "bar")
+
+	p.Add(foo)
+	p.Add(bar)
+
+	job := newUnstructured("batch/v1", "Job", "ns-foo", "foo")
+	unstructured.SetNestedField(job.Object, int64(1), "status", "active")
+
+	p.Observe(foo, job)
+	p.render()
+
+	assert.Equal(t, "waiting for jobs/foo (0s): 1 active, 0 succeeded, 0 failed\nwaiting for deployments/bar (0s)\n", buf.String())
+
+	buf.Reset()
+
+	p.Done(foo, func() {
+		buf.WriteString("job.batch/foo completed\n")
+	})
+
+	assert.Equal(t, "\x1b[1A\x1b[2K\x1b[1A\x1b[2Kjob.batch/foo completed\nwaiting for deployments/bar (0s)\n", buf.String())
+
+	buf.Reset()
+
+	p.Stop()
+
+	assert.Equal(t, "\x1b[1A\x1b[2K", buf.String())
+}
+
+func TestProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := newLiveProgress(&buf, time.Hour)
+
+	p.Add(newProgressInfo("jobs", "foo"))
+	p.render()
+
+	buf.Reset()
+
+	w := &progressWriter{progress: p, w: &buf}
+
+	n, err := fmt.Fprintln(w, "jobs/foo is blocked by finalizers")
+
+	require.NoError(t, err)
+	assert.Equal(t, 34, n)
+	assert.Equal(t, "\x1b[1A\x1b[2Kjobs/foo is blocked by finalizers\nwaiting for jobs/foo (0s)\n", buf.String())
+
+	p.Stop()
+	buf.Reset()
+
+	fmt.Fprintln(w, "removed finalizers")
+
+	// Output after the progress was stopped must not redraw it.
+	assert.Equal(t, "removed finalizers\n", buf.String())
+}
+
+func TestHeartbeatProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := newHeartbeatProgress(&buf, 10*time.Millisecond)
+
+	foo := newProgressInfo("jobs", "foo")
+	bar := newProgressInfo("jobs", "bar")
+
+	p.Add(foo)
+	p.Add(bar)
+
+	called := false
+
+	p.Done(bar, func() {
+		called = true
+	})
+
+	assert.True(t, called)
+
+	p.Start()
+	time.Sleep(25 * time.Millisecond)
+	p.Stop()
+
+	assert.Contains(t, buf.String(), "still waiting for jobs/foo (0s)\n")
+	assert.NotContains(t, buf.String(), "jobs/bar")
+}
+
+func TestNewProgress(t *testing.T) {
+	assert.IsType(t, noopProgress{}, NewProgress(nil))
+	assert.IsType(t, &heartbeatProgress{}, NewProgress(&bytes.Buffer{}))
+}
+
+func TestDescribeStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		expected string
+	}{
+		{
+			name: "deployment",
+			obj: newReadinessObject("apps/v1", "Deployment", 1,
+				map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{"readyReplicas": int64(2)},
+			),
+			expected: "2/3 replicas ready",
+		},
+		{
+			name: "daemonset",
+			obj: newReadinessObject("apps/v1", "DaemonSet", 1, nil,
+				map[string]interface{}{"desiredNumberScheduled": int64(3), "numberReady": int64(1)},
+			),
+			expected: "1/3 pods ready",
+		},
+		{
+			name: "job",
+			obj: newReadinessObject("batch/v1", "Job", 1, nil,
+				map[string]interface{}{"active": int64(1), "failed": int64(2)},
+			),
+			expected: "1 active, 0 succeeded, 2 failed",
+		},
+		{
+			name:     "pvc",
+			obj:      newReadinessObject("v1", "PersistentVolumeClaim", 0, nil, map[string]interface{}{"phase": "Pending"}),
+			expected: "pending",
+		},
+		{
+			name: "terminating",
+			obj: func() *unstructured.Unstructured {
+				obj := newUnstructured("v1", "Namespace", "", "foo")
+				obj.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+				return obj
+			}(),
+			expected: "terminating",
+		},
+		{
+			name: "unknown",
+			obj:  newUnstructured("v1", "ConfigMap", "ns-foo", "foo"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, describeStatus(test.obj))
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package wait
+
+import (
+	"context"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+)
+
+// readinessFuncs maps GroupKinds to functions that check if an object of
+// this kind is ready.
+var readinessFuncs = map[schema.GroupKind]func(obj *unstructured.Unstructured) (bool, error){
+	{Group: "apps", Kind: "Deployment"}:                               isDeploymentReady,
+	{Group: "apps", Kind: "StatefulSet"}:                              isStatefulSetReady,
+	{Group: "apps", Kind: "DaemonSet"}:                                isDaemonSetReady,
+	{Group: "batch", Kind: "Job"}:                                     hasStatusComplete,
+	{Group: "", Kind: "PersistentVolumeClaim"}:                        isPersistentVolumeClaimBound,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: isCustomResourceDefinitionEstablished,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:             isAPIServiceAvailable,
+	{Group: "snapshot.storage.k8s.io", Kind: "VolumeSnapshot"}:        isVolumeSnapshotReady,
+}
+
+// SupportsReadiness returns true if the readiness of resources of given
+// GroupKind can be determined by the ReadinessWait condition func.
+func SupportsReadiness(gk schema.GroupKind) bool {
+	_, ok := readinessFuncs[gk]
+
+	return ok
+}
+
+type ReadinessWait struct {
+	DynamicClient dynamic.Interface
+	ErrOut        io.Writer
+}
+
+func NewReadinessConditionFunc(client dynamic.Interface, errOut io.Writer) ConditionFunc {
+	w := ReadinessWait{
+		DynamicClient: client,
+		ErrOut:        errOut,
+	}
+
+	return w.ConditionFunc
+}
+
+// ConditionFunc waits for a resource to become ready. For workload resources
+// this means that their rollout finished, Jobs have to complete,
+// PersistentVolumeClaims have to be bound, CustomResourceDefinitions have to
+// be established, APIServices have to be available and VolumeSnapshots have
+// to be ready to use. Waiting on resources of other kinds is skipped.
+func (w ReadinessWait) ConditionFunc(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+	gvk := info.Mapping.GroupVersionKind
+
+	isReady, ok := readinessFuncs[gvk.GroupKind()]
+	if !ok {
+		return info.Object, false, &WaitSkippedError{Name: info.Name, GroupVersionKind: gvk}
+	}
+
+	return watchUntil(ctx, w.DynamicClient, w.ErrOut, info, o, isReady)
+}
+
+func isDeploymentReady(obj *unstructured.Unstructured) (bool, error) {
+	if !hasObservedGeneration(obj) {
+		return false, nil
+	}
+
+	replicas := nestedInt64(obj, 1, "spec", "replicas")
+	updatedReplicas := nestedInt64(obj, 0, "status", "updatedReplicas")
+	statusReplicas := nestedInt64(obj, 0, "status", "replicas")
+	availableReplicas := nestedInt64(obj, 0, "status", "availableReplicas")
+
+	return updatedReplicas == replicas &&
+		statusReplicas == updatedReplicas &&
+		availableReplicas == updatedReplicas, nil
+}
+
+func isStatefulSetReady(obj *unstructured.Unstructured) (bool, error) {
+	if !hasObservedGeneration(obj) {
+		return false, nil
+	}
+
+	replicas := nestedInt64(obj, 1, "spec", "replicas")
+	readyReplicas := nestedInt64(obj, 0, "status", "readyReplicas")
+
+	if readyReplicas != replicas {
+		return false, nil
+	}
+
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		// Pods are not updated automatically, so there is no rollout to
+		// wait for.
+		return true, nil
+	}
+
+	partition := nestedInt64(obj, 0, "spec", "updateStrategy", "rollingUpdate", "partition")
+	updatedReplicas := nestedInt64(obj, 0, "status", "updatedReplicas")
+
+	return updatedReplicas >= replicas-partition, nil
+}
+
+func isDaemonSetReady(obj *unstructured.Unstructured) (bool, error) {
+	if !hasObservedGeneration(obj) {
+		return false, nil
+	}
+
+	desired := nestedInt64(obj, 0, "status", "desiredNumberScheduled")
+	updated := nestedInt64(obj, 0, "status", "updatedNumberScheduled")
+	available := nestedInt64(obj, 0, "status", "numberAvailable")
+
+	return updated == desired && available == desired, nil
+}
+
+func isVolumeSnapshotReady(obj *unstructured.Unstructured) (bool, error) {
+	ready, _, err := unstructured.NestedBool(obj.Object, "status", "readyToUse")
+
+	return ready, err
+}
+
+func isPersistentVolumeClaimBound(obj *unstructured.Unstructured) (bool, error) {
+	phase, _, err := unstructured.NestedString(obj.Object, "status", "phase")
+
+	return phase == "Bound", err
+}
+
+func isCustomResourceDefinitionEstablished(obj *unstructured.Unstructured) (bool, error) {
+	return hasConditionStatus(obj, "established", "true")
+}
+
+func isAPIServiceAvailable(obj *unstructured.Unstructured) (bool, error) {
+	return hasConditionStatus(obj, "available", "true")
+}
+
+// hasConditionStatus returns true if obj has a status condition of
+// conditionType with given status. Both conditionType and status must be
+// lowercase.
+func hasConditionStatus(obj *unstructured.Unstructured, conditionType, status string) (bool, error) {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+
+	actual, ok := getConditionStatus(conditions, conditionType)
+
+	return ok && actual == status, nil
+}
+
+// hasObservedGeneration returns true if the controller already observed the
+// latest generation of obj.
+func hasObservedGeneration(obj *unstructured.Unstructured) bool {
+	observedGeneration := nestedInt64(obj, 0, "status", "observedGeneration")
+
+	return observedGeneration >= obj.GetGeneration()
+}
+
+// nestedInt64 returns the int64 value at fields or defaultValue if it is not
+// present.
+func nestedInt64(obj *unstructured.Unstructured, defaultValue int64, fields ...string) int64 {
+	value, found, err := unstructured.NestedInt64(obj.Object, fields...)
+	if !found || err != nil {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,269 @@
+package wait
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfakeclient "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newReadinessObject(apiVersion, kind string, generation int64, spec, status map[string]interface{}) *unstructured.Unstructured {
+	obj := newUnstructured(apiVersion, kind, "ns-foo", "name-foo")
+	obj.SetGeneration(generation)
+
+	if spec != nil {
+		obj.Object["spec"] = spec
+	}
+
+	if status != nil {
+		obj.Object["status"] = status
+	}
+
+	return obj
+}
+
+func TestReadinessFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name: "deployment rolled out",
+			obj: newReadinessObject("apps/v1", "Deployment", 2,
+				map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{
+					"observedGeneration": int64(2),
+					"replicas":           int64(3),
+					"updatedReplicas":    int64(3),
+					"availableReplicas":  int64(3),
+				},
+			),
+			expected: true,
+		},
+		{
+			name: "deployment generation not observed",
+			obj: newReadinessObject("apps/v1", "Deployment", 3,
+				map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{
+					"observedGeneration": int64(2),
+					"replicas":           int64(3),
+					"updatedReplicas":    int64(3),
+					"availableReplicas":  int64(3),
+				},
+			),
+		},
+		{
+			name: "deployment with old replicas",
+			obj: newReadinessObject("apps/v1", "Deployment", 2,
+				map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{
+					"observedGeneration": int64(2),
+					"replicas":           int64(4),
+					"updatedReplicas":    int64(3),
+					"availableReplicas":  int64(3),
+				},
+			),
+		},
+		{
+			name: "deployment defaults to one replica",
+			obj: newReadinessObject("apps/v1", "Deployment", 1, nil,
+				map[string]interface{}{
+					"observedGeneration": int64(1),
+					"replicas":           int64(1),
+					"updatedReplicas":    int64(1),
+					"availableReplicas":  int64(1),
+				},
+			),
+			expected: true,
+		},
+		{
+			name: "statefulset rolled out",
+			obj: newReadinessObject("apps/v1", "StatefulSet", 1,
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{
+					"observedGeneration": int64(1),
+					"readyReplicas":      int64(2),
+					"updatedReplicas":    int64(2),
+				},
+			),
+			expected: true,
+		},
+		{
+			name: "statefulset not updated",
+			obj: newReadinessObject("apps/v1", "StatefulSet", 1,
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{
+					"observedGeneration": int64(1),
+					"readyReplicas":      int64(2),
+					"updatedReplicas":    int64(1),
+				},
+			),
+		},
+		{
+			name: "statefulset with partition",
+			obj: newReadinessObject("apps/v1", "StatefulSet", 1,
+				map[string]interface{}{
+					"replicas": int64(2),
+					"updateStrategy": map[string]interface{}{
+						"type":          "RollingUpdate",
+						"rollingUpdate": map[string]interface{}{"partition": int64(1)},
+					},
+				},
+				map[string]interface{}{
+					"observedGeneration": int64(1),
+					"readyReplicas":      int64(2),
+					"updatedReplicas":    int64(1),
+				},
+			),
+			expected: true,
+		},
+		{
+			name: "statefulset with OnDelete strategy",
+			obj: newReadinessObject("apps/v1", "StatefulSet", 1,
+				map[string]interface{}{
+					"replicas":       int64(2),
+					"updateStrategy": map[string]interface{}{"type": "OnDelete"},
+				},
+				map[string]interface{}{
+					"observedGeneration": int64(1),
+					"readyReplicas":      int64(2),
+				},
+			),
+			expected: true,
+		},
+		{
+			name: "daemonset rolled out",
+			obj: newReadinessObject("apps/v1", "DaemonSet", 1, nil,
+				map[string]interface{}{
+					"observedGeneration":     int64(1),
+					"desiredNumberScheduled": int64(3),
+					"updatedNumberScheduled": int64(3),
+					"numberAvailable":        int64(3),
+				},
+			),
+			expected: true,
+		},
+		{
+			name: "daemonset not available",
+			obj: newReadinessObject("apps/v1", "DaemonSet", 1, nil,
+				map[string]interface{}{
+					"observedGeneration":     int64(1),
+					"desiredNumberScheduled": int64(3),
+					"updatedNumberScheduled": int64(3),
+					"numberAvailable":        int64(2),
+				},
+			),
+		},
+		{
+			name:     "pvc bound",
+			obj:      newReadinessObject("v1", "PersistentVolumeClaim", 0, nil, map[string]interface{}{"phase": "Bound"}),
+			expected: true,
+		},
+		{
+			name: "pvc pending",
+			obj:  newReadinessObject("v1", "PersistentVolumeClaim", 0, nil, map[string]interface{}{"phase": "Pending"}),
+		},
+		{
+			name:     "crd established",
+			obj:      addCondition(newReadinessObject("apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", 0, nil, nil), "Established", "True"),
+			expected: true,
+		},
+		{
+			name: "crd not established",
+			obj:  addCondition(newReadinessObject("apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", 0, nil, nil), "Established", "False"),
+		},
+		{
+			name:     "apiservice available",
+			obj:      addCondition(newReadinessObject("apiregistration.k8s.io/v1", "APIService", 0, nil, nil), "Available", "True"),
+			expected: true,
+		},
+		{
+			name:     "volumesnapshot ready to use",
+			obj:      newReadinessObject("snapshot.storage.k8s.io/v1beta1", "VolumeSnapshot", 0, nil, map[string]interface{}{"readyToUse": true}),
+			expected: true,
+		},
+		{
+			name: "volumesnapshot not ready to use",
+			obj:  newReadinessObject("snapshot.storage.k8s.io/v1beta1", "VolumeSnapshot", 0, nil, map[string]interface{}{"readyToUse": false}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isReady, ok := readinessFuncs[test.obj.GroupVersionKind().GroupKind()]
+			require.True(t, ok)
+
+			ready, err := isReady(test.obj)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, ready)
+		})
+	}
+}
+
+func TestReadinessWait_ConditionFunc(t *testing.T) {
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	fakeClient := dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme())
+	fakeClient.PrependReactor("list", "deployments", func(action clienttesting.Action) (handled bool, ret runtime.Object, err error) {
+		return true, newUnstructuredList(newReadinessObject("apps/v1", "Deployment", 1, nil,
+			map[string]interface{}{
+				"observedGeneration": int64(1),
+				"replicas":           int64(1),
+				"updatedReplicas":    int64(1),
+				"availableReplicas":  int64(1),
+			},
+		)), nil
+	})
+
+	info := &resource.Info{
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			GroupVersionKind: deploymentGVK,
+		},
+		Name:      "name-foo",
+		Namespace: "ns-foo",
+	}
+
+	w := NewReadinessConditionFunc(fakeClient, nil)
+
+	_, ready, err := w(context.Background(), info, Options{Timeout: 10 * time.Second})
+
+	require.NoError(t, err)
+	assert.True(t, ready)
+	assert.Len(t, fakeClient.Actions(), 1)
+}
+
+func TestReadinessWait_ConditionFunc_Skipped(t *testing.T) {
+	info := &resource.Info{
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		},
+		Name:      "name-foo",
+		Namespace: "ns-foo",
+	}
+
+	w := NewReadinessConditionFunc(dynamicfakeclient.NewSimpleDynamicClient(runtime.NewScheme()), nil)
+
+	_, ready, err := w(context.Background(), info, Options{Timeout: 10 * time.Second})
+
+	require.Error(t, err)
+	assert.False(t, ready)
+	assert.IsType(t, &WaitSkippedError{}, err)
+}
+
+func TestSupportsReadiness(t *testing.T) {
+	assert.True(t, SupportsReadiness(schema.GroupKind{Group: "apps", Kind: "Deployment"}))
+	assert.False(t, SupportsReadiness(schema.GroupKind{Kind: "ConfigMap"}))
+}
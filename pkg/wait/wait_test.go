@@ -4,9 +4,14 @@ package wait
 // added features to ensure that the waiting behaviour is similar.
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -329,10 +334,10 @@ func TestWaitForDeletion(t *testing.T) {
 				if len(actions) != 2 {
 					t.Fatal(spew.Sdump(actions))
 				}
-				if !actions[0].Matches("list", "theresource-1") {
-					t.Error(spew.Sdump(actions))
-				}
-				if !actions[1].Matches("list", "theresource-2") {
+				// Resources are waited for concurrently, so the order of
+				// actions is not deterministic.
+				if !(actions[0].Matches("list", "theresource-1") && actions[1].Matches("list", "theresource-2")) &&
+					!(actions[0].Matches("list", "theresource-2") && actions[1].Matches("list", "theresource-1")) {
 					t.Error(spew.Sdump(actions))
 				}
 			},
@@ -405,10 +410,10 @@ func TestWaitForDeletion(t *testing.T) {
 					Timeout: test.timeout,
 				},
 				Visitor:     resource.InfoListVisitor(test.infos),
-				ConditionFn: NewDeletedConditionFunc(fakeClient, ioutil.Discard, test.uidMap),
+				ConditionFn: NewDeletedConditionFunc(fakeClient, ioutil.Discard, test.uidMap, 0),
 			}
 
-			err := w.Wait(req)
+			err := w.Wait(context.Background(), req)
 			switch {
 			case err == nil && len(test.expectedErr) == 0:
 			case err != nil && len(test.expectedErr) == 0:
@@ -801,7 +806,7 @@ func TestWaitForCompletion(t *testing.T) {
 				ConditionFn: NewCompletionConditionFunc(fakeClient, ioutil.Discard),
 			}
 
-			err := w.Wait(req)
+			err := w.Wait(context.Background(), req)
 			switch {
 			case err == nil && len(test.expectedErr) == 0:
 			case err != nil && len(test.expectedErr) == 0:
@@ -828,14 +833,14 @@ func TestWait_Errors(t *testing.T) {
 	}{
 		{
 			name: "forces error if condition was not satisfied and no error was returned",
-			conditionFn: func(info *resource.Info, o Options) (runtime.Object, bool, error) {
+			conditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 				return newUnstructured("batch", "v1", "ns-foo", "name-foo"), false, nil
 			},
 			expectedErr: "&{map[apiVersion:batch kind:v1 metadata:map[name:name-foo namespace:ns-foo uid:some-UID-value]]} unsatisified for unknown reason",
 		},
 		{
 			name: "does not ignore WaitTimeoutError if AllowFailure is not set",
-			conditionFn: func(info *resource.Info, o Options) (runtime.Object, bool, error) {
+			conditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 				err := &WaitTimeoutError{
 					Name:     "foo",
 					Resource: "jobs",
@@ -850,7 +855,7 @@ func TestWait_Errors(t *testing.T) {
 			options: Options{
 				AllowFailure: true,
 			},
-			conditionFn: func(info *resource.Info, o Options) (runtime.Object, bool, error) {
+			conditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 				err := &WaitTimeoutError{
 					Name:     "foo",
 					Resource: "jobs",
@@ -861,7 +866,7 @@ func TestWait_Errors(t *testing.T) {
 		},
 		{
 			name: "does not ignore StatusFailedError if AllowFailure is not set",
-			conditionFn: func(info *resource.Info, o Options) (runtime.Object, bool, error) {
+			conditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 				err := &StatusFailedError{
 					Name: "foo",
 					GroupVersionKind: schema.GroupVersionKind{
@@ -879,7 +884,7 @@ func TestWait_Errors(t *testing.T) {
 			options: Options{
 				AllowFailure: true,
 			},
-			conditionFn: func(info *resource.Info, o Options) (runtime.Object, bool, error) {
+			conditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 				err := &StatusFailedError{
 					Name: "foo",
 					GroupVersionKind: schema.GroupVersionKind{
@@ -893,7 +898,7 @@ func TestWait_Errors(t *testing.T) {
 		},
 		{
 			name: "ignores WaitSkippedError",
-			conditionFn: func(info *resource.Info, o Options) (runtime.Object, bool, error) {
+			conditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
 				err := &WaitSkippedError{
 					Name: "foo",
 					GroupVersionKind: schema.GroupVersionKind{
@@ -919,7 +924,7 @@ func TestWait_Errors(t *testing.T) {
 				ConditionFn: test.conditionFn,
 			}
 
-			err := w.Wait(req)
+			err := w.Wait(context.Background(), req)
 			if test.expectedErr != "" {
 				require.Error(t, err)
 				assert.Equal(t, test.expectedErr, err.Error())
@@ -930,6 +935,193 @@ func TestWait_Errors(t *testing.T) {
 	}
 }
 
+func TestWait_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := printers.ResourcePrinterFunc(func(obj runtime.Object, w io.Writer) error {
+		_, err := fmt.Fprintln(w, obj.(*unstructured.Unstructured).GetName())
+		return err
+	})
+
+	w := NewWaiter(genericclioptions.IOStreams{Out: &buf, ErrOut: ioutil.Discard}, p)
+
+	req := &Request{
+		Visitor: resource.InfoListVisitor([]*resource.Info{
+			{Name: "slow", Object: newUnstructured("batch/v1", "Job", "ns-foo", "slow")},
+			{Name: "fast", Object: newUnstructured("batch/v1", "Job", "ns-foo", "fast")},
+		}),
+		ConditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+			if info.Name == "slow" {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			return info.Object, true, nil
+		},
+	}
+
+	start := time.Now()
+
+	require.NoError(t, w.Wait(context.Background(), req))
+
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+	assert.Equal(t, "fast\nslow\n", buf.String())
+}
+
+func TestWait_AggregatesErrors(t *testing.T) {
+	w := NewSilentWaiter(genericclioptions.NewTestIOStreamsDiscard())
+
+	req := &Request{
+		Visitor: resource.InfoListVisitor([]*resource.Info{
+			{Name: "foo", Object: newUnstructured("batch/v1", "Job", "ns-foo", "foo")},
+			{Name: "bar", Object: newUnstructured("batch/v1", "Job", "ns-foo", "bar")},
+		}),
+		ConditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+			return info.Object, false, errors.New(info.Name + " failed")
+		},
+	}
+
+	err := w.Wait(context.Background(), req)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "foo failed")
+	assert.Contains(t, err.Error(), "bar failed")
+}
+
+func TestWait_Timeout(t *testing.T) {
+	w := NewSilentWaiter(genericclioptions.NewTestIOStreamsDiscard())
+
+	var timeouts []time.Duration
+	var mu sync.Mutex
+
+	req := &Request{
+		Options: &Options{Timeout: time.Hour},
+		Timeout: 50 * time.Millisecond,
+		Visitor: resource.InfoListVisitor([]*resource.Info{
+			{
+				Mapping: &meta.RESTMapping{
+					Resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+				},
+				Name:   "name-foo",
+				Object: newUnstructured("batch/v1", "Job", "ns-foo", "name-foo"),
+			},
+		}),
+		ConditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+			mu.Lock()
+			timeouts = append(timeouts, o.Timeout)
+			mu.Unlock()
+
+			time.Sleep(time.Second)
+
+			return info.Object, true, nil
+		},
+	}
+
+	start := time.Now()
+
+	err := w.Wait(context.Background(), req)
+
+	require.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, "timed out after 50ms waiting for all resources on jobs/name-foo", err.Error())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []time.Duration{50 * time.Millisecond}, timeouts)
+}
+
+func TestWait_Timeout_CancelsConditionFunc(t *testing.T) {
+	w := NewSilentWaiter(genericclioptions.NewTestIOStreamsDiscard())
+
+	cancelled := make(chan struct{})
+
+	req := &Request{
+		Options: &Options{Timeout: time.Hour},
+		Timeout: 50 * time.Millisecond,
+		Visitor: resource.InfoListVisitor([]*resource.Info{
+			{
+				Mapping: &meta.RESTMapping{
+					Resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+				},
+				Name:   "name-foo",
+				Object: newUnstructured("batch/v1", "Job", "ns-foo", "name-foo"),
+			},
+		}),
+		ConditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return info.Object, false, ctx.Err()
+		},
+	}
+
+	err := w.Wait(context.Background(), req)
+
+	require.Error(t, err)
+	assert.Equal(t, "timed out after 50ms waiting for all resources on jobs/name-foo", err.Error())
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected context of condition func to be cancelled")
+	}
+}
+
+func TestWait_ErrOut(t *testing.T) {
+	streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+
+	w := NewSilentWaiter(streams)
+
+	req := &Request{
+		Visitor: resource.InfoListVisitor([]*resource.Info{
+			{
+				Mapping: &meta.RESTMapping{
+					Resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+				},
+				Name:   "name-foo",
+				Object: newUnstructured("batch/v1", "Job", "ns-foo", "name-foo"),
+			},
+		}),
+		ConditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+			require.NotNil(t, o.ErrOut)
+			fmt.Fprintln(o.ErrOut, "some message")
+			return info.Object, true, nil
+		},
+	}
+
+	require.NoError(t, w.Wait(context.Background(), req))
+
+	assert.Equal(t, "some message\n", errOut.String())
+	assert.Empty(t, out.String())
+}
+
+func TestWait_Cancelled(t *testing.T) {
+	w := NewSilentWaiter(genericclioptions.NewTestIOStreamsDiscard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req := &Request{
+		Options: &Options{Timeout: time.Hour},
+		Visitor: resource.InfoListVisitor([]*resource.Info{
+			{
+				Mapping: &meta.RESTMapping{
+					Resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"},
+				},
+				Name:   "name-foo",
+				Object: newUnstructured("batch/v1", "Job", "ns-foo", "name-foo"),
+			},
+		}),
+		ConditionFn: func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error) {
+			cancel()
+			<-ctx.Done()
+			return info.Object, false, ctx.Err()
+		},
+	}
+
+	err := w.Wait(ctx, req)
+
+	require.Equal(t, context.Canceled, err)
+}
+
 func TestRequest_OptionsFor(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -1,7 +1,9 @@
 package wait
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/pkg/errors"
@@ -9,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -23,6 +26,31 @@ type Options struct {
 	// AllowFailure indicates if an error during waiting for this resource is
 	// acceptable. In this case the error will just be logged.
 	AllowFailure bool
+
+	// Observer is notified about every observed state of the resource while
+	// waiting. It is set by the Waiter and may be nil.
+	Observer func(obj runtime.Object)
+
+	// ErrOut receives messages that are written while waiting, e.g. watch
+	// errors or finalizer reports. It is set by the Waiter so that these
+	// messages do not break the progress display and may be nil.
+	ErrOut io.Writer
+}
+
+// observe passes obj to the Observer if there is one.
+func (o Options) observe(obj runtime.Object) {
+	if o.Observer != nil {
+		o.Observer(obj)
+	}
+}
+
+// errOut returns the ErrOut writer of o or fallback if it is not set.
+func (o Options) errOut(fallback io.Writer) io.Writer {
+	if o.ErrOut != nil {
+		return o.ErrOut
+	}
+
+	return fallback
 }
 
 // ResourceOptions defines custom wait options for resource UIDs.
@@ -44,6 +72,11 @@ type Request struct {
 
 	// Visitor will be used to walk the resources that should be waited on.
 	Visitor resource.Visitor
+
+	// Timeout is an optional overall deadline for waiting on all resources.
+	// Per-resource timeouts are capped to the time remaining until the
+	// deadline. If zero, only per-resource timeouts apply.
+	Timeout time.Duration
 }
 
 var (
@@ -60,8 +93,8 @@ var (
 type Waiter interface {
 	// Wait waits for all resources using the provided options. If no condition
 	// func is defined in the options the default condition to wait for is
-	// resource deletion.
-	Wait(r *Request) error
+	// resource deletion. Waiting stops if ctx is cancelled.
+	Wait(ctx context.Context, r *Request) error
 }
 
 // OptionsFor returns Options for a resource info. If the resource has a UID
@@ -97,70 +130,211 @@ type UIDMap map[ResourceLocation]types.UID
 
 // ConditionFunc is called for every resource that is waited for. It should
 // check if the waiting condition is met or not, and if errors occured while
-// waiting.
-type ConditionFunc func(info *resource.Info, o Options) (runtime.Object, bool, error)
+// waiting. It should stop waiting once ctx is cancelled.
+type ConditionFunc func(ctx context.Context, info *resource.Info, o Options) (runtime.Object, bool, error)
 
 // waiter is a generic Waiter implementation.
 type waiter struct {
 	genericclioptions.IOStreams
 
-	Printer printers.ResourcePrinter
+	Printer  printers.ResourcePrinter
+	Progress Progress
 }
 
-// NewSilentWaiter creates a new Waiter which discards all wait output.
+// NewSilentWaiter creates a new Waiter which does not print the resources it
+// waited for. The progress of pending resources is still rendered to the
+// ErrOut stream.
 func NewSilentWaiter(streams genericclioptions.IOStreams) Waiter {
-	return NewWaiter(streams, printers.NewDiscardingPrinter())
+	return &waiter{
+		IOStreams: streams,
+		Printer:   printers.NewDiscardingPrinter(),
+		Progress:  NewProgress(streams.ErrOut),
+	}
 }
 
-// NewWaiter creates a new Waiter value.
+// NewWaiter creates a new Waiter value. The progress of pending resources is
+// rendered to the ErrOut stream.
 func NewWaiter(streams genericclioptions.IOStreams, p printers.ResourcePrinter) Waiter {
 	return &waiter{
 		IOStreams: streams,
 		Printer:   p,
+		Progress:  NewProgress(streams.ErrOut),
 	}
 }
 
-// Waiter waits for all resources using the provided options. If no condition
-// func is defined in the options the default condition to wait for is resource
-// deletion.
-func (w *waiter) Wait(r *Request) error {
+// result is the outcome of waiting for a single resource.
+type result struct {
+	info    *resource.Info
+	options Options
+	obj     runtime.Object
+	success bool
+	err     error
+}
+
+// Wait waits for all resources concurrently using the provided options. If
+// no condition func is defined in the options the default condition to wait
+// for is resource deletion. Results are printed as soon as waiting for a
+// resource finishes. Errors for all resources are aggregated. If ctx is
+// cancelled, waiting stops and the context error is returned.
+func (w *waiter) Wait(ctx context.Context, r *Request) error {
+	infos := make([]*resource.Info, 0)
+
 	err := r.Visitor.Visit(func(info *resource.Info, err error) error {
 		if err != nil {
 			return err
 		}
 
-		options := r.OptionsFor(info)
+		infos = append(infos, info)
 
-		obj, success, err := r.ConditionFn(info, options)
-		if success {
-			w.Printer.PrintObj(obj, w.Out)
-			return nil
-		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		skipErr, ok := err.(*WaitSkippedError)
-		if ok && skipErr != nil {
-			fmt.Fprintln(w.ErrOut, skipErr.Error())
-			return nil
-		}
+	if len(infos) == 0 {
+		return nil
+	}
+
+	// Condition funcs receive a context which is cancelled once the overall
+	// deadline is exceeded, so that they do not outlive the request.
+	waitCtx := ctx
+
+	var deadline <-chan struct{}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		waitCtx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+
+		deadline = waitCtx.Done()
+	}
 
-		statusError, ok := err.(*StatusFailedError)
-		if ok && statusError != nil && options.AllowFailure {
-			fmt.Fprintln(w.ErrOut, statusError.Error())
-			return nil
+	// The channel is buffered so that goroutines do not block forever if
+	// the deadline is exceeded before all of them finished.
+	resultCh := make(chan *result, len(infos))
+	pending := make(map[*resource.Info]Options, len(infos))
+
+	w.Progress.Start()
+	defer w.Progress.Stop()
+
+	errOut := &progressWriter{progress: w.Progress, w: w.ErrOut}
+
+	for _, info := range infos {
+		options := r.OptionsFor(info)
+		if r.Timeout > 0 && options.Timeout > r.Timeout {
+			options.Timeout = r.Timeout
 		}
 
-		timeoutError, ok := err.(*WaitTimeoutError)
-		if ok && timeoutError != nil && options.AllowFailure {
-			fmt.Fprintln(w.ErrOut, timeoutError.Error())
-			return nil
+		pending[info] = options
+
+		w.Progress.Add(info)
+
+		info := info
+		options.Observer = func(obj runtime.Object) {
+			w.Progress.Observe(info, obj)
 		}
+		options.ErrOut = errOut
 
-		if err == nil {
-			return errors.Errorf("%v unsatisified for unknown reason", obj)
+		go func(info *resource.Info, options Options) {
+			obj, success, err := r.ConditionFn(waitCtx, info, options)
+
+			resultCh <- &result{info, options, obj, success, err}
+		}(info, options)
+	}
+
+	errs := make([]error, 0)
+
+	handleResult := func(res *result) {
+		delete(pending, res.info)
+
+		w.Progress.Done(res.info, func() {
+			if err := w.handleResult(res); err != nil {
+				errs = append(errs, err)
+			}
+		})
+	}
+
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case res := <-resultCh:
+			handleResult(res)
+		case <-deadline:
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// Resources may have finished right before the deadline was
+			// exceeded. Their results are handled so that they are not
+			// reported as timed out. Results of condition funcs that were
+			// stopped by the deadline are left pending.
+			for drained := false; !drained; {
+				select {
+				case res := <-resultCh:
+					if res.err != context.DeadlineExceeded {
+						handleResult(res)
+					}
+				default:
+					drained = true
+				}
+			}
+
+			for _, info := range infos {
+				options, ok := pending[info]
+				if !ok {
+					continue
+				}
+
+				err := waitTimeoutError(errors.Errorf("timed out after %s waiting for all resources", r.Timeout), info)
+
+				w.Progress.Done(info, func() {
+					if err := w.handleResult(&result{info: info, options: options, err: err}); err != nil {
+						errs = append(errs, err)
+					}
+				})
+			}
+
+			return utilerrors.Reduce(utilerrors.NewAggregate(errs))
 		}
+	}
 
-		return err
-	})
+	return utilerrors.Reduce(utilerrors.NewAggregate(errs))
+}
+
+// handleResult prints successful results and returns an error if waiting
+// for the resource failed. Skipped resources and failures that are allowed
+// are only logged.
+func (w *waiter) handleResult(res *result) error {
+	if res.success {
+		w.Printer.PrintObj(res.obj, w.Out)
+		return nil
+	}
+
+	err := res.err
+
+	skipErr, ok := err.(*WaitSkippedError)
+	if ok && skipErr != nil {
+		fmt.Fprintln(w.ErrOut, skipErr.Error())
+		return nil
+	}
+
+	statusError, ok := err.(*StatusFailedError)
+	if ok && statusError != nil && res.options.AllowFailure {
+		fmt.Fprintln(w.ErrOut, statusError.Error())
+		return nil
+	}
+
+	timeoutError, ok := err.(*WaitTimeoutError)
+	if ok && timeoutError != nil && res.options.AllowFailure {
+		fmt.Fprintln(w.ErrOut, timeoutError.Error())
+		return nil
+	}
+
+	if err == nil {
+		return errors.Errorf("%v unsatisified for unknown reason", res.obj)
+	}
 
 	return err
 }
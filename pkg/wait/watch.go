@@ -0,0 +1,159 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// watchUntil waits until statusFn reports true for the object described by
+// info, statusFn returns an error or the timeout from o is exceeded. The
+// object is retrieved once via list to obtain the resourceVersion to watch
+// from and then watched for changes. If list or watch are not possible or
+// watches are repeatedly closed prematurely, it falls back to polling. Waiting
+// stops with the context error once ctx is cancelled.
+func watchUntil(
+	ctx context.Context,
+	client dynamic.Interface,
+	errOut io.Writer,
+	info *resource.Info,
+	o Options,
+	statusFn func(obj *unstructured.Unstructured) (bool, error),
+) (runtime.Object, bool, error) {
+	endTime := time.Now().Add(o.Timeout)
+	errOut = o.errOut(errOut)
+
+	var closes watchCloses
+
+	poll := func() (runtime.Object, bool, error) {
+		return pollUntil(ctx, client, info, o, endTime, func(obj *unstructured.Unstructured) (bool, error) {
+			if obj == nil {
+				return false, nil
+			}
+
+			return statusFn(obj)
+		})
+	}
+
+	condition := func(event watch.Event) (bool, error) {
+		if event.Type == watch.Error {
+			// keep waiting in the event we see an error - we expect the watch to be closed by
+			// the server
+			err := apierrors.FromObject(event.Object)
+			fmt.Fprintf(errOut, "error: An error occurred while waiting for the condition to be satisfied: %v", err)
+			return false, nil
+		}
+
+		if event.Type == watch.Deleted {
+			// this will chain back out, result in another get and an return false back up the chain
+			return false, nil
+		}
+
+		obj := event.Object.(*unstructured.Unstructured)
+
+		o.observe(obj)
+
+		return statusFn(obj)
+	}
+
+	for {
+		if len(info.Name) == 0 {
+			return info.Object, false, fmt.Errorf("resource name must be provided")
+		}
+
+		nameSelector := fields.OneTermEqualSelector("metadata.name", info.Name).String()
+
+		var obj *unstructured.Unstructured
+		// List with a name field selector to get the current resourceVersion
+		// to watch from (not the object's resourceVersion)
+		objList, err := client.
+			Resource(info.Mapping.Resource).
+			Namespace(info.Namespace).
+			List(metav1.ListOptions{FieldSelector: nameSelector})
+
+		var resourceVersion string
+
+		switch {
+		case isWatchUnsupported(err):
+			return poll()
+		case err != nil:
+			return info.Object, false, err
+		case len(objList.Items) != 1:
+			resourceVersion = objList.GetResourceVersion()
+		default:
+			obj = &objList.Items[0]
+			o.observe(obj)
+			done, err := statusFn(obj)
+			if done {
+				return obj, true, nil
+			}
+			if err != nil {
+				return obj, false, err
+			}
+			resourceVersion = objList.GetResourceVersion()
+		}
+
+		watchOptions := metav1.ListOptions{
+			FieldSelector:   nameSelector,
+			ResourceVersion: resourceVersion,
+		}
+
+		objWatch, err := client.
+			Resource(info.Mapping.Resource).
+			Namespace(info.Namespace).
+			Watch(watchOptions)
+		if isWatchUnsupported(err) {
+			return poll()
+		}
+
+		if err != nil {
+			return obj, false, err
+		}
+
+		errWaitTimeout := waitTimeoutError(wait.ErrWaitTimeout, info)
+		if endTime.Sub(time.Now()) < 0 {
+			return obj, false, errWaitTimeout
+		}
+
+		watchCtx, cancel := watchtools.ContextWithOptionalTimeout(ctx, o.Timeout)
+
+		watchStart := time.Now()
+
+		watchEvent, err := watchtools.UntilWithoutRetry(watchCtx, objWatch, condition)
+
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return obj, false, ctx.Err()
+		case err == nil:
+			return watchEvent.Object, true, nil
+		case err == watchtools.ErrWatchClosed:
+			if closes.closed(watchStart) {
+				return poll()
+			}
+
+			continue
+		case err == wait.ErrWaitTimeout:
+			if watchEvent != nil {
+				return watchEvent.Object, false, errWaitTimeout
+			}
+
+			return obj, false, errWaitTimeout
+		default:
+			return obj, false, err
+		}
+	}
+}